@@ -0,0 +1,139 @@
+package y3
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/yomorun/y3/encoding"
+)
+
+// Canonicalize returns the canonical encoding of the packet in buf, so the
+// same logical message is always encoded to the same bytes:
+//
+//   - the children of a node are sorted by SeqID, the packets with the same
+//     SeqID and the elements of a slice keep their order
+//   - the lengths are the shortest PVarInts
+//
+// The Val of primitives are kept as is, since their types are unknown, see
+// CanonicalizeTyped. buf should be exactly one packet accepted by Validate.
+func Canonicalize(buf []byte) ([]byte, error) {
+	return canonicalize(buf, false)
+}
+
+// CanonicalizeTyped is like Canonicalize, all the primitives are treated as
+// typed primitives, and the numbers, bools and times are re-encoded to the
+// shortest Val
+func CanonicalizeTyped(buf []byte) ([]byte, error) {
+	return canonicalize(buf, true)
+}
+
+// IsCanonical returns true if buf is exactly one packet in canonical
+// encoding, see Canonicalize
+func IsCanonical(buf []byte) bool {
+	res, err := Canonicalize(buf)
+	return err == nil && bytes.Equal(res, buf)
+}
+
+// IsCanonicalTyped returns true if buf is exactly one packet of typed
+// primitives in canonical encoding, see CanonicalizeTyped
+func IsCanonicalTyped(buf []byte) bool {
+	res, err := CanonicalizeTyped(buf)
+	return err == nil && bytes.Equal(res, buf)
+}
+
+func canonicalize(buf []byte, typed bool) ([]byte, error) {
+	if err := Validate(buf, nil); err != nil {
+		return nil, err
+	}
+	p, err := readRawPacket(buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalPacket(nil, p, typed)
+}
+
+// canonicalPacket appends the canonical encoding of p to dst
+func canonicalPacket(dst []byte, p *rawPacket, typed bool) ([]byte, error) {
+	val := p.val
+	var err error
+	switch {
+	case p.tag.IsNode():
+		val, err = canonicalVal(p.val, p.off+len(p.header), p.tag.IsSlice(), typed)
+	case typed && !p.isNull():
+		val, err = canonicalTypedVal(p.val)
+		if err != nil {
+			err = fmt.Errorf("y3: offset %d: %v", p.off, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return appendPacket(dst, p.header[0], val), nil
+}
+
+// canonicalVal returns the canonical encoding of the packets in the Val of a
+// node, off is the offset of val
+func canonicalVal(val []byte, off int, slice, typed bool) ([]byte, error) {
+	type child struct {
+		sid byte
+		buf []byte
+	}
+	var children []child
+	err := eachRawPacket(val, off, func(p *rawPacket) error {
+		buf, err := canonicalPacket(nil, p, typed)
+		if err != nil {
+			return err
+		}
+		children = append(children, child{sid: p.tag.SeqID(), buf: buf})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !slice {
+		sort.SliceStable(children, func(i, j int) bool {
+			return children[i].sid < children[j].sid
+		})
+	}
+	res := make([]byte, 0, len(val))
+	for _, c := range children {
+		res = append(res, c.buf...)
+	}
+	return res, nil
+}
+
+// canonicalTypedVal returns the shortest Val of a typed primitive
+func canonicalTypedVal(val []byte) ([]byte, error) {
+	if len(val) == 0 {
+		return nil, fmt.Errorf("missing the Kind of typed primitive")
+	}
+	switch Kind(val[0]) {
+	case KindString, KindBytes:
+		return val, nil
+	case KindInt32, KindInt64, KindUInt32, KindUInt64, KindFloat32, KindFloat64, KindBool, KindTime:
+	default:
+		return nil, fmt.Errorf("unknown Kind %d", val[0])
+	}
+	v, err := (&PrimitiveValue{typed: true, valbuf: val}).Value()
+	if err != nil {
+		return nil, err
+	}
+	enc := NewTypedPrimitivePacketEncoder(0)
+	if err := enc.SetValue(v); err != nil {
+		return nil, err
+	}
+	return enc.GetValBuf(), nil
+}
+
+// appendPacket appends the packet of tag and val with the shortest length to dst
+func appendPacket(dst []byte, tag byte, val []byte) []byte {
+	size := encoding.SizeOfPVarInt32(int32(len(val)))
+	codec := encoding.VarCodec{Size: size}
+	dst = append(dst, tag)
+	n := len(dst)
+	dst = append(dst, make([]byte, size)...)
+	// the size is computed, encoding never fails
+	_ = codec.EncodePVarInt32(dst[n:], int32(len(val)))
+	return append(dst, val...)
+}
@@ -0,0 +1,86 @@
+package y3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalize(t *testing.T) {
+	buf, err := ParseText(`0x01 { 0x03: "c" 0x02 { 0x05: 1 0x04: 2 } 0x06 [2 1] 0x02: "d" }`)
+	assert.NoError(t, err)
+	assert.False(t, IsCanonical(buf))
+
+	want, err := ParseText(`0x01 { 0x02 { 0x04: 2 0x05: 1 } 0x02: "d" 0x03: "c" 0x06 [2 1] }`)
+	assert.NoError(t, err)
+	res, err := Canonicalize(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, want, res)
+	assert.True(t, IsCanonical(res))
+
+	// over-long lengths
+	assert.False(t, IsCanonical([]byte{0x81, 0x80, 0x00}))
+	res, err = Canonicalize([]byte{0x81, 0x80, 0x00})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x81, 0x00}, res)
+	res, err = Canonicalize([]byte{0x81, 0x04, 0x01, 0x80, 0x01, 0x61})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x81, 0x03, 0x01, 0x01, 0x61}, res)
+
+	_, err = Canonicalize([]byte{0x81, 0x03, 0x01, 0x02, 0x61})
+	assert.Error(t, err)
+	assert.False(t, IsCanonical(nil))
+}
+
+func TestCanonicalizeTyped(t *testing.T) {
+	enc := NewTypedPrimitivePacketEncoder(0x02)
+	enc.SetInt64Value(1)
+	short := enc.Encode()
+	long := []byte{0x02, 0x04, byte(KindInt64), 0x00, 0x00, 0x01}
+	tm := NewTypedPrimitivePacketEncoder(0x01)
+	tm.SetTimeValue(time.Unix(1, 0))
+
+	root := NewNodePacketEncoder(0x3F)
+	root.AddPrimitivePacket(tm)
+	root.AddBytes(long)
+	buf := root.Encode()
+	assert.True(t, IsCanonical(buf))
+	assert.False(t, IsCanonicalTyped(buf))
+
+	want := append([]byte{0xBF, byte(len(short) + len(tm.Encode()))}, tm.Encode()...)
+	want = append(want, short...)
+	res, err := CanonicalizeTyped(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, want, res)
+	assert.True(t, IsCanonicalTyped(res))
+
+	_, err = CanonicalizeTyped([]byte{0x81, 0x03, 0x01, 0x01, 0x7F})
+	if assert.Error(t, err) {
+		assert.Equal(t, "y3: offset 2: unknown Kind 127", err.Error())
+	}
+}
+
+func TestCanonicalEncoder(t *testing.T) {
+	newNode := func(canonical bool) *NodePacketEncoder {
+		node := NewNodePacketEncoder(0x01)
+		node.SetCanonical(canonical)
+		for _, sid := range []byte{0x03, 0x01, 0x02} {
+			p := NewPrimitivePacketEncoder(sid)
+			p.SetInt32Value(int32(sid))
+			node.AddPrimitivePacket(p)
+		}
+		return node
+	}
+	assert.Equal(t, []byte{0x81, 0x09, 0x03, 0x01, 0x03, 0x01, 0x01, 0x01, 0x02, 0x01, 0x02}, newNode(false).Encode())
+	assert.Equal(t, []byte{0x81, 0x09, 0x01, 0x01, 0x01, 0x02, 0x01, 0x02, 0x03, 0x01, 0x03}, newNode(true).Encode())
+
+	// the elements of slice keep the order
+	s := NewNodeSlicePacketEncoder(0x01)
+	s.SetCanonical(true)
+	s.AddNodePacket(newNode(false))
+	s.AddNodePacket(NewNodePacketEncoder(0x00))
+	res := s.Encode()
+	assert.Equal(t, []byte{0xC1, 0x0D, 0x81, 0x09, 0x01, 0x01, 0x01, 0x02, 0x01, 0x02, 0x03, 0x01, 0x03, 0x80, 0x00}, res)
+	assert.True(t, IsCanonical(res))
+}
@@ -0,0 +1,65 @@
+// Command y3 is the toolkit for Y3 packets and schemas.
+//
+// Usage:
+//
+//	y3 <command> [arguments]
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// command is a subcommand of y3
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []*command{
+	dumpCommand,
+	validateCommand,
+	toJSONCommand,
+	fromJSONCommand,
+	hexCommand,
+	catCommand,
+	diffCommand,
+	redactCommand,
+	schemaCommand,
+}
+
+// errUsage tells main to print the usage of the command
+var errUsage = errors.New("usage")
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+		if err := cmd.run(os.Args[2:]); err != nil {
+			if err == errUsage {
+				fmt.Fprintf(os.Stderr, "usage: y3 %s\n", cmd.usage)
+				os.Exit(2)
+			}
+			fmt.Fprintf(os.Stderr, "y3 %s: %v\n", cmd.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: y3 <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "\ty3 %s\n", cmd.usage)
+	}
+}
@@ -0,0 +1,563 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yomorun/y3"
+	"github.com/yomorun/y3/schema"
+	"github.com/yomorun/y3/y3json"
+)
+
+var dumpCommand = &command{
+	name:  "dump",
+	usage: "dump [-schema file.y3] [-typed] [file]",
+	run:   runDump,
+}
+
+var validateCommand = &command{
+	name:  "validate",
+	usage: "validate [-schema file.y3] [-message name] [file]",
+	run:   runValidate,
+}
+
+var toJSONCommand = &command{
+	name:  "tojson",
+	usage: "tojson [-schema file.y3] [-message name] [-typed] [file]",
+	run:   runToJSON,
+}
+
+var fromJSONCommand = &command{
+	name:  "fromjson",
+	usage: "fromjson [-schema file.y3] [-message name] [-seqid id] [-typed] [file]",
+	run:   runFromJSON,
+}
+
+var hexCommand = &command{
+	name:  "hex",
+	usage: "hex [-r] [file]",
+	run:   runHex,
+}
+
+var diffCommand = &command{
+	name:  "diff",
+	usage: "diff file1 file2",
+	run:   runDiff,
+}
+
+var redactCommand = &command{
+	name:  "redact",
+	usage: "redact [-schema file.y3] [-path path]... [-hash key] [-typed] [file]",
+	run:   runRedact,
+}
+
+var catCommand = &command{
+	name:  "cat",
+	usage: "cat [-d dir] [file...]",
+	run:   runCat,
+}
+
+// The packet commands read the packets one after another from the file, or
+// from stdin if the file is omitted or "-", and write to stdout.
+
+// openInput opens the file, or returns stdin if path is empty or "-"
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "" || path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+// inputArg returns the only optional file argument of fs
+func inputArg(fs *flag.FlagSet) (string, error) {
+	switch fs.NArg() {
+	case 0:
+		return "", nil
+	case 1:
+		return fs.Arg(0), nil
+	}
+	return "", errUsage
+}
+
+// countingReader counts the bytes read, so the offsets of packets are known
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+// forEachPacket reads the packets from r by y3.ReadPacket, off is the offset
+// of the packet in the stream
+func forEachPacket(r io.Reader, fn func(i, off int, buf []byte) error) error {
+	cr := &countingReader{r: bufio.NewReader(r)}
+	for i := 0; ; i++ {
+		off := cr.n
+		buf, err := y3.ReadPacket(cr)
+		if err == io.EOF && cr.n == off {
+			return nil
+		}
+		if err == io.EOF {
+			err = errors.New("truncated packet")
+		}
+		if err != nil {
+			return fmt.Errorf("packet %d at offset %d: %v", i, off, err)
+		}
+		if err := fn(i, off, buf); err != nil {
+			return err
+		}
+	}
+}
+
+// forEachPacketIn opens the file and calls forEachPacket
+func forEachPacketIn(path string, fn func(i, off int, buf []byte) error) error {
+	r, err := openInput(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return forEachPacket(r, fn)
+}
+
+// jsonFlags are the flags of the JSON conversion commands
+type jsonFlags struct {
+	schema  *string
+	message *string
+	typed   *bool
+}
+
+func addJSONFlags(fs *flag.FlagSet) *jsonFlags {
+	return &jsonFlags{
+		schema:  fs.String("schema", "", "schema file giving the field names and types"),
+		message: fs.String("message", "", "full name of the root message, by the SeqID of packet by default"),
+		typed:   fs.Bool("typed", false, "the primitives are typed"),
+	}
+}
+
+func (f *jsonFlags) options() (*y3json.Options, error) {
+	opts := &y3json.Options{Message: *f.message, Typed: *f.typed}
+	if *f.schema != "" {
+		s, err := schema.ParseFile(*f.schema)
+		if err != nil {
+			return nil, err
+		}
+		opts.Schema = s
+	}
+	return opts, nil
+}
+
+// runDump prints the hex dump of packets by y3.DumpWith, the primitives are
+// commented with their values in -schema, or the values they may be
+func runDump(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	schemaPath := fs.String("schema", "", "schema file giving the types of primitives")
+	typed := fs.Bool("typed", false, "decode the primitives by their type codes")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	path, err := inputArg(fs)
+	if err != nil {
+		return err
+	}
+	var value y3.ValueFunc
+	if *schemaPath != "" {
+		s, err := schema.ParseFile(*schemaPath)
+		if err != nil {
+			return err
+		}
+		value = schema.ValueFunc(s)
+	}
+
+	opts := &y3.DumpOptions{
+		Annotate: func(path []byte, isNode bool, val []byte) string {
+			if isNode {
+				return ""
+			}
+			sid := path[len(path)-1]
+			// the schema describes the untyped values
+			if value != nil && !*typed {
+				if text := value(path, val); text != "" {
+					return fmt.Sprintf("0x%02X %s", sid, text)
+				}
+			}
+			return guess(sid, val, *typed)
+		},
+	}
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	return forEachPacketIn(path, func(i, off int, buf []byte) error {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "# packet %d at offset %d, %d bytes\n", i, off, len(buf))
+		w.WriteString(y3.DumpWith(buf, opts))
+		if err := y3.Validate(buf, nil); err != nil {
+			return fmt.Errorf("packet %d at offset %d: %v", i, off, err)
+		}
+		return nil
+	})
+}
+
+// guess returns the comment of the primitive with the values it may be, or
+// the typed value
+func guess(sid byte, val []byte, typed bool) string {
+	p := y3.NewRawPrimitive(sid, val).Packet()
+	if typed {
+		v, err := p.AsTyped().Value()
+		if err != nil {
+			return fmt.Sprintf("0x%02X (%v)", sid, err)
+		}
+		return fmt.Sprintf("0x%02X %v %s", sid, y3.Kind(val[0]), quoteValue(v))
+	}
+
+	// the compact text is like 0x01:"yomo", with the string or the hex
+	guesses := []string{strings.Replace(fmt.Sprint(p), ":", " ", 1)}
+	if len(val) == 1 && val[0] <= 1 {
+		guesses = append(guesses, fmt.Sprintf("bool %v", val[0] == 1))
+	}
+	if len(val) > 0 && len(val) <= 8 {
+		if v, err := p.ToInt64(); err == nil {
+			guesses = append(guesses, fmt.Sprintf("int %d", v))
+		}
+	}
+	if len(val) > 1 && len(val) <= 8 {
+		// skip the floats which are unlikely to be written
+		if v, err := p.ToFloat64(); err == nil && math.Abs(v) >= 1e-9 && math.Abs(v) < 1e15 {
+			guesses = append(guesses, fmt.Sprintf("float64 %g", v))
+		}
+	}
+	return strings.Join(guesses, " | ")
+}
+
+func quoteValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []byte:
+		return fmt.Sprintf("h\"%X\"", v)
+	}
+	return fmt.Sprint(v)
+}
+
+// runValidate checks the packets are well-formed, and match the schema if
+// it's given
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	schemaPath := fs.String("schema", "", "schema file the packets should match")
+	message := fs.String("message", "", "full name of the root message, by the SeqID of packet by default")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	path, err := inputArg(fs)
+	if err != nil {
+		return err
+	}
+	var opts *y3json.Options
+	if *schemaPath != "" {
+		s, err := schema.ParseFile(*schemaPath)
+		if err != nil {
+			return err
+		}
+		opts = &y3json.Options{Schema: s, Message: *message}
+	}
+
+	var count int
+	err = forEachPacketIn(path, func(i, off int, buf []byte) error {
+		count++
+		if err := y3.Validate(buf, nil); err != nil {
+			return fmt.Errorf("packet %d at offset %d: %v", i, off, err)
+		}
+		if opts == nil {
+			return nil
+		}
+		// the conversion checks the packets of the fields
+		if _, err := y3json.Encode(buf, opts); err != nil {
+			return fmt.Errorf("packet %d at offset %d: %v", i, off, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d packets are valid\n", count)
+	return nil
+}
+
+// runToJSON prints the packets as JSON, one object per line
+func runToJSON(args []string) error {
+	fs := flag.NewFlagSet("tojson", flag.ContinueOnError)
+	jf := addJSONFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	path, err := inputArg(fs)
+	if err != nil {
+		return err
+	}
+	opts, err := jf.options()
+	if err != nil {
+		return err
+	}
+
+	enc := y3json.NewEncoder(os.Stdout, opts)
+	return forEachPacketIn(path, func(i, off int, buf []byte) error {
+		if err := enc.Encode(buf); err != nil {
+			return fmt.Errorf("packet %d at offset %d: %v", i, off, err)
+		}
+		return nil
+	})
+}
+
+// runFromJSON converts the JSON objects to packets
+func runFromJSON(args []string) error {
+	fs := flag.NewFlagSet("fromjson", flag.ContinueOnError)
+	jf := addJSONFlags(fs)
+	sid := fs.String("seqid", "0", "SeqID of the root packets, the SeqID of the root message is used if it declares one")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	path, err := inputArg(fs)
+	if err != nil {
+		return err
+	}
+	opts, err := jf.options()
+	if err != nil {
+		return err
+	}
+	v, err := strconv.ParseUint(*sid, 0, 8)
+	if err != nil || v > 0x3F {
+		return fmt.Errorf("SeqID %s should be in [0..0x3F]", *sid)
+	}
+	opts.SeqID = byte(v)
+
+	r, err := openInput(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	dec := y3json.NewDecoder(r, opts)
+	for i := 0; ; i++ {
+		buf, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("object %d: %v", i, err)
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+}
+
+// runHex prints the packets in hex, one packet per line, or converts the hex
+// back to binary with -r
+func runHex(args []string) error {
+	fs := flag.NewFlagSet("hex", flag.ContinueOnError)
+	reverse := fs.Bool("r", false, "convert hex to binary, 0x prefixes and separators are ignored")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	path, err := inputArg(fs)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	if !*reverse {
+		return forEachPacketIn(path, func(i, off int, buf []byte) error {
+			_, err := fmt.Fprintf(w, "% X\n", buf)
+			return err
+		})
+	}
+
+	r, err := openInput(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	buf, err := parseHex(string(src))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// parseHex decodes hex dumps like "81 08 02", "0x81, 0x8" or "8108", the
+// byte slice literals of Go can be pasted too
+func parseHex(s string) ([]byte, error) {
+	s = strings.ReplaceAll(s, "[]byte", "")
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return strings.ContainsRune(" \t\r\n,:;{}[]", r)
+	})
+	var res []byte
+	for _, f := range fields {
+		if strings.HasPrefix(f, "0x") || strings.HasPrefix(f, "0X") {
+			f = f[2:]
+			if len(f) == 1 {
+				f = "0" + f
+			}
+		}
+		buf, err := hex.DecodeString(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex %q", f)
+		}
+		res = append(res, buf...)
+	}
+	return res, nil
+}
+
+// runCat writes the packets of the files to stdout, the packets are checked
+// one by one, or writes every packet to a file in the directory with -d
+func runCat(args []string) error {
+	fs := flag.NewFlagSet("cat", flag.ContinueOnError)
+	dir := fs.String("d", "", "write every packet to dir/NNNNNN.y3b instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	var count int
+	for _, path := range paths {
+		err := forEachPacketIn(path, func(i, off int, buf []byte) error {
+			count++
+			if *dir == "" {
+				_, err := w.Write(buf)
+				return err
+			}
+			return os.WriteFile(filepath.Join(*dir, fmt.Sprintf("%06d.y3b", count)), buf, 0644)
+		})
+		if err != nil {
+			if path != "-" {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// runDiff prints the structural differences between the packets of two files
+// one by one, and fails if they differ
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 {
+		return errUsage
+	}
+	var packets [2][][]byte
+	for i := range packets {
+		err := forEachPacketIn(fs.Arg(i), func(_, _ int, buf []byte) error {
+			packets[i] = append(packets[i], buf)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %v", fs.Arg(i), err)
+		}
+	}
+
+	var differ int
+	for i := 0; i < len(packets[0]) || i < len(packets[1]); i++ {
+		var a, b []byte
+		if i < len(packets[0]) {
+			a = packets[0][i]
+		}
+		if i < len(packets[1]) {
+			b = packets[1][i]
+		}
+		changes := y3.Diff(a, b)
+		if len(changes) == 0 {
+			continue
+		}
+		differ++
+		fmt.Printf("packet %d:\n%s", i, y3.FormatDiff(changes))
+	}
+	if differ > 0 {
+		return fmt.Errorf("%d packets differ", differ)
+	}
+	return nil
+}
+
+// runRedact writes the packets with the values of the fields selected by
+// -path, or declared with the redact option in -schema, replaced
+func runRedact(args []string) error {
+	fs := flag.NewFlagSet("redact", flag.ContinueOnError)
+	schemaPath := fs.String("schema", "", "schema file declaring the fields to redact")
+	var paths pathList
+	fs.Var(&paths, "path", "path of the packets to redact, e.g. 0x05[*].0x01, it can be repeated")
+	key := fs.String("hash", "", "replace the values by their HMAC-SHA256 with key instead of "+y3.RedactMarker)
+	typed := fs.Bool("typed", false, "the primitives are typed")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	path, err := inputArg(fs)
+	if err != nil {
+		return err
+	}
+
+	r := &y3.Redactor{}
+	if *schemaPath != "" {
+		s, err := schema.ParseFile(*schemaPath)
+		if err != nil {
+			return err
+		}
+		r = schema.NewRedactor(s)
+	}
+	r.Paths = append(r.Paths, paths...)
+	if *key != "" {
+		r.Mask = y3.HashMask([]byte(*key))
+	}
+	r.Typed = *typed
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	return forEachPacketIn(path, func(i, off int, buf []byte) error {
+		res, err := r.Redact(buf)
+		if err != nil {
+			return fmt.Errorf("packet %d at offset %d: %v", i, off, err)
+		}
+		_, err = w.Write(res)
+		return err
+	})
+}
+
+// pathList is the value of a repeated path flag
+type pathList []y3.Path
+
+func (l *pathList) String() string {
+	return fmt.Sprint(*l)
+}
+
+func (l *pathList) Set(s string) error {
+	p, err := y3.ParsePath(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, p)
+	return nil
+}
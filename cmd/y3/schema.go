@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yomorun/y3/schema"
+)
+
+var schemaCommand = &command{
+	name:  "schema",
+	usage: "schema compile [-o output] [-package name] file.y3 | schema check old.y3 new.y3 | schema infer [-go] [-o output] [-package name] capture...",
+	run:   runSchema,
+}
+
+func runSchema(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "compile":
+		return schemaCompile(args[1:])
+	case "check":
+		return schemaCheck(args[1:])
+	case "infer":
+		return schemaInfer(args[1:])
+	}
+	return errUsage
+}
+
+// schemaCompile generates Go code from a .y3 file, the output is <file>_y3.go by default
+func schemaCompile(args []string) error {
+	fs := flag.NewFlagSet("schema compile", flag.ContinueOnError)
+	out := fs.String("o", "", "output file, <file>_y3.go by default")
+	pkg := fs.String("package", "", "Go package name, overrides the package in schema")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	path := fs.Arg(0)
+	s, err := schema.ParseFile(path)
+	if err != nil {
+		return err
+	}
+	src, err := schema.GenerateGo(s, schema.GoOptions{Package: *pkg, Source: filepath.Base(path)})
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = strings.TrimSuffix(path, ".y3") + "_y3.go"
+	}
+	return os.WriteFile(*out, src, 0644)
+}
+
+// schemaCheck reports the incompatible changes from the old schema to the new one
+func schemaCheck(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	old, err := schema.ParseFile(args[0])
+	if err != nil {
+		return err
+	}
+	new, err := schema.ParseFile(args[1])
+	if err != nil {
+		return err
+	}
+	res := schema.Check(old, new)
+	for _, i := range res {
+		fmt.Println(i)
+	}
+	if len(res) > 0 {
+		return fmt.Errorf("%d incompatible changes", len(res))
+	}
+	return nil
+}
+
+// schemaInfer prints a draft schema inferred from the packets in the capture
+// files, every file contains Y3 packets one after another
+func schemaInfer(args []string) error {
+	fs := flag.NewFlagSet("schema infer", flag.ContinueOnError)
+	goCode := fs.Bool("go", false, "generate Go code instead of schema")
+	out := fs.String("o", "", "output file, stdout by default")
+	pkg := fs.String("package", "draft", "package name")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() == 0 {
+		return errUsage
+	}
+
+	var packets [][]byte
+	for _, path := range fs.Args() {
+		buf, err := readPackets(path)
+		if err != nil {
+			return err
+		}
+		packets = append(packets, buf...)
+	}
+	s, err := schema.Infer(packets)
+	if err != nil {
+		return err
+	}
+	s.Package = *pkg
+
+	src := schema.Format(s)
+	if *goCode {
+		if src, err = schema.GenerateGo(s, schema.GoOptions{}); err != nil {
+			return err
+		}
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0644)
+}
+
+func readPackets(path string) ([][]byte, error) {
+	var packets [][]byte
+	err := forEachPacketIn(path, func(i, off int, buf []byte) error {
+		packets = append(packets, buf)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return packets, nil
+}
@@ -0,0 +1,172 @@
+package y3
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Marshaler is implemented by types which encode themselves as a primitive packet
+type Marshaler interface {
+	MarshalY3(enc *PrimitivePacketEncoder) error
+}
+
+// Unmarshaler is implemented by types which decode themselves from a primitive packet
+type Unmarshaler interface {
+	UnmarshalY3(p *PrimitivePacket) error
+}
+
+var (
+	marshalerType         = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType       = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	textMarshalerType     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// codec is a registered pair of encode and decode functions
+type codec struct {
+	encode func(v reflect.Value, enc *PrimitivePacketEncoder) error
+	decode func(p *PrimitivePacket, v reflect.Value) error
+}
+
+var codecs sync.Map // map[reflect.Type]*codec
+
+// RegisterCodec registers the functions which encode and decode the type T as
+// a primitive packet, e.g.
+//
+//	y3.RegisterCodec(func(v uuid.UUID, enc *y3.PrimitivePacketEncoder) error {
+//		enc.SetBytesValue(v[:])
+//		return nil
+//	}, func(p *y3.PrimitivePacket) (uuid.UUID, error) {
+//		return uuid.FromBytes(p.ToBytes())
+//	})
+//
+// Marshal, Unmarshal and the Value model look for the encoding of a type in
+// order: the registered codec, Marshaler and Unmarshaler, encoding.BinaryMarshaler
+// and encoding.BinaryUnmarshaler as bytes, encoding.TextMarshaler and
+// encoding.TextUnmarshaler as string, and then the builtin encoding. time.Time
+// always uses the builtin encoding unless a codec is registered.
+//
+// RegisterCodec panics if T is already registered, it's supposed to be called
+// in init functions.
+func RegisterCodec[T any](encode func(v T, enc *PrimitivePacketEncoder) error, decode func(p *PrimitivePacket) (T, error)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	c := &codec{
+		encode: func(v reflect.Value, enc *PrimitivePacketEncoder) error {
+			return encode(v.Interface().(T), enc)
+		},
+		decode: func(p *PrimitivePacket, v reflect.Value) error {
+			res, err := decode(p)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(&res).Elem())
+			return nil
+		},
+	}
+	if _, loaded := codecs.LoadOrStore(t, c); loaded {
+		panic(fmt.Errorf("y3: codec of %v is already registered", t))
+	}
+}
+
+// customEncoder returns the function encoding v by the registered codec or the
+// marshaler interfaces, nil if v uses the builtin encoding
+func customEncoder(v reflect.Value) func(enc *PrimitivePacketEncoder) error {
+	t := v.Type()
+	if c, ok := codecs.Load(t); ok {
+		return func(enc *PrimitivePacketEncoder) error {
+			return c.(*codec).encode(v, enc)
+		}
+	}
+	m := marshalerOf(t)
+	if m == nil {
+		return nil
+	}
+	return func(enc *PrimitivePacketEncoder) error {
+		return m(v, enc)
+	}
+}
+
+var marshalers sync.Map // map[reflect.Type]func(v reflect.Value, enc *PrimitivePacketEncoder) error
+
+// marshalerOf returns the function encoding the value of type t by the
+// marshaler interfaces, nil if t doesn't implement them. The result is cached,
+// so the methods of t are looked up once.
+func marshalerOf(t reflect.Type) func(v reflect.Value, enc *PrimitivePacketEncoder) error {
+	if m, ok := marshalers.Load(t); ok {
+		return m.(func(v reflect.Value, enc *PrimitivePacketEncoder) error)
+	}
+	m := newMarshaler(t)
+	marshalers.Store(t, m)
+	return m
+}
+
+func newMarshaler(t reflect.Type) func(v reflect.Value, enc *PrimitivePacketEncoder) error {
+	if t == timeType || t.Kind() == reflect.Pointer || t.Kind() == reflect.Interface {
+		return nil
+	}
+
+	// the methods with pointer receiver are called on a copy, so v needs not
+	// to be addressable
+	rt, receiver := t, func(v reflect.Value) any {
+		return v.Interface()
+	}
+	if !t.Implements(marshalerType) && !t.Implements(binaryMarshalerType) && !t.Implements(textMarshalerType) {
+		rt, receiver = reflect.PointerTo(t), func(v reflect.Value) any {
+			p := reflect.New(t)
+			p.Elem().Set(v)
+			return p.Interface()
+		}
+	}
+	switch {
+	case rt.Implements(marshalerType):
+		return func(v reflect.Value, enc *PrimitivePacketEncoder) error {
+			return receiver(v).(Marshaler).MarshalY3(enc)
+		}
+	case rt.Implements(binaryMarshalerType):
+		return func(v reflect.Value, enc *PrimitivePacketEncoder) error {
+			buf, err := receiver(v).(encoding.BinaryMarshaler).MarshalBinary()
+			enc.SetBytesValue(buf)
+			return err
+		}
+	case rt.Implements(textMarshalerType):
+		return func(v reflect.Value, enc *PrimitivePacketEncoder) error {
+			buf, err := receiver(v).(encoding.TextMarshaler).MarshalText()
+			enc.SetStringValue(string(buf))
+			return err
+		}
+	}
+	return nil
+}
+
+// customDecoder returns the function decoding to the addressable v of type t
+// by the registered codec or the unmarshaler interfaces, nil if t uses the
+// builtin encoding
+func customDecoder(t reflect.Type) func(p *PrimitivePacket, v reflect.Value) error {
+	if c, ok := codecs.Load(t); ok {
+		return c.(*codec).decode
+	}
+	if t == timeType || t.Kind() == reflect.Pointer || t.Kind() == reflect.Interface {
+		return nil
+	}
+
+	pt := reflect.PointerTo(t)
+	switch {
+	case pt.Implements(unmarshalerType):
+		return func(p *PrimitivePacket, v reflect.Value) error {
+			return v.Addr().Interface().(Unmarshaler).UnmarshalY3(p)
+		}
+	case pt.Implements(binaryUnmarshalerType):
+		return func(p *PrimitivePacket, v reflect.Value) error {
+			return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(append([]byte{}, p.ToBytes()...))
+		}
+	case pt.Implements(textUnmarshalerType):
+		return func(p *PrimitivePacket, v reflect.Value) error {
+			return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(p.ToBytes())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package y3
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testPoint struct {
+	Lat, Lng float64
+}
+
+func init() {
+	RegisterCodec(func(v testPoint, enc *PrimitivePacketEncoder) error {
+		buf := make([]byte, 16)
+		binary.BigEndian.PutUint64(buf, math.Float64bits(v.Lat))
+		binary.BigEndian.PutUint64(buf[8:], math.Float64bits(v.Lng))
+		enc.SetBytesValue(buf)
+		return nil
+	}, func(p *PrimitivePacket) (testPoint, error) {
+		buf := p.ToBytes()
+		if len(buf) != 16 {
+			return testPoint{}, errors.New("invalid point")
+		}
+		return testPoint{
+			Lat: math.Float64frombits(binary.BigEndian.Uint64(buf)),
+			Lng: math.Float64frombits(binary.BigEndian.Uint64(buf[8:])),
+		}, nil
+	})
+}
+
+type testLevel int
+
+var testLevelNames = []string{"debug", "info", "warn"}
+
+func (l testLevel) MarshalY3(enc *PrimitivePacketEncoder) error {
+	if l < 0 || int(l) >= len(testLevelNames) {
+		return errors.New("invalid level")
+	}
+	enc.SetStringValue(testLevelNames[l])
+	return nil
+}
+
+func (l *testLevel) UnmarshalY3(p *PrimitivePacket) error {
+	s, err := p.ToUTF8String()
+	if err != nil {
+		return err
+	}
+	for i, name := range testLevelNames {
+		if name == s {
+			*l = testLevel(i)
+			return nil
+		}
+	}
+	return errors.New("invalid level")
+}
+
+// testVersion implements BinaryMarshaler with a pointer receiver
+type testVersion struct {
+	Major, Minor byte
+}
+
+func (v *testVersion) MarshalBinary() ([]byte, error) {
+	return []byte{v.Major, v.Minor}, nil
+}
+
+func (v *testVersion) UnmarshalBinary(buf []byte) error {
+	if len(buf) != 2 {
+		return errors.New("invalid version")
+	}
+	v.Major, v.Minor = buf[0], buf[1]
+	return nil
+}
+
+type testDevice struct {
+	IP       net.IP      `y3:"0x01"`
+	Level    testLevel   `y3:"0x02"`
+	Location *testPoint  `y3:"0x03"`
+	Version  testVersion `y3:"0x04"`
+	Levels   []testLevel `y3:"0x05"`
+}
+
+func TestMarshalCustomTypes(t *testing.T) {
+	dev := testDevice{
+		IP:       net.ParseIP("10.0.0.1"),
+		Level:    2,
+		Location: &testPoint{Lat: 31.2, Lng: 121.5},
+		Version:  testVersion{Major: 1, Minor: 3},
+		Levels:   []testLevel{0, 1},
+	}
+	// not addressable
+	buf, err := Marshal(0x10, dev)
+	assert.NoError(t, err)
+
+	v, err := Parse(buf)
+	assert.NoError(t, err)
+	node := v.(*NodeValue)
+	// TextMarshaler as string
+	assert.Equal(t, []byte("10.0.0.1"), node.Get(0x01).(*PrimitiveValue).Bytes())
+	// Marshaler
+	assert.Equal(t, []byte("warn"), node.Get(0x02).(*PrimitiveValue).Bytes())
+	// registered codec
+	assert.Len(t, node.Get(0x03).(*PrimitiveValue).Bytes(), 16)
+	// BinaryMarshaler as bytes
+	assert.Equal(t, []byte{0x01, 0x03}, node.Get(0x04).(*PrimitiveValue).Bytes())
+
+	var res testDevice
+	assert.NoError(t, Unmarshal(buf, &res))
+	assert.Equal(t, dev, res)
+}
+
+func TestCustomTypeErrors(t *testing.T) {
+	_, err := Marshal(0x10, &testDevice{Level: 5})
+	assert.EqualError(t, err, "y3: marshal y3.testLevel: invalid level")
+
+	buf, err := Marshal(0x10, struct {
+		Level string `y3:"0x02"`
+	}{Level: "fatal"})
+	assert.NoError(t, err)
+	var res testDevice
+	assert.EqualError(t, Unmarshal(buf, &res), "y3: unmarshal y3.testLevel: invalid level")
+
+	assert.Panics(t, func() {
+		RegisterCodec(func(v testPoint, enc *PrimitivePacketEncoder) error { return nil },
+			func(p *PrimitivePacket) (testPoint, error) { return testPoint{}, nil })
+	})
+}
+
+func TestValueCustomTypes(t *testing.T) {
+	p, err := NewPrimitive(0x01, net.ParseIP("10.0.0.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, KindString, p.Kind())
+
+	var ip net.IP
+	assert.NoError(t, p.Decode(&ip))
+	assert.Equal(t, "10.0.0.1", ip.String())
+
+	p, err = NewPrimitive(0x02, testPoint{Lat: 1, Lng: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, KindBytes, p.Kind())
+	var point testPoint
+	assert.NoError(t, p.Decode(&point))
+	assert.Equal(t, testPoint{Lat: 1, Lng: 2}, point)
+
+	// untyped primitive
+	var level testLevel
+	assert.NoError(t, NewRawPrimitive(0x03, []byte("info")).Decode(&level))
+	assert.Equal(t, testLevel(1), level)
+
+	assert.EqualError(t, p.Decode(point), "y3: Decode needs a non-nil pointer, got y3.testPoint")
+}
+
+// testRaw writes its Val by AddBytes
+type testRaw []byte
+
+func (r testRaw) MarshalY3(enc *PrimitivePacketEncoder) error {
+	enc.AddBytes(r)
+	return nil
+}
+
+func TestMarshalerAddBytes(t *testing.T) {
+	buf, err := Marshal(0x10, struct {
+		Raw testRaw `y3:"0x01"`
+	}{Raw: testRaw{0x01, 0x02}})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x90, 0x04, 0x01, 0x02, 0x01, 0x02}, buf)
+
+	// the type code would be lost in typed mode
+	_, err = NewPrimitive(0x01, testRaw{0x01, 0x02})
+	assert.EqualError(t, err, "y3: y3.testRaw is encoded by AddBytes without type code")
+}
+
+func TestMarshalerCache(t *testing.T) {
+	_, err := Marshal(0x10, struct {
+		Device testDevice `y3:"0x01"`
+		Count  int32      `y3:"0x02"`
+	}{})
+	assert.NoError(t, err)
+	for _, v := range []any{testLevel(0), testVersion{}, int32(0)} {
+		m, ok := marshalers.Load(reflect.TypeOf(v))
+		assert.True(t, ok, "%T", v)
+		assert.Equal(t, v != int32(0), m.(func(reflect.Value, *PrimitivePacketEncoder) error) != nil, "%T", v)
+	}
+}
@@ -0,0 +1,332 @@
+package y3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/yomorun/y3/encoding"
+)
+
+// The delta packets carry the frames of a stream, a keyframe is the whole
+// frame, and a delta is the changes from the previous frame:
+//
+//	0x01 { 0x01: seq  0x02: h"frame" }
+//	0x02 { 0x01: seq  0x03 [ { 0x01: kind  0x02: h"path"  0x03: h"packet" } ] }
+//
+// seq is the uint32 sequence number of the frame, kind is the int32 of
+// ChangeKind, and the steps of path are PVarUInt32s, the SeqID of a child of
+// node, or 0x40 plus the index of an element of slice.
+const (
+	deltaKeyframeSeqID byte = 0x01
+	deltaChangesSeqID  byte = 0x02
+
+	deltaSeqSeqID     byte = 0x01
+	deltaFrameSeqID   byte = 0x02
+	deltaListSeqID    byte = 0x03
+	deltaKindSeqID    byte = 0x01
+	deltaPathSeqID    byte = 0x02
+	deltaPacketSeqID  byte = 0x03
+	deltaIndexStepBit      = 0x40
+)
+
+// ErrDeltaOutOfSync means a delta doesn't follow the frame decoded before, the
+// deltas are dropped until the next keyframe
+var ErrDeltaOutOfSync = errors.New("y3: delta is out of sync, waiting for keyframe")
+
+// DeltaEncoder encodes the frames of a stream to delta packets, which only
+// carry the changed packets from the previous frame, see DeltaDecoder
+type DeltaEncoder struct {
+	// KeyframeInterval is the max count of deltas between keyframes, 0 means
+	// keyframes are only sent if needed
+	KeyframeInterval int
+
+	prev   []byte
+	seq    uint32
+	deltas int
+}
+
+// NewDeltaEncoder returns a DeltaEncoder sending a keyframe every
+// keyframeInterval deltas
+func NewDeltaEncoder(keyframeInterval int) *DeltaEncoder {
+	return &DeltaEncoder{KeyframeInterval: keyframeInterval}
+}
+
+// Reset makes the next frame a keyframe, e.g. when the decoder is out of sync
+func (e *DeltaEncoder) Reset() {
+	e.prev = nil
+}
+
+// Encode returns the delta packet of frame, which is a keyframe if it's the
+// first frame, the interval is reached, or the delta is larger than frame
+func (e *DeltaEncoder) Encode(frame []byte) ([]byte, error) {
+	if err := Validate(frame, nil); err != nil {
+		return nil, err
+	}
+	e.seq++
+	seq := NewPrimitivePacketEncoder(deltaSeqSeqID)
+	seq.SetUInt32Value(e.seq)
+
+	if e.prev != nil && (e.KeyframeInterval <= 0 || e.deltas < e.KeyframeInterval) {
+		delta := e.delta(seq, frame)
+		if len(delta) < len(frame) {
+			e.prev = append(e.prev[:0], frame...)
+			e.deltas++
+			return delta, nil
+		}
+	}
+
+	keyframe := NewNodePacketEncoder(deltaKeyframeSeqID)
+	keyframe.AddPrimitivePacket(seq)
+	f := NewPrimitivePacketEncoder(deltaFrameSeqID)
+	f.SetBytesValue(frame)
+	keyframe.AddPrimitivePacket(f)
+	e.prev = append(e.prev[:0], frame...)
+	e.deltas = 0
+	return keyframe.Encode(), nil
+}
+
+// delta returns the delta packet of the changes from the previous frame
+func (e *DeltaEncoder) delta(seq *PrimitivePacketEncoder, frame []byte) []byte {
+	a, _ := readRawPacket(e.prev, 0)
+	b, _ := readRawPacket(frame, 0)
+	var changes []Change
+	deltaDiff(&changes, Path{}, a, b)
+
+	list := NewNodeSlicePacketEncoder(deltaListSeqID)
+	for _, c := range changes {
+		entry := NewNodePacketEncoder(SliceElementSeqID)
+		kind := NewPrimitivePacketEncoder(deltaKindSeqID)
+		kind.SetInt32Value(int32(c.Kind))
+		entry.AddPrimitivePacket(kind)
+		path := NewPrimitivePacketEncoder(deltaPathSeqID)
+		path.SetBytesValue(encodeDeltaPath(c.Path))
+		entry.AddPrimitivePacket(path)
+		if c.Kind != Removed {
+			packet := NewPrimitivePacketEncoder(deltaPacketSeqID)
+			packet.SetBytesValue(c.New)
+			entry.AddPrimitivePacket(packet)
+		}
+		list.AddNodePacket(entry)
+	}
+	delta := NewNodePacketEncoder(deltaChangesSeqID)
+	delta.AddPrimitivePacket(seq)
+	delta.AddNodePacket(list)
+	return delta.Encode()
+}
+
+// deltaDiff appends the changes from a to b which can be applied by Patch to
+// get exactly b. Unlike Diff, the children of nodes are aligned by order, a
+// node is Changed as a whole if its children are reordered, inserted, or
+// have duplicated SeqIDs, but the children can be removed or appended.
+func deltaDiff(changes *[]Change, path Path, a, b *rawPacket) {
+	changed := Change{Kind: Changed, Path: path, Old: a.bytes(), New: b.bytes()}
+	if bytes.Equal(a.bytes(), b.bytes()) {
+		return
+	}
+	if a.header[0] != b.header[0] || !a.tag.IsNode() {
+		*changes = append(*changes, changed)
+		return
+	}
+	// both are validated
+	childrenA, _ := rawChildren(a)
+	childrenB, _ := rawChildren(b)
+
+	if a.tag.IsSlice() {
+		for i := 0; i < len(childrenA) || i < len(childrenB); i++ {
+			switch {
+			case i >= len(childrenA):
+				*changes = append(*changes, Change{Kind: Added, Path: path.Elem(i), New: childrenB[i].bytes()})
+			case i >= len(childrenB):
+				*changes = append(*changes, Change{Kind: Removed, Path: path.Elem(i), Old: childrenA[i].bytes()})
+			default:
+				deltaDiff(changes, path.Elem(i), childrenA[i], childrenB[i])
+			}
+		}
+		return
+	}
+
+	indexA, ok := uniqueSeqIDs(childrenA)
+	if !ok {
+		*changes = append(*changes, changed)
+		return
+	}
+	indexB, ok := uniqueSeqIDs(childrenB)
+	if !ok {
+		*changes = append(*changes, changed)
+		return
+	}
+	// the kept children should be in the same order, followed by the new ones
+	next, appending := 0, false
+	for _, c := range childrenB {
+		i, kept := indexA[c.tag.SeqID()]
+		if !kept {
+			appending = true
+			continue
+		}
+		if appending || i < next {
+			*changes = append(*changes, changed)
+			return
+		}
+		next = i + 1
+	}
+
+	for _, c := range childrenA {
+		if j, ok := indexB[c.tag.SeqID()]; ok {
+			deltaDiff(changes, path.Child(c.tag.SeqID()), c, childrenB[j])
+		} else {
+			*changes = append(*changes, Change{Kind: Removed, Path: path.Child(c.tag.SeqID()), Old: c.bytes()})
+		}
+	}
+	for _, c := range childrenB {
+		if _, ok := indexA[c.tag.SeqID()]; !ok {
+			*changes = append(*changes, Change{Kind: Added, Path: path.Child(c.tag.SeqID()), New: c.bytes()})
+		}
+	}
+}
+
+// uniqueSeqIDs returns the indexes of children by SeqID, false if there are
+// duplicated SeqIDs
+func uniqueSeqIDs(children []*rawPacket) (map[byte]int, bool) {
+	m := make(map[byte]int, len(children))
+	for i, c := range children {
+		if _, ok := m[c.tag.SeqID()]; ok {
+			return nil, false
+		}
+		m[c.tag.SeqID()] = i
+	}
+	return m, true
+}
+
+func encodeDeltaPath(path Path) []byte {
+	var buf []byte
+	for _, step := range path {
+		v := uint32(step.SeqID)
+		if step.IsIndex {
+			v = deltaIndexStepBit + uint32(step.Index)
+		}
+		size := encoding.SizeOfPVarUInt32(v)
+		codec := encoding.VarCodec{Size: size}
+		n := len(buf)
+		buf = append(buf, make([]byte, size)...)
+		// the size is computed, encoding never fails
+		_ = codec.EncodePVarUInt32(buf[n:], v)
+	}
+	return buf
+}
+
+func decodeDeltaPath(buf []byte) (Path, error) {
+	path := Path{}
+	codec := encoding.VarCodec{}
+	for codec.Ptr < len(buf) {
+		var v uint32
+		codec.Size = 0
+		if err := codec.DecodePVarUInt32(buf, &v); err != nil {
+			return nil, err
+		}
+		if v < deltaIndexStepBit {
+			path = append(path, PathStep{SeqID: byte(v)})
+		} else {
+			path = append(path, PathStep{Index: int(v - deltaIndexStepBit), IsIndex: true})
+		}
+	}
+	return path, nil
+}
+
+// DeltaDecoder decodes the delta packets of a stream to frames, see DeltaEncoder
+type DeltaDecoder struct {
+	prev []byte
+	seq  uint32
+}
+
+// NewDeltaDecoder returns a DeltaDecoder waiting for the first keyframe
+func NewDeltaDecoder() *DeltaDecoder {
+	return &DeltaDecoder{}
+}
+
+// Decode returns the frame of the delta packet, it returns ErrDeltaOutOfSync
+// if a delta doesn't follow the previous frame, e.g. the packets are lost
+func (d *DeltaDecoder) Decode(packet []byte) ([]byte, error) {
+	var np NodePacket
+	if _, err := DecodeToNodePacket(packet, &np); err != nil {
+		return nil, err
+	}
+	seqPacket, err := deltaField(&np, deltaSeqSeqID)
+	if err != nil {
+		return nil, err
+	}
+	seq, err := seqPacket.ToUInt32()
+	if err != nil {
+		return nil, err
+	}
+
+	switch np.SeqID() {
+	case deltaKeyframeSeqID:
+		fp, err := deltaField(&np, deltaFrameSeqID)
+		if err != nil {
+			return nil, err
+		}
+		frame := fp.ToBytes()
+		if err := Validate(frame, nil); err != nil {
+			return nil, err
+		}
+		d.prev = append([]byte{}, frame...)
+		d.seq = seq
+		return append([]byte{}, frame...), nil
+	case deltaChangesSeqID:
+	default:
+		return nil, fmt.Errorf("y3: invalid delta packet 0x%02X", np.SeqID())
+	}
+
+	if d.prev == nil || seq != d.seq+1 {
+		d.prev = nil
+		return nil, ErrDeltaOutOfSync
+	}
+	list, ok := np.NodePackets[deltaListSeqID]
+	if !ok {
+		return nil, errors.New("y3: delta without changes")
+	}
+	changes, err := DecodeNodeSlice(&list, func(entry *NodePacket) (Change, error) {
+		var c Change
+		kind, err := deltaField(entry, deltaKindSeqID)
+		if err != nil {
+			return c, err
+		}
+		k, err := kind.ToInt32()
+		if err != nil {
+			return c, err
+		}
+		c.Kind = ChangeKind(k)
+		path, err := deltaField(entry, deltaPathSeqID)
+		if err != nil {
+			return c, err
+		}
+		if c.Path, err = decodeDeltaPath(path.ToBytes()); err != nil {
+			return c, err
+		}
+		if p, ok := entry.PrimitivePackets[deltaPacketSeqID]; ok {
+			c.New = p.ToBytes()
+		}
+		return c, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("y3: invalid delta: %v", err)
+	}
+	frame, err := Patch(d.prev, changes...)
+	if err != nil {
+		d.prev = nil
+		return nil, err
+	}
+	d.prev = frame
+	d.seq = seq
+	return append([]byte{}, frame...), nil
+}
+
+// deltaField returns the primitive child of the delta packet
+func deltaField(np *NodePacket, sid byte) (*PrimitivePacket, error) {
+	p, ok := np.PrimitivePackets[sid]
+	if !ok {
+		return nil, fmt.Errorf("y3: missing field 0x%02X of delta packet 0x%02X", sid, np.SeqID())
+	}
+	return &p, nil
+}
@@ -0,0 +1,196 @@
+package y3
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// telemetryFrame returns the i-th frame of a device, most of the fields are
+// unchanged from the previous frame
+func telemetryFrame(r *rand.Rand, i int) []byte {
+	root := NewNodePacketEncoder(0x3F)
+	id := NewPrimitivePacketEncoder(0x01)
+	id.SetStringValue("device-1")
+	root.AddPrimitivePacket(id)
+	seq := NewPrimitivePacketEncoder(0x02)
+	seq.SetInt64Value(int64(i))
+	root.AddPrimitivePacket(seq)
+
+	sensors := NewNodePacketEncoder(0x03)
+	for sid := byte(1); sid <= 0x20; sid++ {
+		v := NewPrimitivePacketEncoder(sid)
+		v.SetFloat64Value(float64(sid) + 0.1)
+		if int(sid) == i%0x20+1 {
+			v.SetFloat64Value(r.Float64())
+		}
+		sensors.AddPrimitivePacket(v)
+	}
+	root.AddNodePacket(sensors)
+
+	if r.Intn(10) == 0 {
+		alert := NewPrimitivePacketEncoder(0x04)
+		alert.SetStringValue("overheat")
+		root.AddPrimitivePacket(alert)
+	}
+	tags := NewNodeSlicePacketEncoder(0x05)
+	for j := 0; j < i/10%3; j++ {
+		tag := NewPrimitivePacketEncoder(SliceElementSeqID)
+		tag.SetStringValue(fmt.Sprint("tag", j))
+		tags.AddPrimitivePacket(tag)
+	}
+	root.AddNodePacket(tags)
+
+	// the elements are reordered, or have duplicated SeqIDs from time to time
+	items := NewNodeSlicePacketEncoder(0x06)
+	item := NewNodePacketEncoder(SliceElementSeqID)
+	sids := []byte{0x01, 0x02}
+	switch r.Intn(8) {
+	case 0:
+		sids = []byte{0x02, 0x01}
+	case 1:
+		sids = []byte{0x01, 0x02, 0x01}
+	}
+	for _, sid := range sids {
+		v := NewPrimitivePacketEncoder(sid)
+		v.SetInt64Value(int64(i / 5))
+		item.AddPrimitivePacket(v)
+	}
+	items.AddNodePacket(item)
+	root.AddNodePacket(items)
+	if r.Intn(20) == 0 {
+		// a child before the others makes the root changed as a whole
+		note := NewPrimitivePacketEncoder(0x00)
+		note.SetStringValue("note")
+		inserted := NewNodePacketEncoder(0x3F)
+		inserted.AddPrimitivePacket(note)
+		inserted.AddBytes(root.valbuf)
+		return inserted.Encode()
+	}
+	return root.Encode()
+}
+
+func TestDelta(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	enc := NewDeltaEncoder(20)
+	dec := NewDeltaDecoder()
+	var keyframes, size, deltaSize int
+	for i := 0; i < 200; i++ {
+		frame := telemetryFrame(r, i)
+		packet, err := enc.Encode(frame)
+		assert.NoError(t, err)
+		if packet[0] == 0x80|deltaKeyframeSeqID {
+			keyframes++
+		}
+		size += len(frame)
+		deltaSize += len(packet)
+
+		res, err := dec.Decode(packet)
+		assert.NoError(t, err)
+		assert.Equal(t, frame, res, "frame %d", i)
+	}
+	assert.GreaterOrEqual(t, keyframes, 10)
+	assert.Less(t, deltaSize, size)
+}
+
+func TestDeltaDiff(t *testing.T) {
+	frames := []string{
+		`0x3F { 0x01: "device-1" 0x02: 1 0x03 { 0x01: 1 0x02: 2 } 0x05 ["a" "b"] }`,
+		`0x3F { 0x01: "device-1" 0x02: 2 0x03 { 0x01: 1 0x02: 3 } 0x05 ["a" "b" "c"] }`,
+		`0x3F { 0x01: "device-1" 0x02: 3 0x03 { 0x02: 3 0x04: 4 } 0x05 ["a"] 0x06: null }`,
+		`0x3F { 0x01: "device-1" 0x03 { 0x04: 4 0x02: 3 } 0x02: 4 0x05 ["a"] 0x06: null }`,
+		`0x3F { 0x00: 0 0x01: "device-1" 0x03 { 0x04: 4 0x02: 3 } 0x02: 4 0x05 ["a"] 0x06: null }`,
+		`0x3F { 0x00: 0 0x00: 1 0x01: "device-1" 0x03 { 0x04: 4 0x02: 3 } 0x02: 4 0x05 ["a"] 0x06: null }`,
+		`0x3F { 0x00: 0 0x00: 1 0x01: "device-1" 0x03 { 0x04: 5 0x02: 3 } 0x02: 4 0x05 ["a"] 0x06: null }`,
+		`0x3F { 0x05 [ { 0x01: 1 0x02: 2 } ] }`,
+		`0x3F { 0x05 [ { 0x02: 2 0x01: 1 } ] }`,
+		`0x3F { 0x05 [ { 0x02: 2 0x01: 1 0x01: 3 } ] }`,
+		`0x3F { 0x05 [ { 0x02: 3 0x01: 1 0x01: 3 } ] }`,
+	}
+	var changes [][]string
+	for i := 1; i < len(frames); i++ {
+		a, err := ParseText(frames[i-1])
+		assert.NoError(t, err)
+		b, err := ParseText(frames[i])
+		assert.NoError(t, err)
+		pa, _ := readRawPacket(a, 0)
+		pb, _ := readRawPacket(b, 0)
+		var cs []Change
+		deltaDiff(&cs, Path{}, pa, pb)
+		res, err := Patch(a, cs...)
+		assert.NoError(t, err)
+		assert.Equal(t, b, res, frames[i])
+
+		var paths []string
+		for _, c := range cs {
+			paths = append(paths, c.Kind.String()+" "+c.Path.String())
+		}
+		changes = append(changes, paths)
+	}
+	assert.Equal(t, [][]string{
+		{"changed 0x02", "changed 0x03.0x02", "added 0x05[2]"},
+		{"changed 0x02", "removed 0x03.0x01", "added 0x03.0x04", "removed 0x05[1]", "removed 0x05[2]", "added 0x06"},
+		// reordered
+		{"changed ."},
+		// inserted
+		{"changed ."},
+		// duplicated SeqIDs are added
+		{"changed ."},
+		// the root is changed as a whole since it has duplicated SeqIDs
+		{"changed ."},
+		{"changed ."},
+		// the elements of slice are compared in order too
+		{"changed 0x05[0]"},
+		{"changed 0x05[0]"},
+		{"changed 0x05[0]"},
+	}, changes)
+}
+
+func TestDeltaOutOfSync(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	enc := NewDeltaEncoder(0)
+	dec := NewDeltaDecoder()
+
+	first, err := enc.Encode(telemetryFrame(r, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, 0x80|deltaKeyframeSeqID, first[0])
+	// the first packet is lost
+	second, err := enc.Encode(telemetryFrame(r, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, 0x80|deltaChangesSeqID, second[0])
+	_, err = dec.Decode(second)
+	assert.Equal(t, ErrDeltaOutOfSync, err)
+
+	_, err = dec.Decode(first)
+	assert.NoError(t, err)
+	third, err := enc.Encode(telemetryFrame(r, 2))
+	assert.NoError(t, err)
+	// the second packet is lost
+	_, err = dec.Decode(third)
+	assert.Equal(t, ErrDeltaOutOfSync, err)
+
+	enc.Reset()
+	frame := telemetryFrame(r, 3)
+	keyframe, err := enc.Encode(frame)
+	assert.NoError(t, err)
+	res, err := dec.Decode(keyframe)
+	assert.NoError(t, err)
+	assert.Equal(t, frame, res)
+
+	_, err = dec.Decode([]byte{0x83, 0x00})
+	if assert.Error(t, err) {
+		assert.Equal(t, "y3: missing field 0x01 of delta packet 0x03", err.Error())
+	}
+	_, err = enc.Encode([]byte{0x81})
+	assert.Error(t, err)
+}
+
+func TestDeltaPath(t *testing.T) {
+	for _, p := range []Path{{}, NewPath(0x3F, 0x00), NewPath(0x05).Elem(0).Elem(1000).Child(0x01)} {
+		res, err := decodeDeltaPath(encodeDeltaPath(p))
+		assert.NoError(t, err)
+		assert.Equal(t, p, res)
+	}
+}
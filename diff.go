@@ -0,0 +1,201 @@
+package y3
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeKind describes how a packet differs in Diff
+type ChangeKind int
+
+const (
+	// Added means the packet only exists in the new one
+	Added ChangeKind = iota + 1
+	// Removed means the packet only exists in the old one
+	Removed
+	// Changed means the packet exists in both, with different Val or types
+	Changed
+)
+
+var changeKindNames = map[ChangeKind]string{
+	Added:   "added",
+	Removed: "removed",
+	Changed: "changed",
+}
+
+// String returns the name of kind
+func (k ChangeKind) String() string {
+	if name, ok := changeKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Change is a difference found by Diff
+type Change struct {
+	Kind ChangeKind
+	Path Path
+	// Old is the encoded packet in the old one, nil if Added
+	Old []byte
+	// New is the encoded packet in the new one, nil if Removed
+	New []byte
+}
+
+// String returns the change in one line, it's like `+ 0x2F.0x03: "new"` if
+// Added, `- 0x05[2]: 1` if Removed, or `~ 0x2F.0x01: "yomo" -> "y3"` if
+// Changed
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ %s: %s", c.Path, packetValueText(c.New))
+	case Removed:
+		return fmt.Sprintf("- %s: %s", c.Path, packetValueText(c.Old))
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Path, packetValueText(c.Old), packetValueText(c.New))
+}
+
+// FormatDiff returns the changes one per line, "" if there are no changes
+func FormatDiff(changes []Change) string {
+	var sb strings.Builder
+	for _, c := range changes {
+		sb.WriteString(c.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Diff compares the packets a and b by their trees. The children of nodes are
+// aligned by SeqID regardless of their order, the packets with the same SeqID
+// are aligned by their order and located by their occurrences, e.g.
+// "0x01#1", and the elements of slices are aligned by index.
+// A packet of different types, or can't be decoded, is compared as a whole,
+// and the root is Added or Removed if a or b is empty. The changes are
+// ordered by path, it's nil if a and b are equal.
+func Diff(a, b []byte) []Change {
+	var changes []Change
+	pa, errA := readRawPacket(a, 0)
+	pb, errB := readRawPacket(b, 0)
+	switch {
+	case len(a) == 0 && errB == nil:
+		return append(changes, Change{Kind: Added, Path: Path{}, New: pb.bytes()})
+	case len(b) == 0 && errA == nil:
+		return append(changes, Change{Kind: Removed, Path: Path{}, Old: pa.bytes()})
+	case errA != nil || errB != nil:
+		if !bytes.Equal(a, b) {
+			changes = append(changes, Change{Kind: Changed, Path: Path{}, Old: a, New: b})
+		}
+		return changes
+	}
+	diffPacket(&changes, Path{}, pa, pb)
+	return changes
+}
+
+func diffPacket(changes *[]Change, path Path, a, b *rawPacket) {
+	changed := Change{Kind: Changed, Path: path, Old: a.bytes(), New: b.bytes()}
+	if a.header[0] != b.header[0] {
+		*changes = append(*changes, changed)
+		return
+	}
+	if !a.tag.IsNode() {
+		if !bytes.Equal(a.val, b.val) {
+			*changes = append(*changes, changed)
+		}
+		return
+	}
+
+	childrenA, errA := rawChildren(a)
+	childrenB, errB := rawChildren(b)
+	if errA != nil || errB != nil {
+		if !bytes.Equal(a.val, b.val) {
+			*changes = append(*changes, changed)
+		}
+		return
+	}
+
+	if a.tag.IsSlice() {
+		for i := 0; i < len(childrenA) || i < len(childrenB); i++ {
+			diffChild(changes, path.Elem(i), childrenA, childrenB, i, i)
+		}
+		return
+	}
+
+	// the children with the same SeqID are aligned by order
+	indexes := func(children []*rawPacket) map[byte][]int {
+		m := map[byte][]int{}
+		for i, c := range children {
+			m[c.tag.SeqID()] = append(m[c.tag.SeqID()], i)
+		}
+		return m
+	}
+	indexA, indexB := indexes(childrenA), indexes(childrenB)
+	var sids []byte
+	for sid := range indexA {
+		sids = append(sids, sid)
+	}
+	for sid := range indexB {
+		if _, ok := indexA[sid]; !ok {
+			sids = append(sids, sid)
+		}
+	}
+	sort.Slice(sids, func(i, j int) bool { return sids[i] < sids[j] })
+	for _, sid := range sids {
+		ia, ib := indexA[sid], indexB[sid]
+		for k := 0; k < len(ia) || k < len(ib); k++ {
+			i, j := -1, -1
+			if k < len(ia) {
+				i = ia[k]
+			}
+			if k < len(ib) {
+				j = ib[k]
+			}
+			diffChild(changes, path.ChildAt(sid, k), childrenA, childrenB, i, j)
+		}
+	}
+}
+
+// diffChild compares childrenA[i] and childrenB[j], the index out of range
+// means the child doesn't exist
+func diffChild(changes *[]Change, path Path, childrenA, childrenB []*rawPacket, i, j int) {
+	switch {
+	case i < 0 || i >= len(childrenA):
+		*changes = append(*changes, Change{Kind: Added, Path: path, New: childrenB[j].bytes()})
+	case j < 0 || j >= len(childrenB):
+		*changes = append(*changes, Change{Kind: Removed, Path: path, Old: childrenA[i].bytes()})
+	default:
+		diffPacket(changes, path, childrenA[i], childrenB[j])
+	}
+}
+
+// rawChildren returns the packets in the Val of node p
+func rawChildren(p *rawPacket) ([]*rawPacket, error) {
+	var children []*rawPacket
+	err := eachRawPacket(p.val, p.off+len(p.header), func(child *rawPacket) error {
+		children = append(children, child)
+		return nil
+	})
+	return children, err
+}
+
+// bytes returns the encoded packet, the header and Val are adjacent in buf
+func (p *rawPacket) bytes() []byte {
+	return p.header[:p.size():p.size()]
+}
+
+// packetValueText returns the text of the value of the encoded packet: a
+// primitive is its value, a node is its children in braces like %v of
+// NodePacket, or the hex of buf if it's malformed
+func packetValueText(buf []byte) string {
+	p, err := readRawPacket(buf, 0)
+	if err == nil && len(buf) == p.size() {
+		var w bytes.Buffer
+		if err = writeTree(&w, buf, &treeOptions{}); err == nil {
+			s := w.String()
+			// strip the SeqID
+			s = strings.TrimPrefix(s[len("0x00"):], ":")
+			return s
+		}
+	}
+	return fmt.Sprintf("h\"%X\"", buf)
+}
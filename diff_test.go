@@ -0,0 +1,61 @@
+package y3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	a, err := ParseText(`0x3F {
+		0x2F { 0x01: "yomo" 0x02: 1 0x03: "x" }
+		0x05 [1 2 3]
+		0x06: h"01"
+		0x07: null
+	}`)
+	assert.NoError(t, err)
+	b, err := ParseText(`0x3F {
+		0x07: "v"
+		0x05 [1 4]
+		0x2F { 0x02: 1 0x01: "y3" 0x04 { 0x01: true } }
+		0x08 [{ 0x01: 1 }]
+		0x06 { }
+	}`)
+	assert.NoError(t, err)
+
+	changes := Diff(a, b)
+	assert.Equal(t, `~ 0x05[1]: h"02" -> h"04"
+- 0x05[2]: h"03"
+~ 0x06: h"01" -> {}
+~ 0x07: null -> "v"
++ 0x08: [{0x01:h"01"}]
+~ 0x2F.0x01: "yomo" -> "y3"
+- 0x2F.0x03: "x"
++ 0x2F.0x04: {0x01:h"01"}
+`, FormatDiff(changes))
+	assert.Equal(t, Changed, changes[5].Kind)
+	assert.Equal(t, NewPath(0x2F, 0x01), changes[5].Path)
+	assert.Equal(t, []byte{0x01, 0x04, 'y', 'o', 'm', 'o'}, changes[5].Old)
+	assert.Equal(t, []byte{0x01, 0x02, 'y', '3'}, changes[5].New)
+	assert.Equal(t, "removed", changes[1].Kind.String())
+	assert.Nil(t, changes[1].New)
+
+	// the order of children doesn't matter
+	c, err := ParseText(`0x3F { 0x06: h"01" 0x07: null 0x05 [1 2 3] 0x2F { 0x03: "x" 0x02: 1 0x01: "yomo" } }`)
+	assert.NoError(t, err)
+	assert.Nil(t, Diff(a, c))
+	assert.Equal(t, "", FormatDiff(Diff(a, a)))
+}
+
+func TestDiffRoot(t *testing.T) {
+	a := []byte{0x01, 0x01, 0x61}
+	assert.Equal(t, "~ .: \"a\" -> {}\n", FormatDiff(Diff(a, []byte{0x81, 0x00})))
+	assert.Equal(t, "+ .: \"a\"\n", FormatDiff(Diff(nil, a)))
+	assert.Equal(t, "- .: \"a\"\n", FormatDiff(Diff(a, nil)))
+	assert.Nil(t, Diff(nil, nil))
+
+	// malformed packets are compared as bytes
+	assert.Equal(t, "~ .: h\"0102\" -> \"a\"\n", FormatDiff(Diff([]byte{0x01, 0x02}, a)))
+	assert.Equal(t, "~ .: h\"81028102\" -> h\"81028101\"\n", FormatDiff(Diff([]byte{0x81, 0x02, 0x81, 0x02}, []byte{0x81, 0x02, 0x81, 0x01})))
+	assert.Nil(t, Diff([]byte{0x81, 0x02, 0x81, 0x02}, []byte{0x81, 0x02, 0x81, 0x02}))
+}
@@ -0,0 +1,105 @@
+package y3
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dumpValWidth is the max number of Val bytes in a line of Dump
+const dumpValWidth = 16
+
+// DumpOptions customizes DumpWith
+type DumpOptions struct {
+	// Annotate returns the comment of the packet, path is the SeqIDs from the
+	// root packet to the packet, val is nil for node packets. The default
+	// comment is used if it returns "", it's not called for null.
+	Annotate func(path []byte, isNode bool, val []byte) string
+	// Value returns the text of primitives in the default comments, e.g. the
+	// symbolic names of enums, it's optional
+	Value ValueFunc
+}
+
+// dumpLine is a packet in the dump
+type dumpLine struct {
+	p       *rawPacket
+	depth   int
+	comment string
+}
+
+// Dump returns the hex dump of the packets in buf, every line is a packet
+// indented by its depth, the Tag and Length bytes are separated from the Val
+// bytes by '|', e.g.
+//
+//	000000  81 06   |                                                  # 0x01 node
+//	000002    02 04 | 79 6F 6D 6F                                      # 0x02 "yomo"
+//
+// The error is appended as the last line if buf is malformed.
+func Dump(buf []byte) string {
+	return DumpWith(buf, nil)
+}
+
+// DumpWith is like Dump with the options, opts can be nil
+func DumpWith(buf []byte, opts *DumpOptions) string {
+	var lines []dumpLine
+	err := walkTree(buf, 0, func(p *rawPacket, path []byte, _ bool) error {
+		line := dumpLine{p: p, depth: len(path) - 1}
+		if opts != nil && opts.Annotate != nil && !p.isNull() {
+			var val []byte
+			if !p.tag.IsNode() {
+				val = p.val
+			}
+			line.comment = opts.Annotate(path, p.tag.IsNode(), val)
+		}
+		if line.comment == "" {
+			var value ValueFunc
+			if opts != nil {
+				value = opts.Value
+			}
+			line.comment = dumpComment(p, path, value)
+		}
+		lines = append(lines, line)
+		return nil
+	}, nil)
+
+	width := 0
+	for _, l := range lines {
+		if w := 2*l.depth + 3*len(l.p.header) - 1; w > width {
+			width = w
+		}
+	}
+	var sb strings.Builder
+	for _, l := range lines {
+		left := strings.Repeat(" ", 2*l.depth) + fmt.Sprintf("% X", l.p.header)
+		var val []byte
+		if !l.p.tag.IsNode() {
+			val = l.p.val
+		}
+		for i := 0; i == 0 || i < len(val); i += dumpValWidth {
+			end := i + dumpValWidth
+			if end > len(val) {
+				end = len(val)
+			}
+			chunk := val[i:end]
+			if i == 0 {
+				fmt.Fprintf(&sb, "%06X  %-*s | %-*s  # %s\n", l.p.off, width, left, 3*dumpValWidth-1, fmt.Sprintf("% X", chunk), l.comment)
+			} else {
+				fmt.Fprintf(&sb, "%06X  %-*s | % X\n", l.p.off+len(l.p.header)+i, width, "", chunk)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(&sb, "error: %v\n", err)
+	}
+	return sb.String()
+}
+
+// dumpComment returns the default comment of the packet at path in Dump
+func dumpComment(p *rawPacket, path []byte, value ValueFunc) string {
+	switch {
+	case p.tag.IsNode() && p.tag.IsSlice():
+		return fmt.Sprintf("0x%02X slice", p.tag.SeqID())
+	case p.tag.IsNode():
+		return fmt.Sprintf("0x%02X node", p.tag.SeqID())
+	}
+	return fmt.Sprintf("0x%02X %s", p.tag.SeqID(), valueText(p, path, value))
+}
@@ -0,0 +1,62 @@
+package y3
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDump(t *testing.T) {
+	buf, err := ParseText(`0x3F { 0x2F { 0x01: "yomo" 0x03: null } 0x05 [1] 0x2E: h"0102030405060708090A0B0C0D0E0F1011" }`)
+	assert.NoError(t, err)
+	assert.Equal(t, `000000  BF 22     |                                                  # 0x3F node
+000002    AF 08   |                                                  # 0x2F node
+000004      01 04 | 79 6F 6D 6F                                      # 0x01 "yomo"
+00000A      43 00 |                                                  # 0x03 null
+00000C    C5 03   |                                                  # 0x05 slice
+00000E      00 01 | 01                                               # 0x00 h"01"
+000011    2E 11   | 01 02 03 04 05 06 07 08 09 0A 0B 0C 0D 0E 0F 10  # 0x2E h"0102030405060708090A0B0C0D0E0F1011"
+000023            | 11
+`, Dump(buf))
+
+	// the error is appended
+	assert.Equal(t, `000000  81 03 |                                                  # 0x01 node
+error: offset 2: length 4 exceeds the 1 bytes left
+`, Dump([]byte{0x81, 0x03, 0x02, 0x04, 0x61}))
+	assert.Equal(t, "", Dump(nil))
+}
+
+func TestDumpWith(t *testing.T) {
+	buf, err := ParseText(`0x01 { 0x02: "a" 0x03: null }`)
+	assert.NoError(t, err)
+	var paths []string
+	res := DumpWith(buf, &DumpOptions{
+		Annotate: func(path []byte, isNode bool, val []byte) string {
+			paths = append(paths, fmt.Sprintf("% X", path))
+			if isNode {
+				return ""
+			}
+			return fmt.Sprintf("name = %q", val)
+		},
+	})
+	assert.Equal(t, []string{"01", "01 02"}, paths)
+	assert.Equal(t, `000000  81 05   |                                                  # 0x01 node
+000002    02 01 | 61                                               # name = "a"
+000005    43 00 |                                                  # 0x03 null
+`, res)
+}
+
+func TestDumpValue(t *testing.T) {
+	buf, err := ParseText(`0x01 { 0x02: 1 0x03: null }`)
+	assert.NoError(t, err)
+	res := DumpWith(buf, &DumpOptions{
+		Value: func(path []byte, val []byte) string {
+			return "OK"
+		},
+	})
+	assert.Equal(t, `000000  81 05   |                                                  # 0x01 node
+000002    02 01 | 01                                               # 0x02 OK
+000005    43 00 |                                                  # 0x03 null
+`, res)
+}
@@ -30,13 +30,26 @@ func (enc *encoder) IsEmpty() bool {
 }
 
 func (enc *encoder) AddBytes(buf []byte) {
+	enc.reset()
 	enc.valbuf = append(enc.valbuf, buf...)
 }
 
 func (enc *encoder) addRawPacket(en iEncoder) {
+	enc.reset()
 	enc.valbuf = append(enc.valbuf, en.Encode()...)
 }
 
+// reset makes the encoder encode again after Val is changed, the bytes
+// returned by Encode before are kept unchanged
+func (enc *encoder) reset() {
+	if !enc.complete {
+		return
+	}
+	enc.seqID &= 0x3F
+	enc.buf = new(bytes.Buffer)
+	enc.complete = false
+}
+
 // setTag write tag as seqID
 func (enc *encoder) writeTag() {
 	if enc.seqID > 0x3F {
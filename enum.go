@@ -0,0 +1,160 @@
+package y3
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ErrUnknownEnumValue is returned when a closed enum decodes a value without name
+var ErrUnknownEnumValue = errors.New("unknown enum value")
+
+// Enum is the table of the symbolic names of an int32 type, an enum is encoded
+// as an int32 primitive packet. When decoding, a closed enum rejects the values
+// not in the table, and an open enum preserves them.
+type Enum struct {
+	name   string
+	closed bool
+	names  map[int32]string
+	values map[string]int32
+}
+
+var (
+	enumsByName sync.Map // map[string]*Enum
+	enumsByType sync.Map // map[reflect.Type]*Enum
+)
+
+// RegisterEnum registers an open enum of type T with the name, e.g.
+//
+//	type Status int32
+//
+//	var StatusEnum = y3.RegisterEnum("Status", map[string]Status{
+//		"OK":        0,
+//		"NOT_FOUND": 404,
+//	})
+//
+// The fields of type T are validated by Marshal and Unmarshal. If T is int32
+// itself, the enum is only referenced by the tag option of int32 fields, e.g.
+// `y3:"0x02,enum=Status"`.
+//
+// RegisterEnum panics if the name or the type is already registered, or two
+// names have the same value.
+func RegisterEnum[T ~int32](name string, values map[string]T) *Enum {
+	return registerEnum(name, false, values)
+}
+
+// RegisterClosedEnum registers a closed enum of type T, see RegisterEnum
+func RegisterClosedEnum[T ~int32](name string, values map[string]T) *Enum {
+	return registerEnum(name, true, values)
+}
+
+func registerEnum[T ~int32](name string, closed bool, values map[string]T) *Enum {
+	e := &Enum{name: name, closed: closed, names: map[int32]string{}, values: map[string]int32{}}
+	for n, v := range values {
+		if prev, ok := e.names[int32(v)]; ok {
+			panic(fmt.Errorf("y3: %s and %s of enum %s have the same value %d", prev, n, name, v))
+		}
+		e.names[int32(v)] = n
+		e.values[n] = int32(v)
+	}
+
+	if _, loaded := enumsByName.LoadOrStore(name, e); loaded {
+		panic(fmt.Errorf("y3: enum %s is already registered", name))
+	}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t == reflect.TypeOf(int32(0)) {
+		return e
+	}
+	if _, loaded := enumsByType.LoadOrStore(t, e); loaded {
+		panic(fmt.Errorf("y3: enum of %v is already registered", t))
+	}
+	return e
+}
+
+// LookupEnum returns the enum registered with the name, nil if not exists
+func LookupEnum(name string) *Enum {
+	if e, ok := enumsByName.Load(name); ok {
+		return e.(*Enum)
+	}
+	return nil
+}
+
+// EnumOf returns the enum registered with the type, nil if not exists
+func EnumOf(t reflect.Type) *Enum {
+	if e, ok := enumsByType.Load(t); ok {
+		return e.(*Enum)
+	}
+	return nil
+}
+
+// Name returns the name of the enum
+func (e *Enum) Name() string {
+	return e.name
+}
+
+// IsClosed returns true if the enum rejects unknown values
+func (e *Enum) IsClosed() bool {
+	return e.closed
+}
+
+// NameOf returns the symbolic name of the value
+func (e *Enum) NameOf(v int32) (string, bool) {
+	n, ok := e.names[v]
+	return n, ok
+}
+
+// ValueOf returns the value of the symbolic name
+func (e *Enum) ValueOf(name string) (int32, bool) {
+	v, ok := e.values[name]
+	return v, ok
+}
+
+// Values returns all the values in ascending order
+func (e *Enum) Values() []int32 {
+	res := make([]int32, 0, len(e.names))
+	for v := range e.names {
+		res = append(res, v)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+// String returns the symbolic name of the value, or the number if the value
+// has no name
+func (e *Enum) String(v int32) string {
+	if n, ok := e.names[v]; ok {
+		return n
+	}
+	return strconv.Itoa(int(v))
+}
+
+// Check returns ErrUnknownEnumValue if the enum is closed and the value has no name
+func (e *Enum) Check(v int32) error {
+	if _, ok := e.names[v]; !ok && e.closed {
+		return fmt.Errorf("%w %d of %s", ErrUnknownEnumValue, v, e.name)
+	}
+	return nil
+}
+
+// ToEnum decodes the primitive packet as the enum type T, the value is
+// checked if T is registered
+func ToEnum[T ~int32](p *PrimitivePacket) (T, error) {
+	v, err := p.ToInt32()
+	if err != nil {
+		return 0, err
+	}
+	if e := EnumOf(reflect.TypeOf(T(0))); e != nil {
+		if err := e.Check(v); err != nil {
+			return 0, fmt.Errorf("y3: %w", err)
+		}
+	}
+	return T(v), nil
+}
+
+// SetEnumValue encodes the enum value as int32
+func SetEnumValue[T ~int32](enc *PrimitivePacketEncoder, v T) {
+	enc.SetInt32Value(int32(v))
+}
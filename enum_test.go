@@ -0,0 +1,123 @@
+package y3
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testStatus int32
+
+type testColor int32
+
+var (
+	testStatusEnum = RegisterClosedEnum("testStatus", map[string]testStatus{
+		"OK":        0,
+		"NOT_FOUND": 404,
+	})
+	testColorEnum = RegisterEnum("testColor", map[string]testColor{
+		"RED":   1,
+		"GREEN": 2,
+	})
+	testLevelEnum = RegisterClosedEnum("testLogLevel", map[string]int32{
+		"DEBUG": 0,
+		"INFO":  1,
+	})
+)
+
+type testResponse struct {
+	Status  testStatus   `y3:"0x01"`
+	Color   testColor    `y3:"0x02"`
+	Level   int32        `y3:"0x03,enum=testLogLevel"`
+	History []testStatus `y3:"0x04"`
+}
+
+func TestEnum(t *testing.T) {
+	assert.Equal(t, "testStatus", testStatusEnum.Name())
+	assert.True(t, testStatusEnum.IsClosed())
+	assert.False(t, testColorEnum.IsClosed())
+	assert.Equal(t, "NOT_FOUND", testStatusEnum.String(404))
+	assert.Equal(t, "500", testStatusEnum.String(500))
+	v, ok := testStatusEnum.ValueOf("NOT_FOUND")
+	assert.True(t, ok)
+	assert.EqualValues(t, 404, v)
+	_, ok = testStatusEnum.NameOf(500)
+	assert.False(t, ok)
+	assert.Equal(t, []int32{0, 404}, testStatusEnum.Values())
+
+	assert.Equal(t, testStatusEnum, LookupEnum("testStatus"))
+	assert.Equal(t, testStatusEnum, EnumOf(reflect.TypeOf(testStatus(0))))
+	assert.Equal(t, testLevelEnum, LookupEnum("testLogLevel"))
+	// int32 is not registered as an enum type
+	assert.Nil(t, EnumOf(reflect.TypeOf(int32(0))))
+
+	assert.NoError(t, testColorEnum.Check(3))
+	assert.EqualError(t, testStatusEnum.Check(500), "unknown enum value 500 of testStatus")
+}
+
+func TestRegisterEnumPanics(t *testing.T) {
+	assert.Panics(t, func() { RegisterEnum("testStatus", map[string]int32{}) })
+	assert.Panics(t, func() { RegisterEnum("testStatus2", map[string]testStatus{}) })
+	assert.Panics(t, func() { RegisterEnum("testDup", map[string]int32{"A": 1, "B": 1}) })
+}
+
+func TestMarshalEnum(t *testing.T) {
+	resp := testResponse{Status: 404, Color: 3, Level: 1, History: []testStatus{0, 404}}
+	buf, err := Marshal(0x10, &resp)
+	assert.NoError(t, err)
+
+	var res testResponse
+	assert.NoError(t, Unmarshal(buf, &res))
+	// the unknown value of an open enum is preserved
+	assert.Equal(t, resp, res)
+
+	_, err = Marshal(0x10, &testResponse{Status: 500})
+	assert.ErrorIs(t, err, ErrUnknownEnumValue)
+	assert.EqualError(t, err, "y3: unknown enum value 500 of testStatus")
+
+	_, err = Marshal(0x10, &testResponse{Level: 2})
+	assert.EqualError(t, err, "y3: y3.testResponse.Level (0x3): unknown enum value 2 of testLogLevel")
+}
+
+func TestUnmarshalClosedEnum(t *testing.T) {
+	var res testResponse
+	// 0x90 { 0x01: 500 }
+	err := Unmarshal([]byte{0x90, 0x04, 0x01, 0x02, 0x01, 0xF4}, &res)
+	assert.ErrorIs(t, err, ErrUnknownEnumValue)
+
+	// 0x90 { 0x03: 5 }
+	err = Unmarshal([]byte{0x90, 0x03, 0x03, 0x01, 0x05}, &res)
+	var fe *FieldError
+	assert.ErrorAs(t, err, &fe)
+	assert.Equal(t, "Level", fe.Field)
+
+	// 0x90 { 0x84 [ 0x00: 7 ] }
+	err = Unmarshal([]byte{0x90, 0x05, 0xC4, 0x03, 0x00, 0x01, 0x07}, &res)
+	assert.ErrorIs(t, err, ErrUnknownEnumValue)
+
+	p := &PrimitivePacket{}
+	_, err = DecodeToPrimitivePacket([]byte{0x01, 0x02, 0x01, 0xF4}, p)
+	assert.NoError(t, err)
+	_, err = ToEnum[testStatus](p)
+	assert.ErrorIs(t, err, ErrUnknownEnumValue)
+	c, err := ToEnum[testColor](p)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 500, c)
+
+	enc := NewPrimitivePacketEncoder(0x01)
+	SetEnumValue(enc, testStatus(404))
+	assert.Equal(t, []byte{0x01, 0x02, 0x01, 0x94}, enc.Encode())
+}
+
+func TestEnumTagErrors(t *testing.T) {
+	_, err := Marshal(0x01, struct {
+		A int32 `y3:"0x01,enum=noSuchEnum"`
+	}{})
+	assert.EqualError(t, err, "y3: struct { A int32 \"y3:\\\"0x01,enum=noSuchEnum\\\"\" }.A: enum noSuchEnum is not registered")
+
+	_, err = Marshal(0x01, struct {
+		A string `y3:"0x01,enum=testLogLevel"`
+	}{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,299 @@
+// Code generated by y3 schema compile. DO NOT EDIT.
+// source: frame.y3
+
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/yomorun/y3"
+)
+
+// DataFrame is the message DataFrame
+type DataFrame struct {
+	Meta    *MetaFrame    `y3:"0x2F"`
+	Payload *PayloadFrame `y3:"0x2E"`
+	// UnknownFields keeps the children not declared in the schema
+	UnknownFields []byte `y3:",unknown"`
+}
+
+// SeqIDOfDataFrame is the SeqID of DataFrame when encoded as a root packet
+const SeqIDOfDataFrame byte = 0x3F
+
+// Encode returns the Y3 encoded bytes of DataFrame with SeqID 0x3F
+func (m *DataFrame) Encode() []byte {
+	return m.ToNodePacketEncoder(0x3F).Encode()
+}
+
+// ToNodePacketEncoder returns the encoder of DataFrame with SeqID sid
+func (m *DataFrame) ToNodePacketEncoder(sid byte) *y3.NodePacketEncoder {
+	enc := y3.NewNodePacketEncoder(sid)
+	if m.Meta != nil {
+		enc.AddNodePacket(m.Meta.ToNodePacketEncoder(0x2F))
+	} else {
+		enc.AddNodePacket((&MetaFrame{}).ToNodePacketEncoder(0x2F))
+	}
+	if m.Payload != nil {
+		enc.AddNodePacket(m.Payload.ToNodePacketEncoder(0x2E))
+	} else {
+		enc.AddNodePacket((&PayloadFrame{}).ToNodePacketEncoder(0x2E))
+	}
+	enc.AddBytes(m.UnknownFields)
+	return enc
+}
+
+// Decode parse Y3 encoded bytes to DataFrame
+func (m *DataFrame) Decode(buf []byte) error {
+	np := &y3.NodePacket{}
+	if _, err := y3.DecodeToNodePacket(buf, np); err != nil {
+		return err
+	}
+	return m.DecodeNodePacket(np)
+}
+
+// DecodeNodePacket parse a decoded node packet to DataFrame
+func (m *DataFrame) DecodeNodePacket(np *y3.NodePacket) error {
+	if n, ok := np.NodePackets[0x2F]; ok {
+		m.Meta = &MetaFrame{}
+		if err := m.Meta.DecodeNodePacket(&n); err != nil {
+			return err
+		}
+	} else {
+		return errors.New("y3: required field DataFrame.meta (0x2F) is missing")
+	}
+	if n, ok := np.NodePackets[0x2E]; ok {
+		m.Payload = &PayloadFrame{}
+		if err := m.Payload.DecodeNodePacket(&n); err != nil {
+			return err
+		}
+	} else {
+		return errors.New("y3: required field DataFrame.payload (0x2E) is missing")
+	}
+	m.UnknownFields = np.UnknownBytes(0x2F, 0x2E)
+	return nil
+}
+
+// MetaFrame is the message MetaFrame
+type MetaFrame struct {
+	TransactionId string     `y3:"0x01"`
+	IssuedAt      *time.Time `y3:"0x02"`
+	Tags          []string   `y3:"0x03"`
+	// UnknownFields keeps the children not declared in the schema
+	UnknownFields []byte `y3:",unknown"`
+}
+
+// Encode returns the Y3 encoded bytes of MetaFrame with SeqID 0x00
+func (m *MetaFrame) Encode() []byte {
+	return m.ToNodePacketEncoder(0x00).Encode()
+}
+
+// ToNodePacketEncoder returns the encoder of MetaFrame with SeqID sid
+func (m *MetaFrame) ToNodePacketEncoder(sid byte) *y3.NodePacketEncoder {
+	enc := y3.NewNodePacketEncoder(sid)
+	{
+		p := y3.NewPrimitivePacketEncoder(0x01)
+		p.SetStringValue(m.TransactionId)
+		enc.AddPrimitivePacket(p)
+	}
+	{
+		p := y3.NewPrimitivePacketEncoder(0x02)
+		y3.SetOptionalValue(p, m.IssuedAt, (*y3.PrimitivePacketEncoder).SetTimeValue)
+		enc.AddPrimitivePacket(p)
+	}
+	{
+		s := y3.NewNodeSlicePacketEncoder(0x03)
+		for _, v := range m.Tags {
+			p := y3.NewPrimitivePacketEncoder(y3.SliceElementSeqID)
+			p.SetStringValue(v)
+			s.AddPrimitivePacket(p)
+		}
+		enc.AddNodePacket(s)
+	}
+	enc.AddBytes(m.UnknownFields)
+	return enc
+}
+
+// Decode parse Y3 encoded bytes to MetaFrame
+func (m *MetaFrame) Decode(buf []byte) error {
+	np := &y3.NodePacket{}
+	if _, err := y3.DecodeToNodePacket(buf, np); err != nil {
+		return err
+	}
+	return m.DecodeNodePacket(np)
+}
+
+// DecodeNodePacket parse a decoded node packet to MetaFrame
+func (m *MetaFrame) DecodeNodePacket(np *y3.NodePacket) error {
+	if p, ok := np.PrimitivePackets[0x01]; ok {
+		v, err := p.ToUTF8String()
+		if err != nil {
+			return err
+		}
+		m.TransactionId = v
+	} else {
+		return errors.New("y3: required field MetaFrame.transaction_id (0x01) is missing")
+	}
+	if p, ok := np.PrimitivePackets[0x02]; ok {
+		v, err := y3.ToOptionalValue(&p, (*y3.PrimitivePacket).ToTime)
+		if err != nil {
+			return err
+		}
+		m.IssuedAt = v
+	}
+	if n, ok := np.NodePackets[0x03]; ok {
+		v, err := y3.DecodePrimitiveSlice(&n, (*y3.PrimitivePacket).ToUTF8String)
+		if err != nil {
+			return err
+		}
+		m.Tags = v
+	}
+	m.UnknownFields = np.UnknownBytes(0x01, 0x02, 0x03)
+	return nil
+}
+
+// PayloadFrame is the message PayloadFrame
+type PayloadFrame struct {
+	Sid      uint32                `y3:"0x01"`
+	Carriage []byte                `y3:"0x02"`
+	Routes   []*PayloadFrame_Route `y3:"0x03"`
+	// UnknownFields keeps the children not declared in the schema
+	UnknownFields []byte `y3:",unknown"`
+}
+
+// Encode returns the Y3 encoded bytes of PayloadFrame with SeqID 0x00
+func (m *PayloadFrame) Encode() []byte {
+	return m.ToNodePacketEncoder(0x00).Encode()
+}
+
+// ToNodePacketEncoder returns the encoder of PayloadFrame with SeqID sid
+func (m *PayloadFrame) ToNodePacketEncoder(sid byte) *y3.NodePacketEncoder {
+	enc := y3.NewNodePacketEncoder(sid)
+	{
+		p := y3.NewPrimitivePacketEncoder(0x01)
+		p.SetUInt32Value(m.Sid)
+		enc.AddPrimitivePacket(p)
+	}
+	{
+		p := y3.NewPrimitivePacketEncoder(0x02)
+		p.SetBytesValue(m.Carriage)
+		enc.AddPrimitivePacket(p)
+	}
+	{
+		s := y3.NewNodeSlicePacketEncoder(0x03)
+		for _, v := range m.Routes {
+			s.AddNodePacket(v.ToNodePacketEncoder(y3.SliceElementSeqID))
+		}
+		enc.AddNodePacket(s)
+	}
+	enc.AddBytes(m.UnknownFields)
+	return enc
+}
+
+// Decode parse Y3 encoded bytes to PayloadFrame
+func (m *PayloadFrame) Decode(buf []byte) error {
+	np := &y3.NodePacket{}
+	if _, err := y3.DecodeToNodePacket(buf, np); err != nil {
+		return err
+	}
+	return m.DecodeNodePacket(np)
+}
+
+// DecodeNodePacket parse a decoded node packet to PayloadFrame
+func (m *PayloadFrame) DecodeNodePacket(np *y3.NodePacket) error {
+	if p, ok := np.PrimitivePackets[0x01]; ok {
+		v, err := p.ToUInt32()
+		if err != nil {
+			return err
+		}
+		m.Sid = v
+	} else {
+		return errors.New("y3: required field PayloadFrame.sid (0x01) is missing")
+	}
+	if p, ok := np.PrimitivePackets[0x02]; ok {
+		v, err := y3BytesValue(&p)
+		if err != nil {
+			return err
+		}
+		m.Carriage = v
+	} else {
+		return errors.New("y3: required field PayloadFrame.carriage (0x02) is missing")
+	}
+	if n, ok := np.NodePackets[0x03]; ok {
+		v, err := y3.DecodeNodeSlice(&n, func(n *y3.NodePacket) (*PayloadFrame_Route, error) {
+			v := &PayloadFrame_Route{}
+			return v, v.DecodeNodePacket(n)
+		})
+		if err != nil {
+			return err
+		}
+		m.Routes = v
+	}
+	m.UnknownFields = np.UnknownBytes(0x01, 0x02, 0x03)
+	return nil
+}
+
+// PayloadFrame_Route is the message PayloadFrame.Route
+type PayloadFrame_Route struct {
+	Name   string `y3:"0x01"`
+	Weight *int32 `y3:"0x02"`
+	// UnknownFields keeps the children not declared in the schema
+	UnknownFields []byte `y3:",unknown"`
+}
+
+// Encode returns the Y3 encoded bytes of PayloadFrame_Route with SeqID 0x00
+func (m *PayloadFrame_Route) Encode() []byte {
+	return m.ToNodePacketEncoder(0x00).Encode()
+}
+
+// ToNodePacketEncoder returns the encoder of PayloadFrame_Route with SeqID sid
+func (m *PayloadFrame_Route) ToNodePacketEncoder(sid byte) *y3.NodePacketEncoder {
+	enc := y3.NewNodePacketEncoder(sid)
+	{
+		p := y3.NewPrimitivePacketEncoder(0x01)
+		p.SetStringValue(m.Name)
+		enc.AddPrimitivePacket(p)
+	}
+	{
+		p := y3.NewPrimitivePacketEncoder(0x02)
+		y3.SetOptionalValue(p, m.Weight, (*y3.PrimitivePacketEncoder).SetInt32Value)
+		enc.AddPrimitivePacket(p)
+	}
+	enc.AddBytes(m.UnknownFields)
+	return enc
+}
+
+// Decode parse Y3 encoded bytes to PayloadFrame_Route
+func (m *PayloadFrame_Route) Decode(buf []byte) error {
+	np := &y3.NodePacket{}
+	if _, err := y3.DecodeToNodePacket(buf, np); err != nil {
+		return err
+	}
+	return m.DecodeNodePacket(np)
+}
+
+// DecodeNodePacket parse a decoded node packet to PayloadFrame_Route
+func (m *PayloadFrame_Route) DecodeNodePacket(np *y3.NodePacket) error {
+	if p, ok := np.PrimitivePackets[0x01]; ok {
+		v, err := p.ToUTF8String()
+		if err != nil {
+			return err
+		}
+		m.Name = v
+	} else {
+		return errors.New("y3: required field PayloadFrame.Route.name (0x01) is missing")
+	}
+	if p, ok := np.PrimitivePackets[0x02]; ok {
+		v, err := y3.ToOptionalValue(&p, (*y3.PrimitivePacket).ToInt32)
+		if err != nil {
+			return err
+		}
+		m.Weight = v
+	}
+	m.UnknownFields = np.UnknownBytes(0x01, 0x02)
+	return nil
+}
+
+func y3BytesValue(p *y3.PrimitivePacket) ([]byte, error) {
+	return append([]byte{}, p.ToBytes()...), nil
+}
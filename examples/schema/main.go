@@ -0,0 +1,35 @@
+package main
+
+//go:generate go run ../../cmd/y3 schema compile frame.y3
+
+import (
+	"fmt"
+	"time"
+)
+
+func main() {
+	now := time.Now()
+	weight := int32(10)
+	frame := &DataFrame{
+		Meta: &MetaFrame{
+			TransactionId: "yomo",
+			IssuedAt:      &now,
+			Tags:          []string{"edge", "demo"},
+		},
+		Payload: &PayloadFrame{
+			Sid:      0x01,
+			Carriage: []byte{0x01, 0x02, 0x03},
+			Routes:   []*PayloadFrame_Route{{Name: "a", Weight: &weight}, {Name: "b"}},
+		},
+	}
+
+	buf := frame.Encode()
+	fmt.Printf("encoded: %# x\n", buf)
+
+	decoded := &DataFrame{}
+	if err := decoded.Decode(buf); err != nil {
+		panic(err)
+	}
+	fmt.Printf("transactionID=%s, tags=%v, carriage=%# x, routes=%d\n",
+		decoded.Meta.TransactionId, decoded.Meta.Tags, decoded.Payload.Carriage, len(decoded.Payload.Routes))
+}
@@ -0,0 +1,341 @@
+package y3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yomorun/y3/encoding"
+)
+
+// rawPacket is a packet located in the encoded bytes
+type rawPacket struct {
+	tag *Tag
+	// off is the offset of the tag
+	off int
+	// header is the tag and the length
+	header []byte
+	val    []byte
+}
+
+func (p *rawPacket) size() int {
+	return len(p.header) + len(p.val)
+}
+
+// isNull returns true if it's a primitive of explicit null
+func (p *rawPacket) isNull() bool {
+	return !p.tag.IsNode() && p.tag.IsSlice() && len(p.val) == 0
+}
+
+// readRawPacket reads the packet at the beginning of buf, off is the offset of buf
+func readRawPacket(buf []byte, off int) (*rawPacket, error) {
+	if len(buf) == 0 {
+		return nil, fmt.Errorf("offset %d: empty buf", off)
+	}
+	// the length is a PVarInt ends with the byte without MSB
+	end := 1
+	for end < len(buf) && buf[end]&0x80 != 0 {
+		end++
+	}
+	if end >= len(buf) {
+		return nil, fmt.Errorf("offset %d: truncated length", off)
+	}
+	var length int32
+	codec := encoding.VarCodec{}
+	if err := codec.DecodePVarInt32(buf[1:end+1], &length); err != nil || length < 0 {
+		return nil, fmt.Errorf("offset %d: invalid length % X", off, buf[1:end+1])
+	}
+	hdr := end + 1
+	if int(length) > len(buf)-hdr {
+		return nil, fmt.Errorf("offset %d: length %d exceeds the %d bytes left", off, length, len(buf)-hdr)
+	}
+	return &rawPacket{tag: NewTag(buf[0]), off: off, header: buf[:hdr], val: buf[hdr : hdr+int(length)]}, nil
+}
+
+// eachRawPacket calls fn with the packets one after another in buf
+func eachRawPacket(buf []byte, off int, fn func(p *rawPacket) error) error {
+	for pos := 0; pos < len(buf); {
+		p, err := readRawPacket(buf[pos:], off+pos)
+		if err != nil {
+			return err
+		}
+		pos += p.size()
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkTree calls enter with the packets in buf in depth-first order, and
+// leave after the children of every node, leave is optional. path is the
+// SeqIDs from the root packet to the packet, elem means it's an element of
+// slice, off is the offset of buf.
+func walkTree(buf []byte, off int, enter func(p *rawPacket, path []byte, elem bool) error, leave func(p *rawPacket, path []byte)) error {
+	var path []byte
+	var walk func(p *rawPacket, elem bool) error
+	walk = func(p *rawPacket, elem bool) error {
+		path = append(path, p.tag.SeqID())
+		defer func() { path = path[:len(path)-1] }()
+
+		if err := enter(p, path, elem); err != nil {
+			return err
+		}
+		if !p.tag.IsNode() {
+			return nil
+		}
+		err := eachRawPacket(p.val, p.off+len(p.header), func(child *rawPacket) error {
+			return walk(child, p.tag.IsSlice())
+		})
+		if err != nil {
+			return err
+		}
+		if leave != nil {
+			leave(p, path)
+		}
+		return nil
+	}
+	return eachRawPacket(buf, off, func(p *rawPacket) error {
+		return walk(p, false)
+	})
+}
+
+// ValueFunc returns the text of the primitive at path in the printed
+// packets, e.g. the symbolic name of enum, path is the SeqIDs from the root
+// packet to the primitive. The default text is used if it returns "", it's
+// not called for null.
+type ValueFunc func(path []byte, val []byte) string
+
+// treeOptions describes how writeTree writes packets
+type treeOptions struct {
+	// indent is the indentation of a level, every packet is written in a
+	// line, the tree is written in one line if it's ""
+	indent string
+	// offsets means writing the offset and the length of Val after the SeqID
+	offsets bool
+	// value returns the text of primitives, it's optional
+	value ValueFunc
+}
+
+// writeTree writes the packets in buf as a tree, see formatPacket for the
+// compact form and FormatText for the indented form
+func writeTree(w *bytes.Buffer, buf []byte, opts *treeOptions) error {
+	pretty := opts.indent != ""
+	enter := func(p *rawPacket, path []byte, elem bool) error {
+		omitSeqID := elem && p.tag.SeqID() == SliceElementSeqID && !opts.offsets
+		if pretty {
+			w.WriteString(strings.Repeat(opts.indent, len(path)-1))
+		} else if len(path) > 1 {
+			if last := w.Bytes()[w.Len()-1]; last != '{' && last != '[' {
+				w.WriteByte(' ')
+			}
+		}
+		if !omitSeqID {
+			fmt.Fprintf(w, "0x%02X", p.tag.SeqID())
+		}
+		if opts.offsets {
+			fmt.Fprintf(w, "@%d/%d", p.off, len(p.val))
+		}
+
+		if !p.tag.IsNode() {
+			if !omitSeqID {
+				w.WriteByte(':')
+				if pretty {
+					w.WriteByte(' ')
+				}
+			}
+			w.WriteString(valueText(p, path, opts.value))
+			if pretty {
+				w.WriteByte('\n')
+			}
+			return nil
+		}
+
+		if pretty && !omitSeqID {
+			w.WriteByte(' ')
+		}
+		open, _ := brackets(p)
+		w.WriteByte(open)
+		if pretty && len(p.val) > 0 {
+			w.WriteByte('\n')
+		}
+		return nil
+	}
+	leave := func(p *rawPacket, path []byte) {
+		if pretty && len(p.val) > 0 {
+			w.WriteString(strings.Repeat(opts.indent, len(path)-1))
+		}
+		_, close := brackets(p)
+		w.WriteByte(close)
+		if pretty {
+			w.WriteByte('\n')
+		}
+	}
+	return walkTree(buf, 0, enter, leave)
+}
+
+// brackets returns the brackets enclosing the children of node
+func brackets(p *rawPacket) (open, close byte) {
+	if p.tag.IsSlice() {
+		return '[', ']'
+	}
+	return '{', '}'
+}
+
+// textValue returns the text of the primitive value, see FormatText
+func textValue(p *rawPacket) string {
+	if p.isNull() {
+		return "null"
+	}
+	if len(p.val) > 0 && isPrintableText(p.val) {
+		return strconv.Quote(string(p.val))
+	}
+	return `h"` + strings.ToUpper(hex.EncodeToString(p.val)) + `"`
+}
+
+// valueText returns the text of the primitive at path by value, or by
+// textValue if value is nil or returns ""
+func valueText(p *rawPacket, path []byte, value ValueFunc) string {
+	if value != nil && !p.isNull() {
+		if text := value(path, p.val); text != "" {
+			return text
+		}
+	}
+	return textValue(p)
+}
+
+func isPrintableText(buf []byte) bool {
+	if !utf8.Valid(buf) {
+		return false
+	}
+	for _, r := range string(buf) {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatPacket implements fmt.Formatter for the encoded packet raw:
+//
+//	%v, %s  the compact tree, e.g. 0x3F{0x2F{0x01:"yomo"} 0x2E:h"0102"}
+//	%+v     the tree with the offset and the length of Val of every packet,
+//	        e.g. 0x3F@0/10{0x2F@2/6{0x01@4/4:"yomo"}}
+//	%#v     the Go syntax of raw bytes, e.g. []byte{0x81, 0x01, 0x01}
+//	%x, %X  the raw bytes in hex, the flags of fmt are supported
+//
+// value returns the text of primitives in the trees, it can be nil.
+func formatPacket(f fmt.State, verb rune, raw []byte, value ValueFunc) {
+	switch verb {
+	case 'v', 's':
+		if verb == 'v' && f.Flag('#') {
+			fmt.Fprint(f, goSyntax(raw))
+			return
+		}
+		var w bytes.Buffer
+		p, err := readRawPacket(raw, 0)
+		if err == nil {
+			err = writeTree(&w, p.bytes(), &treeOptions{offsets: verb == 'v' && f.Flag('+'), value: value})
+		}
+		if err != nil {
+			fmt.Fprintf(f, "%%!%c(y3: %v)", verb, err)
+			return
+		}
+		f.Write(w.Bytes())
+	case 'x', 'X':
+		format := "%"
+		for _, flag := range " #" {
+			if f.Flag(int(flag)) {
+				format += string(flag)
+			}
+		}
+		fmt.Fprintf(f, format+string(verb), raw)
+	default:
+		fmt.Fprintf(f, "%%!%c(y3 packet=% X)", verb, raw)
+	}
+}
+
+func goSyntax(buf []byte) string {
+	var sb strings.Builder
+	sb.WriteString("[]byte{")
+	for i, b := range buf {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "0x%02X", b)
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// Format implements fmt.Formatter, see formatPacket for the verbs. It has a
+// value receiver, so the packets in NodePackets can be printed as well.
+func (n NodePacket) Format(f fmt.State, verb rune) {
+	formatPacket(f, verb, n.rawBytes(), nil)
+}
+
+// Format implements fmt.Formatter, see NodePacket.Format
+func (p PrimitivePacket) Format(f fmt.State, verb rune) {
+	formatPacket(f, verb, p.rawBytes(), nil)
+}
+
+// Format implements fmt.Formatter, the packet being encoded is printed like
+// NodePacket.Format, the encoder is not completed by formatting
+func (enc *NodePacketEncoder) Format(f fmt.State, verb rune) {
+	formatPacket(f, verb, enc.encoder.bytes(), nil)
+}
+
+// Format implements fmt.Formatter, see NodePacketEncoder.Format
+func (enc *PrimitivePacketEncoder) Format(f fmt.State, verb rune) {
+	formatPacket(f, verb, enc.encoder.bytes(), nil)
+}
+
+// Formatted returns the packet in buf implementing fmt.Formatter like
+// NodePacket.Format, the primitives in the trees are printed by value, e.g.
+//
+//	fmt.Printf("%v\n", y3.Formatted(buf, schema.ValueFunc(s)))
+func Formatted(buf []byte, value ValueFunc) fmt.Formatter {
+	return formattedPacket{buf: buf, value: value}
+}
+
+type formattedPacket struct {
+	buf   []byte
+	value ValueFunc
+}
+
+func (p formattedPacket) Format(f fmt.State, verb rune) {
+	formatPacket(f, verb, p.buf, p.value)
+}
+
+// rawBytes returns the encoded bytes, nil if the packet is not decoded
+func (bp *basePacket) rawBytes() []byte {
+	if bp == nil || bp.buf == nil {
+		return nil
+	}
+	return bp.buf.Bytes()
+}
+
+// bytes returns the encoded bytes like Encode without completing the encoder
+func (enc *encoder) bytes() []byte {
+	if enc.complete {
+		return enc.buf.Bytes()
+	}
+	tag := enc.seqID
+	if enc.isNode {
+		tag |= 0x80
+	}
+	if enc.isArray {
+		tag |= 0x40
+	}
+	size := encoding.SizeOfPVarInt32(int32(len(enc.valbuf)))
+	buf := make([]byte, 1+size, 1+size+len(enc.valbuf))
+	buf[0] = tag
+	codec := encoding.VarCodec{Size: size}
+	// the size is computed, encoding never fails
+	_ = codec.EncodePVarInt32(buf[1:], int32(len(enc.valbuf)))
+	return append(buf, enc.valbuf...)
+}
@@ -0,0 +1,56 @@
+package y3
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatPacket(t *testing.T) {
+	buf, err := ParseText(`0x3F { 0x2F { 0x01: "yomo" 0x03: null } 0x05 [1 2] 0x2E: h"0102" }`)
+	assert.NoError(t, err)
+	var n NodePacket
+	_, err = DecodeToNodePacket(buf, &n)
+	assert.NoError(t, err)
+
+	assert.Equal(t, `0x3F{0x2F{0x01:"yomo" 0x03:null} 0x05[h"01" h"02"] 0x2E:h"0102"}`, fmt.Sprintf("%v", n))
+	assert.Equal(t, fmt.Sprintf("%v", n), fmt.Sprintf("%s", &n))
+	assert.Equal(t, `0x3F@0/22{0x2F@2/8{0x01@4/4:"yomo" 0x03@10/0:null} 0x05@12/6[0x00@14/1:h"01" 0x00@17/1:h"02"] 0x2E@20/2:h"0102"}`, fmt.Sprintf("%+v", n))
+	assert.Equal(t, `0x01:"yomo"`, fmt.Sprintf("%v", n.NodePackets[0x2F].PrimitivePackets[0x01]))
+	assert.Equal(t, "[]byte{0x2E, 0x02, 0x01, 0x02}", fmt.Sprintf("%#v", n.PrimitivePackets[0x2E]))
+	assert.Equal(t, "2e020102", fmt.Sprintf("%x", n.PrimitivePackets[0x2E]))
+	assert.Equal(t, "2E 02 01 02", fmt.Sprintf("% X", n.PrimitivePackets[0x2E]))
+	assert.Equal(t, "%!d(y3 packet=2E 02 01 02)", fmt.Sprintf("%d", n.PrimitivePackets[0x2E]))
+	assert.Equal(t, "%!v(y3: offset 0: empty buf)", fmt.Sprintf("%v", NodePacket{}))
+}
+
+func TestFormatEncoder(t *testing.T) {
+	node := NewNodePacketEncoder(0x01)
+	p := NewPrimitivePacketEncoder(0x02)
+	p.SetStringValue("a")
+	node.AddPrimitivePacket(p)
+
+	assert.Equal(t, `0x02:"a"`, fmt.Sprintf("%v", p))
+	assert.Equal(t, `0x01{0x02:"a"}`, fmt.Sprintf("%v", node))
+	// formatting does not complete the encoder
+	assert.Equal(t, []byte{0x81, 0x03, 0x02, 0x01, 0x61}, node.Encode())
+	assert.Equal(t, `0x01@0/3{0x02@2/1:"a"}`, fmt.Sprintf("%+v", node))
+}
+
+func TestFormatted(t *testing.T) {
+	buf, err := ParseText(`0x01 { 0x02: 1 0x03 [2] 0x04: null }`)
+	assert.NoError(t, err)
+	var paths []string
+	value := func(path []byte, val []byte) string {
+		paths = append(paths, fmt.Sprintf("% X", path))
+		if val[0] == 1 {
+			return "OK"
+		}
+		return ""
+	}
+	assert.Equal(t, `0x01{0x02:OK 0x03[h"02"] 0x04:null}`, fmt.Sprintf("%v", Formatted(buf, value)))
+	assert.Equal(t, []string{"01 02", "01 03 00"}, paths)
+	assert.Equal(t, `0x01@0/10{0x02@2/1:OK 0x03@5/3[0x00@7/1:h"02"] 0x04@10/0:null}`, fmt.Sprintf("%+v", Formatted(buf, value)))
+	assert.Equal(t, `0x01{0x02:h"01" 0x03[h"02"] 0x04:null}`, fmt.Sprintf("%v", Formatted(buf, nil)))
+}
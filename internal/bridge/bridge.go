@@ -0,0 +1,164 @@
+// Package bridge converts Y3 packets to a generic tree and back, it's shared
+// by the converters between Y3 and the self-describing formats.
+//
+// A node packet is a Map keyed by SeqID, a slice packet is []any, and a
+// primitive is nil, int64, uint64, float32, float64, bool, string, []byte or
+// time.Time. Untyped primitives are []byte of the encoded Val.
+package bridge
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/yomorun/y3"
+)
+
+// Map is a node packet, the entries keep the order of the children
+type Map []Entry
+
+// Entry is a child of node packet
+type Entry struct {
+	Key   uint64
+	Value any
+}
+
+// FromPacket parses the Y3 encoded node packet to a Map, typed means the
+// primitives are decoded by their type codes
+func FromPacket(buf []byte, typed bool) (Map, error) {
+	parse := y3.Parse
+	if typed {
+		parse = y3.ParseTyped
+	}
+	v, err := parse(buf)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := v.(*y3.NodeValue)
+	if !ok {
+		return nil, fmt.Errorf("packet %#x is not a node packet", v.SeqID())
+	}
+	return fromNode(n)
+}
+
+func fromNode(n *y3.NodeValue) (Map, error) {
+	m := make(Map, 0, len(n.Children()))
+	for _, child := range n.Children() {
+		v, err := fromValue(child)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, Entry{Key: uint64(child.SeqID()), Value: v})
+	}
+	return m, nil
+}
+
+func fromValue(v y3.Value) (any, error) {
+	switch v := v.(type) {
+	case *y3.NodeValue:
+		return fromNode(v)
+	case *y3.SliceValue:
+		a := make([]any, 0, v.Len())
+		for _, elem := range v.Elems() {
+			e, err := fromValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, e)
+		}
+		return a, nil
+	case *y3.PrimitiveValue:
+		if !v.IsTyped() && !v.IsNull() {
+			return v.Bytes(), nil
+		}
+		x, err := v.Value()
+		if err != nil {
+			return nil, fmt.Errorf("%#x: %w", v.SeqID(), err)
+		}
+		switch x := x.(type) {
+		case int32:
+			return int64(x), nil
+		case uint32:
+			return uint64(x), nil
+		}
+		return x, nil
+	}
+	return nil, nil
+}
+
+// ToPacket encodes the Map to a node packet with SeqID sid, typed means the
+// primitives are encoded with type codes, otherwise []byte values are taken
+// as the encoded Val
+func ToPacket(sid byte, m Map, typed bool) ([]byte, error) {
+	n, err := toNode(sid, m, typed)
+	if err != nil {
+		return nil, err
+	}
+	return n.Encode(), nil
+}
+
+func toNode(sid byte, m Map, typed bool) (*y3.NodeValue, error) {
+	n := y3.NewNode(sid)
+	for _, e := range m {
+		if e.Key > 0x3F {
+			return nil, fmt.Errorf("key %d is not a SeqID", e.Key)
+		}
+		if n.Get(byte(e.Key)) != nil {
+			return nil, fmt.Errorf("duplicated key %d", e.Key)
+		}
+		v, err := toValue(byte(e.Key), e.Value, typed)
+		if err != nil {
+			return nil, err
+		}
+		n.Set(v)
+	}
+	return n, nil
+}
+
+func toValue(sid byte, x any, typed bool) (y3.Value, error) {
+	switch x := x.(type) {
+	case Map:
+		return toNode(sid, x, typed)
+	case []any:
+		s := y3.NewSlice(sid)
+		for _, e := range x {
+			v, err := toValue(y3.SliceElementSeqID, e, typed)
+			if err != nil {
+				return nil, err
+			}
+			s.Append(v)
+		}
+		return s, nil
+	case nil:
+		return y3.NewNull(sid), nil
+	case []byte:
+		if !typed {
+			return y3.NewRawPrimitive(sid, x), nil
+		}
+	case int64:
+		// prefer int32, the default integer type
+		if x >= math.MinInt32 && x <= math.MaxInt32 {
+			return Primitive(sid, int32(x), typed)
+		}
+	case uint64:
+		if x <= math.MaxInt32 {
+			return Primitive(sid, int32(x), typed)
+		}
+		if x <= math.MaxInt64 {
+			return Primitive(sid, int64(x), typed)
+		}
+	}
+	return Primitive(sid, x, typed)
+}
+
+// Primitive creates the primitive of the Go value, typed means it's encoded
+// with the type code
+func Primitive(sid byte, x any, typed bool) (*y3.PrimitiveValue, error) {
+	if typed {
+		return y3.NewPrimitive(sid, x)
+	}
+	enc := y3.NewPrimitivePacketEncoder(sid)
+	if err := enc.SetValue(x); err != nil {
+		return nil, err
+	}
+	return y3.NewRawPrimitive(sid, enc.GetValBuf()), nil
+}
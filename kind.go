@@ -0,0 +1,60 @@
+package y3
+
+import "fmt"
+
+// Kind describes the type of value carried by a typed primitive packet.
+// In typed mode, Kind is written as the first byte of Val, followed by
+// the value encoded the same way as the untyped Set*Value methods do.
+type Kind byte
+
+const (
+	// KindUnknown means the packet carries no type information
+	KindUnknown Kind = iota
+	// KindInt32 is a NVarInt32 encoded int32
+	KindInt32
+	// KindInt64 is a NVarInt64 encoded int64
+	KindInt64
+	// KindUInt32 is a NVarUInt32 encoded uint32
+	KindUInt32
+	// KindUInt64 is a NVarUInt64 encoded uint64
+	KindUInt64
+	// KindFloat32 is a VarFloat32 encoded float32
+	KindFloat32
+	// KindFloat64 is a VarFloat64 encoded float64
+	KindFloat64
+	// KindBool is a PVarBool encoded bool
+	KindBool
+	// KindString is an UTF-8 string
+	KindString
+	// KindBytes is raw bytes
+	KindBytes
+	// KindTime is a time.Time, encoded as NVarInt64 of nanoseconds since Unix epoch
+	KindTime
+)
+
+var kindNames = [...]string{
+	KindUnknown: "unknown",
+	KindInt32:   "int32",
+	KindInt64:   "int64",
+	KindUInt32:  "uint32",
+	KindUInt64:  "uint64",
+	KindFloat32: "float32",
+	KindFloat64: "float64",
+	KindBool:    "bool",
+	KindString:  "string",
+	KindBytes:   "bytes",
+	KindTime:    "time",
+}
+
+// IsValid returns true if k is a known type code
+func (k Kind) IsValid() bool {
+	return k > KindUnknown && int(k) < len(kindNames)
+}
+
+// String returns the name of the Kind
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("kind(%#x)", byte(k))
+}
@@ -0,0 +1,132 @@
+package y3
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/yomorun/y3/utils"
+)
+
+// A map is encoded as a slice node packet, every key/value pair is an entry
+// node inside it, the key and the value are primitive packets of the entry:
+//
+//	0xC? (slice node)
+//	  0x80 (entry node)
+//	    0x01 key
+//	    0x02 value
+//	  0x80 ...
+//
+// Entries are sorted by key, so the same map is always encoded to the same bytes.
+const (
+	// MapEntrySeqID is the SeqID of every entry node in a map packet
+	MapEntrySeqID byte = 0x00
+	// MapKeySeqID is the SeqID of the key in an entry node
+	MapKeySeqID byte = 0x01
+	// MapValueSeqID is the SeqID of the value in an entry node
+	MapValueSeqID byte = 0x02
+)
+
+// NewStringMapPacketEncoder returns an Encoder for map[string]T, set is used to
+// encode every value, e.g. (*PrimitivePacketEncoder).SetStringValue
+func NewStringMapPacketEncoder[T any](sid byte, m map[string]T, set func(*PrimitivePacketEncoder, T)) *NodePacketEncoder {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	enc := NewNodeSlicePacketEncoder(sid)
+	for _, k := range keys {
+		key := NewPrimitivePacketEncoder(MapKeySeqID)
+		key.SetStringValue(k)
+		enc.AddNodePacket(newMapEntryEncoder(key, m[k], set))
+	}
+	return enc
+}
+
+// NewInt64MapPacketEncoder returns an Encoder for map[int64]T, set is used to
+// encode every value, e.g. (*PrimitivePacketEncoder).SetStringValue
+func NewInt64MapPacketEncoder[T any](sid byte, m map[int64]T, set func(*PrimitivePacketEncoder, T)) *NodePacketEncoder {
+	keys := make([]int64, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	enc := NewNodeSlicePacketEncoder(sid)
+	for _, k := range keys {
+		key := NewPrimitivePacketEncoder(MapKeySeqID)
+		key.SetInt64Value(k)
+		enc.AddNodePacket(newMapEntryEncoder(key, m[k], set))
+	}
+	return enc
+}
+
+func newMapEntryEncoder[T any](key *PrimitivePacketEncoder, v T, set func(*PrimitivePacketEncoder, T)) *NodePacketEncoder {
+	val := NewPrimitivePacketEncoder(MapValueSeqID)
+	set(val, v)
+
+	entry := NewNodePacketEncoder(MapEntrySeqID)
+	entry.AddPrimitivePacket(key)
+	entry.AddPrimitivePacket(val)
+	return entry
+}
+
+// DecodeStringMap parse a map packet encoded by NewStringMapPacketEncoder,
+// get is used to decode every value, e.g. (*PrimitivePacket).ToUTF8String
+func DecodeStringMap[T any](buf []byte, get func(*PrimitivePacket) (T, error)) (map[string]T, error) {
+	return decodeMap(buf, (*PrimitivePacket).ToUTF8String, get)
+}
+
+// DecodeInt64Map parse a map packet encoded by NewInt64MapPacketEncoder,
+// get is used to decode every value, e.g. (*PrimitivePacket).ToUTF8String
+func DecodeInt64Map[T any](buf []byte, get func(*PrimitivePacket) (T, error)) (map[int64]T, error) {
+	return decodeMap(buf, (*PrimitivePacket).ToInt64, get)
+}
+
+func decodeMap[K comparable, T any](buf []byte, key func(*PrimitivePacket) (K, error), get func(*PrimitivePacket) (T, error)) (map[K]T, error) {
+	np := &NodePacket{}
+	if _, err := DecodeToNodePacket(buf, np); err != nil {
+		return nil, err
+	}
+	if !np.IsSlice() {
+		return nil, errors.New("y3: map packet should be a slice")
+	}
+
+	m := make(map[K]T)
+	valbuf := np.GetValBuf()
+	for pos := 0; pos < len(valbuf); {
+		if !utils.IsNodePacket(valbuf[pos]) {
+			return nil, fmt.Errorf("y3: map entry should be a node, pos=%v", pos)
+		}
+		entry := &NodePacket{}
+		n, err := DecodeToNodePacket(valbuf[pos:], entry)
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+
+		kp, ok := entry.PrimitivePackets[MapKeySeqID]
+		if !ok {
+			return nil, errors.New("y3: map entry without key")
+		}
+		vp, ok := entry.PrimitivePackets[MapValueSeqID]
+		if !ok {
+			return nil, errors.New("y3: map entry without value")
+		}
+		k, err := key(&kp)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := m[k]; ok {
+			return nil, fmt.Errorf("y3: duplicated map key %v", k)
+		}
+		v, err := get(&vp)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,83 @@
+package y3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringMap(t *testing.T) {
+	labels := map[string]string{"b": "2", "a": "1"}
+	enc := NewStringMapPacketEncoder(0x05, labels, (*PrimitivePacketEncoder).SetStringValue)
+	buf := enc.Encode()
+	// entries are sorted by key
+	assert.Equal(t, []byte{
+		0xC5, 0x10,
+		0x80, 0x06, 0x01, 0x01, 0x61, 0x02, 0x01, 0x31,
+		0x80, 0x06, 0x01, 0x01, 0x62, 0x02, 0x01, 0x32,
+	}, buf)
+
+	m, err := DecodeStringMap(buf, (*PrimitivePacket).ToUTF8String)
+	assert.NoError(t, err)
+	assert.Equal(t, labels, m)
+}
+
+func TestStringMapDeterministic(t *testing.T) {
+	m := map[string]int32{}
+	for i, k := range []string{"x", "y", "z", "w", "v", "u"} {
+		m[k] = int32(i)
+	}
+	expect := NewStringMapPacketEncoder(0x01, m, (*PrimitivePacketEncoder).SetInt32Value).Encode()
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, expect, NewStringMapPacketEncoder(0x01, m, (*PrimitivePacketEncoder).SetInt32Value).Encode())
+	}
+}
+
+func TestInt64Map(t *testing.T) {
+	codes := map[int64]float64{-1: 0.5, 300: 1, 2: 2}
+	buf := NewInt64MapPacketEncoder(0x06, codes, (*PrimitivePacketEncoder).SetFloat64Value).Encode()
+
+	m, err := DecodeInt64Map(buf, (*PrimitivePacket).ToFloat64)
+	assert.NoError(t, err)
+	assert.Equal(t, codes, m)
+}
+
+func TestEmptyMap(t *testing.T) {
+	buf := NewStringMapPacketEncoder(0x05, map[string]string{}, (*PrimitivePacketEncoder).SetStringValue).Encode()
+	assert.Equal(t, []byte{0xC5, 0x00}, buf)
+
+	m, err := DecodeStringMap(buf, (*PrimitivePacket).ToUTF8String)
+	assert.NoError(t, err)
+	assert.Len(t, m, 0)
+}
+
+func TestDecodeMapMalformed(t *testing.T) {
+	_, err := DecodeStringMap([]byte{0x85, 0x00}, (*PrimitivePacket).ToUTF8String)
+	assert.EqualError(t, err, "y3: map packet should be a slice")
+
+	_, err = DecodeStringMap([]byte{0xC5, 0x03, 0x01, 0x01, 0x61}, (*PrimitivePacket).ToUTF8String)
+	assert.EqualError(t, err, "y3: map entry should be a node, pos=0")
+
+	_, err = DecodeStringMap([]byte{0xC5, 0x05, 0x80, 0x03, 0x01, 0x01, 0x61}, (*PrimitivePacket).ToUTF8String)
+	assert.EqualError(t, err, "y3: map entry without value")
+}
+
+func TestDecodeMapDuplicatedKey(t *testing.T) {
+	enc := NewNodeSlicePacketEncoder(0x05)
+	for _, v := range []string{"1", "2"} {
+		key := NewPrimitivePacketEncoder(MapKeySeqID)
+		key.SetStringValue("x")
+		enc.AddNodePacket(newMapEntryEncoder(key, v, (*PrimitivePacketEncoder).SetStringValue))
+	}
+	buf := enc.Encode()
+
+	_, err := DecodeStringMap(buf, (*PrimitivePacket).ToUTF8String)
+	assert.EqualError(t, err, "y3: duplicated map key x")
+
+	var res struct {
+		Labels map[string]string `y3:"0x05"`
+	}
+	root := NewNodePacketEncoder(0x01)
+	root.AddBytes(buf)
+	assert.EqualError(t, Unmarshal(root.Encode(), &res), "y3: duplicated map key x")
+}
@@ -0,0 +1,479 @@
+package y3
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// SliceElementSeqID is the SeqID of every element in a slice packet encoded by Marshal
+const SliceElementSeqID byte = 0x00
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// Marshal encodes a struct to a node packet with SeqID sid, the fields to be
+// encoded are declared by the `y3` struct tag with their SeqID, e.g.
+//
+//	type Foo struct {
+//		ID   int32  `y3:"0x02"`
+//		Name string `y3:"0x04"`
+//		Bar  *Bar   `y3:"0x03"`
+//	}
+//
+// bool, integers, floats, string, []byte and time.Time are encoded as primitive
+// packets, structs as node packets, slices as slice packets with every element
+// of SliceElementSeqID, maps as map packets (see NewStringMapPacketEncoder), and
+// interfaces as oneof packets (see RegisterOneof). A nil pointer or interface is
+// encoded as null. Types with a registered codec or implementing Marshaler,
+// encoding.BinaryMarshaler or encoding.TextMarshaler are encoded as primitive
+// packets, see RegisterCodec.
+//
+// The SeqID in the tag can be followed by options separated by commas, e.g.
+// `y3:"0x03,required,min=0,max=100"`:
+//
+//	required   the child must be present and not null when unmarshaling, and a
+//	           nil pointer fails marshaling
+//	omitempty  skip the zero value, or empty string, slice and map when marshaling,
+//	           can't be used with required or default
+//	default=v  the value set when the child is absent when unmarshaling, for
+//	           bool, numbers, string and the pointers to them
+//	min=v      the minimum of numbers, or the minimum length of string, slice
+//	           and map, checked when marshaling and unmarshaling
+//	max=v      the maximum, like min
+//	enum=name  the int32 field is the enum registered with the name, see RegisterEnum
+//
+// Violations of required, min, max and enum are returned as *FieldError.
+//
+// A []byte field tagged `y3:",unknown"` keeps the children which have no
+// matching field when unmarshaling, and they are appended unchanged when
+// marshaling, so a struct knowing only part of the fields can re-encode a
+// packet without dropping the others. They are appended after the known
+// fields, so their positions among the fields are not kept, and the duplicated
+// children of a known field are dropped as Unmarshal keeps only one of them.
+func Marshal(sid byte, v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, errors.New("y3: Marshal nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("y3: Marshal needs a struct, got %T", v)
+	}
+	return marshalStruct(sid, rv)
+}
+
+// Unmarshal decodes a node packet to the struct pointed by v, see Marshal for
+// the struct tags. Children without a matching field are ignored, unless the
+// struct has an unknown field.
+func Unmarshal(buf []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("y3: Unmarshal needs a non-nil pointer, got %T", v)
+	}
+	np := &NodePacket{}
+	if _, err := DecodeToNodePacket(buf, np); err != nil {
+		return err
+	}
+	return unmarshalNode(np, rv.Elem())
+}
+
+func marshalStruct(sid byte, v reflect.Value) ([]byte, error) {
+	fields, err := cachedStructFields(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	enc := NewNodePacketEncoder(sid)
+	var unknown []byte
+	for _, f := range fields {
+		fv := v.Field(f.index)
+		if f.unknown {
+			unknown = fv.Bytes()
+			continue
+		}
+		if f.omitempty && isEmptyValue(fv) {
+			continue
+		}
+		if f.required && (fv.Kind() == reflect.Pointer || fv.Kind() == reflect.Interface) && fv.IsNil() {
+			return nil, &FieldError{Struct: v.Type(), Field: f.name, SeqID: f.seqID, Err: ErrMissingField}
+		}
+		if err := f.checkRange(fv); err != nil {
+			return nil, &FieldError{Struct: v.Type(), Field: f.name, SeqID: f.seqID, Err: err}
+		}
+		if err := f.checkEnum(fv); err != nil {
+			return nil, &FieldError{Struct: v.Type(), Field: f.name, SeqID: f.seqID, Err: err}
+		}
+		buf, err := marshalValue(f.seqID, fv)
+		if err != nil {
+			return nil, err
+		}
+		enc.AddBytes(buf)
+	}
+	enc.AddBytes(unknown)
+	return enc.Encode(), nil
+}
+
+func marshalValue(sid byte, v reflect.Value) ([]byte, error) {
+	if set := customEncoder(v); set != nil {
+		enc := NewPrimitivePacketEncoder(sid)
+		if err := set(enc); err != nil {
+			return nil, fmt.Errorf("y3: marshal %v: %w", v.Type(), err)
+		}
+		return enc.Encode(), nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			enc := NewPrimitivePacketEncoder(sid)
+			enc.SetNull()
+			return enc.Encode(), nil
+		}
+		if v.Kind() == reflect.Interface {
+			return marshalOneof(sid, v)
+		}
+		return marshalValue(sid, v.Elem())
+	case reflect.Struct:
+		if v.Type() != timeType {
+			return marshalStruct(sid, v)
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return marshalSlice(sid, v)
+		}
+	case reflect.Map:
+		return marshalMap(sid, v)
+	}
+	return marshalPrimitive(sid, v)
+}
+
+func marshalPrimitive(sid byte, v reflect.Value) ([]byte, error) {
+	enc := NewPrimitivePacketEncoder(sid)
+	switch v.Kind() {
+	case reflect.Bool:
+		enc.SetBoolValue(v.Bool())
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		if e := EnumOf(v.Type()); e != nil {
+			if err := e.Check(int32(v.Int())); err != nil {
+				return nil, fmt.Errorf("y3: %w", err)
+			}
+		}
+		enc.SetInt32Value(int32(v.Int()))
+	case reflect.Int, reflect.Int64:
+		enc.SetInt64Value(v.Int())
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		enc.SetUInt32Value(uint32(v.Uint()))
+	case reflect.Uint, reflect.Uint64:
+		enc.SetUInt64Value(v.Uint())
+	case reflect.Float32:
+		enc.SetFloat32Value(float32(v.Float()))
+	case reflect.Float64:
+		enc.SetFloat64Value(v.Float())
+	case reflect.String:
+		enc.SetStringValue(v.String())
+	case reflect.Slice:
+		enc.SetBytesValue(v.Bytes())
+	case reflect.Struct:
+		enc.SetTimeValue(v.Interface().(time.Time))
+	default:
+		return nil, fmt.Errorf("y3: unsupported type %v", v.Type())
+	}
+	return enc.Encode(), nil
+}
+
+func marshalSlice(sid byte, v reflect.Value) ([]byte, error) {
+	enc := NewNodeSlicePacketEncoder(sid)
+	for i := 0; i < v.Len(); i++ {
+		buf, err := marshalValue(SliceElementSeqID, v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		enc.AddBytes(buf)
+	}
+	return enc.Encode(), nil
+}
+
+func marshalMap(sid byte, v reflect.Value) ([]byte, error) {
+	keys := v.MapKeys()
+	switch v.Type().Key().Kind() {
+	case reflect.String:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Int() < keys[j].Int() })
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Uint() < keys[j].Uint() })
+	default:
+		return nil, fmt.Errorf("y3: unsupported map key type %v", v.Type().Key())
+	}
+
+	enc := NewNodeSlicePacketEncoder(sid)
+	for _, k := range keys {
+		key, err := marshalPrimitive(MapKeySeqID, k)
+		if err != nil {
+			return nil, err
+		}
+		val, err := marshalValue(MapValueSeqID, v.MapIndex(k))
+		if err != nil {
+			return nil, err
+		}
+		entry := NewNodePacketEncoder(MapEntrySeqID)
+		entry.AddBytes(key)
+		entry.AddBytes(val)
+		enc.AddNodePacket(entry)
+	}
+	return enc.Encode(), nil
+}
+
+func marshalOneof(sid byte, v reflect.Value) ([]byte, error) {
+	variant := v.Elem()
+	vsid, ok := lookupOneofSeqID(v.Type(), variant.Type())
+	if !ok {
+		return nil, fmt.Errorf("y3: %v is not registered as a oneof variant of %v", variant.Type(), v.Type())
+	}
+	buf, err := marshalValue(vsid, variant)
+	if err != nil {
+		return nil, err
+	}
+	enc := NewOneofPacketEncoder(sid)
+	enc.AddBytes(buf)
+	return enc.Encode(), nil
+}
+
+func unmarshalStruct(np *NodePacket, v reflect.Value) error {
+	fields, err := cachedStructFields(v.Type())
+	if err != nil {
+		return err
+	}
+	unknown := -1
+	known := make([]byte, 0, len(fields))
+	for _, f := range fields {
+		if f.unknown {
+			unknown = f.index
+			continue
+		}
+		known = append(known, f.seqID)
+		fv := v.Field(f.index)
+		p, isPrimitive := np.PrimitivePackets[f.seqID]
+		n, isNode := np.NodePackets[f.seqID]
+		switch {
+		case isPrimitive && !(f.required && p.IsNull()):
+			err = unmarshalPrimitive(&p, fv)
+		case isNode:
+			err = unmarshalNode(&n, fv)
+		case f.required:
+			return &FieldError{Struct: v.Type(), Field: f.name, SeqID: f.seqID, Err: ErrMissingField}
+		case f.def.IsValid():
+			if fv.Kind() == reflect.Pointer {
+				// every struct has its own copy of the default value
+				fv.Set(reflect.New(fv.Type().Elem()))
+				fv.Elem().Set(f.def.Elem())
+			} else {
+				fv.Set(f.def)
+			}
+			continue
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := f.checkRange(fv); err != nil {
+			return &FieldError{Struct: v.Type(), Field: f.name, SeqID: f.seqID, Err: err}
+		}
+		if err := f.checkEnum(fv); err != nil {
+			return &FieldError{Struct: v.Type(), Field: f.name, SeqID: f.seqID, Err: err}
+		}
+	}
+	if unknown >= 0 {
+		v.Field(unknown).SetBytes(np.UnknownBytes(known...))
+	}
+	return nil
+}
+
+func unmarshalNode(np *NodePacket, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshalNode(np, v.Elem())
+	case reflect.Struct:
+		if v.Type() != timeType {
+			return unmarshalStruct(np, v)
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return unmarshalSlice(np, v)
+		}
+	case reflect.Map:
+		return unmarshalMap(np, v)
+	case reflect.Interface:
+		return unmarshalOneof(np, v)
+	}
+	return fmt.Errorf("y3: can not decode node packet %#x to %v", np.SeqID(), v.Type())
+}
+
+func unmarshalPrimitive(p *PrimitivePacket, v reflect.Value) error {
+	if p.IsNull() {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if decode := customDecoder(v.Type()); decode != nil {
+		if err := decode(p, v); err != nil {
+			return fmt.Errorf("y3: unmarshal %v: %w", v.Type(), err)
+		}
+		return nil
+	}
+
+	var err error
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshalPrimitive(p, v.Elem())
+	case reflect.Bool:
+		var b bool
+		b, err = p.ToBool()
+		v.SetBool(b)
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		var i int32
+		if i, err = p.ToInt32(); err == nil && v.OverflowInt(int64(i)) {
+			err = fmt.Errorf("y3: value %v overflows %v", i, v.Type())
+		} else if e := EnumOf(v.Type()); err == nil && e != nil {
+			if err = e.Check(i); err != nil {
+				return fmt.Errorf("y3: %w", err)
+			}
+		}
+		v.SetInt(int64(i))
+	case reflect.Int, reflect.Int64:
+		var i int64
+		i, err = p.ToInt64()
+		v.SetInt(i)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		var u uint32
+		if u, err = p.ToUInt32(); err == nil && v.OverflowUint(uint64(u)) {
+			err = fmt.Errorf("y3: value %v overflows %v", u, v.Type())
+		}
+		v.SetUint(uint64(u))
+	case reflect.Uint, reflect.Uint64:
+		var u uint64
+		u, err = p.ToUInt64()
+		v.SetUint(u)
+	case reflect.Float32:
+		var f float32
+		f, err = p.ToFloat32()
+		v.SetFloat(float64(f))
+	case reflect.Float64:
+		var f float64
+		f, err = p.ToFloat64()
+		v.SetFloat(f)
+	case reflect.String:
+		var s string
+		s, err = p.ToUTF8String()
+		v.SetString(s)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("y3: can not decode primitive packet %#x to %v", p.SeqID(), v.Type())
+		}
+		v.SetBytes(append([]byte{}, p.ToBytes()...))
+	case reflect.Struct:
+		if v.Type() != timeType {
+			return fmt.Errorf("y3: can not decode primitive packet %#x to %v", p.SeqID(), v.Type())
+		}
+		var t time.Time
+		t, err = p.ToTime()
+		v.Set(reflect.ValueOf(t))
+	default:
+		return fmt.Errorf("y3: can not decode primitive packet %#x to %v", p.SeqID(), v.Type())
+	}
+	return err
+}
+
+func unmarshalSlice(np *NodePacket, v reflect.Value) error {
+	s := reflect.MakeSlice(v.Type(), 0, 0)
+	err := eachPacket(np.GetValBuf(), func(isNode bool, n *NodePacket, p *PrimitivePacket) error {
+		elem := reflect.New(v.Type().Elem()).Elem()
+		var err error
+		if isNode {
+			err = unmarshalNode(n, elem)
+		} else {
+			err = unmarshalPrimitive(p, elem)
+		}
+		s = reflect.Append(s, elem)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	v.Set(s)
+	return nil
+}
+
+func unmarshalMap(np *NodePacket, v reflect.Value) error {
+	m := reflect.MakeMap(v.Type())
+	err := eachPacket(np.GetValBuf(), func(isNode bool, entry *NodePacket, _ *PrimitivePacket) error {
+		if !isNode {
+			return errors.New("y3: map entry should be a node")
+		}
+		kp, ok := entry.PrimitivePackets[MapKeySeqID]
+		if !ok {
+			return errors.New("y3: map entry without key")
+		}
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := unmarshalPrimitive(&kp, key); err != nil {
+			return err
+		}
+		if m.MapIndex(key).IsValid() {
+			return fmt.Errorf("y3: duplicated map key %v", key)
+		}
+		val := reflect.New(v.Type().Elem()).Elem()
+		if p, ok := entry.PrimitivePackets[MapValueSeqID]; ok {
+			if err := unmarshalPrimitive(&p, val); err != nil {
+				return err
+			}
+		} else if n, ok := entry.NodePackets[MapValueSeqID]; ok {
+			if err := unmarshalNode(&n, val); err != nil {
+				return err
+			}
+		} else {
+			return errors.New("y3: map entry without value")
+		}
+		m.SetMapIndex(key, val)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	v.Set(m)
+	return nil
+}
+
+func unmarshalOneof(np *NodePacket, v reflect.Value) error {
+	which, err := whichOneof(np)
+	if err != nil {
+		return err
+	}
+	typ, ok := lookupOneofType(v.Type(), which)
+	if !ok {
+		return fmt.Errorf("y3: SeqID %#x is not registered as a oneof variant of %v", which, v.Type())
+	}
+	variant := reflect.New(typ).Elem()
+	if n, ok := np.NodePackets[which]; ok {
+		err = unmarshalNode(&n, variant)
+	} else {
+		p := np.PrimitivePackets[which]
+		err = unmarshalPrimitive(&p, variant)
+	}
+	if err != nil {
+		return err
+	}
+	v.Set(variant)
+	return nil
+}
@@ -0,0 +1,293 @@
+package y3
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrMissingField is returned when a required field is absent or null
+	ErrMissingField = errors.New("required field is missing")
+	// ErrOutOfRange is returned when a value violates the min or max option
+	ErrOutOfRange = errors.New("value out of range")
+)
+
+// FieldError describes an error of a struct field when marshaling or unmarshaling,
+// Err is ErrMissingField or wraps ErrOutOfRange
+type FieldError struct {
+	// Struct is the type of the struct
+	Struct reflect.Type
+	// Field is the name of the struct field
+	Field string
+	SeqID byte
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("y3: %v.%v (%#x): %v", e.Struct, e.Field, e.SeqID, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// structField describes a field with `y3` tag
+type structField struct {
+	index int
+	name  string
+	seqID byte
+	// unknown field stores the raw bytes of unknown children
+	unknown   bool
+	required  bool
+	omitempty bool
+	// def is the value set when the child is absent, invalid if no default
+	def      reflect.Value
+	min, max *float64
+	// enum is declared by the enum option of int32 fields
+	enum *Enum
+}
+
+var structFieldsCache sync.Map // map[reflect.Type][]structField
+
+func cachedStructFields(t reflect.Type) ([]structField, error) {
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.([]structField), nil
+	}
+	fields, err := typeFields(t)
+	if err != nil {
+		return nil, err
+	}
+	structFieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+func typeFields(t reflect.Type) ([]structField, error) {
+	var fields []structField
+	seen := map[byte]string{}
+	hasUnknown := false
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("y3")
+		if !ok || tag == "-" || !sf.IsExported() {
+			continue
+		}
+		f, err := parseStructTag(tag, sf.Type)
+		if err != nil {
+			return nil, fmt.Errorf("y3: %v.%v: %v", t, sf.Name, err)
+		}
+		if f.unknown {
+			if sf.Type != bytesType {
+				return nil, fmt.Errorf("y3: %v.%v: unknown field should be []byte", t, sf.Name)
+			}
+			if hasUnknown {
+				return nil, fmt.Errorf("y3: %v has more than one unknown field", t)
+			}
+			hasUnknown = true
+		} else if name, ok := seen[f.seqID]; ok {
+			return nil, fmt.Errorf("y3: %v.%v and %v.%v have the same SeqID %#x", t, name, t, sf.Name, f.seqID)
+		}
+		if !f.unknown {
+			seen[f.seqID] = sf.Name
+		}
+		f.index = i
+		f.name = sf.Name
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// parseStructTag parses the SeqID and the options of the tag, e.g.
+// `y3:"0x03,required,min=0,max=100"`, typ is the type of the field
+func parseStructTag(tag string, typ reflect.Type) (structField, error) {
+	opts := strings.Split(tag, ",")
+	if strings.TrimSpace(opts[0]) == "" && len(opts) == 2 && strings.TrimSpace(opts[1]) == "unknown" {
+		return structField{unknown: true}, nil
+	}
+	sid, err := strconv.ParseUint(strings.TrimSpace(opts[0]), 0, 8)
+	if err != nil || sid > 0x3F {
+		return structField{}, fmt.Errorf("invalid SeqID %q", opts[0])
+	}
+	f := structField{seqID: byte(sid)}
+
+	for _, opt := range opts[1:] {
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(opt), "=")
+		switch {
+		case name == "required" && !hasArg:
+			f.required = true
+		case name == "omitempty" && !hasArg:
+			f.omitempty = true
+		case name == "default" && hasArg:
+			if f.def, err = parseDefault(arg, typ); err != nil {
+				return f, err
+			}
+		case name == "enum" && hasArg:
+			if elemType(typ).Kind() != reflect.Int32 {
+				return f, fmt.Errorf("enum is not supported by %v", typ)
+			}
+			if f.enum = LookupEnum(arg); f.enum == nil {
+				return f, fmt.Errorf("enum %s is not registered", arg)
+			}
+		case (name == "min" || name == "max") && hasArg:
+			if !hasRange(typ) {
+				return f, fmt.Errorf("%s is not supported by %v", name, typ)
+			}
+			v, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s %q", name, arg)
+			}
+			if name == "min" {
+				f.min = &v
+			} else {
+				f.max = &v
+			}
+		default:
+			return f, fmt.Errorf("unknown option %q", opt)
+		}
+	}
+	if f.required && f.def.IsValid() {
+		return f, errors.New("required field can not have a default value")
+	}
+	if f.required && f.omitempty {
+		return f, errors.New("required field can not be omitempty")
+	}
+	if f.omitempty && f.def.IsValid() {
+		// the omitted zero value would be decoded as the default
+		return f, errors.New("omitempty field can not have a default value")
+	}
+	return f, nil
+}
+
+// parseDefault parses the default value of bool, numbers, string and the pointers to them
+func parseDefault(s string, typ reflect.Type) (reflect.Value, error) {
+	if typ.Kind() == reflect.Pointer {
+		elem, err := parseDefault(s, typ.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v := reflect.New(typ.Elem())
+		v.Elem().Set(elem)
+		return v, nil
+	}
+
+	v := reflect.New(typ).Elem()
+	var err error
+	switch typ.Kind() {
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(s, 0, typ.Bits())
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(s, 0, typ.Bits())
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, typ.Bits())
+		v.SetFloat(f)
+	case reflect.String:
+		v.SetString(s)
+	default:
+		return v, fmt.Errorf("default is not supported by %v", typ)
+	}
+	if err != nil {
+		return v, fmt.Errorf("invalid default %q", s)
+	}
+	return v, nil
+}
+
+// hasRange returns true if min and max can be checked, numbers are checked by
+// their values, strings, slices and maps by their lengths
+func hasRange(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64,
+		reflect.String, reflect.Slice, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// checkRange checks the value v of the field against min and max
+func (f *structField) checkRange(v reflect.Value) error {
+	if f.min == nil && f.max == nil {
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	var x float64
+	what := "value"
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		x = v.Float()
+	default:
+		x = float64(v.Len())
+		what = "length"
+	}
+	if f.min != nil && x < *f.min {
+		return fmt.Errorf("%w: %s %v is less than min %v", ErrOutOfRange, what, x, *f.min)
+	}
+	if f.max != nil && x > *f.max {
+		return fmt.Errorf("%w: %s %v is greater than max %v", ErrOutOfRange, what, x, *f.max)
+	}
+	return nil
+}
+
+// elemType returns the element type of pointer and slice types
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t
+}
+
+// checkEnum checks the value v of the field against the enum option
+func (f *structField) checkEnum(v reflect.Value) error {
+	if f.enum == nil {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return f.checkEnum(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := f.checkEnum(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Int32:
+		return f.enum.Check(int32(v.Int()))
+	}
+	return nil
+}
+
+// isEmptyValue reports whether v is skipped by omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
@@ -0,0 +1,314 @@
+package y3
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testBar struct {
+	Name string `y3:"0x04"`
+}
+
+type testFoo struct {
+	ID       int32             `y3:"0x02"`
+	Bar      *testBar          `y3:"0x03"`
+	Tags     []string          `y3:"0x05"`
+	Labels   map[string]string `y3:"0x06"`
+	Payload  []byte            `y3:"0x07"`
+	Temp     float64           `y3:"0x08"`
+	OK       bool              `y3:"0x09"`
+	At       time.Time         `y3:"0x0A"`
+	Note     *string           `y3:"0x0B"`
+	Bars     []testBar         `y3:"0x0C"`
+	Count    uint64            `y3:"0x0D"`
+	Ignored  string
+	Excluded string `y3:"-"`
+}
+
+type testDupSeqID struct {
+	A int32 `y3:"0x01"`
+	B int32 `y3:"1"`
+}
+
+func TestMarshalSimple(t *testing.T) {
+	// the object in the encode example of README.md
+	buf, err := Marshal(0x01, struct {
+		ID  int32    `y3:"0x02"`
+		Bar *testBar `y3:"0x03"`
+	}{ID: -1, Bar: &testBar{Name: "C"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x81, 0x08, 0x02, 0x01, 0xFF, 0x83, 0x03, 0x04, 0x01, 0x43}, buf)
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	note := "yomo"
+	foo := testFoo{
+		ID:       -1,
+		Bar:      &testBar{Name: "C"},
+		Tags:     []string{"a", "b"},
+		Labels:   map[string]string{"x": "1", "y": "2"},
+		Payload:  []byte{0x01, 0x02},
+		Temp:     36.5,
+		OK:       true,
+		At:       time.Unix(1617262800, 0),
+		Note:     &note,
+		Bars:     []testBar{{Name: "D"}, {Name: "E"}},
+		Count:    1 << 40,
+		Ignored:  "ignored",
+		Excluded: "excluded",
+	}
+	buf, err := Marshal(0x10, &foo)
+	assert.NoError(t, err)
+
+	var res testFoo
+	assert.NoError(t, Unmarshal(buf, &res))
+	assert.Equal(t, foo.ID, res.ID)
+	assert.Equal(t, foo.Bar, res.Bar)
+	assert.Equal(t, foo.Tags, res.Tags)
+	assert.Equal(t, foo.Labels, res.Labels)
+	assert.Equal(t, foo.Payload, res.Payload)
+	assert.Equal(t, foo.Temp, res.Temp)
+	assert.Equal(t, foo.OK, res.OK)
+	assert.True(t, foo.At.Equal(res.At))
+	assert.Equal(t, note, *res.Note)
+	assert.Equal(t, foo.Bars, res.Bars)
+	assert.Equal(t, foo.Count, res.Count)
+	assert.Empty(t, res.Ignored)
+	assert.Empty(t, res.Excluded)
+}
+
+func TestMarshalNilPointerAsNull(t *testing.T) {
+	buf, err := Marshal(0x01, struct {
+		Note *string `y3:"0x01"`
+	}{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x81, 0x02, 0x41, 0x00}, buf)
+
+	note := "previous"
+	res := struct {
+		Note *string `y3:"0x01"`
+	}{Note: &note}
+	assert.NoError(t, Unmarshal(buf, &res))
+	assert.Nil(t, res.Note)
+}
+
+func TestMarshalErrors(t *testing.T) {
+	_, err := Marshal(0x01, 1)
+	assert.EqualError(t, err, "y3: Marshal needs a struct, got int")
+
+	_, err = Marshal(0x01, testDupSeqID{})
+	assert.EqualError(t, err, "y3: y3.testDupSeqID.A and y3.testDupSeqID.B have the same SeqID 0x1")
+
+	_, err = Marshal(0x01, struct {
+		A int32 `y3:"0x40"`
+	}{})
+	assert.Error(t, err)
+
+	_, err = Marshal(0x01, struct {
+		A chan int `y3:"0x01"`
+	}{})
+	assert.EqualError(t, err, "y3: unsupported type chan int")
+
+	var foo testFoo
+	assert.EqualError(t, Unmarshal([]byte{0x81, 0x00}, foo), "y3: Unmarshal needs a non-nil pointer, got y3.testFoo")
+}
+
+func TestUnmarshalOverflow(t *testing.T) {
+	buf, err := Marshal(0x01, struct {
+		A int32 `y3:"0x01"`
+	}{A: 300})
+	assert.NoError(t, err)
+
+	var res struct {
+		A int8 `y3:"0x01"`
+	}
+	assert.EqualError(t, Unmarshal(buf, &res), "y3: value 300 overflows int8")
+}
+
+func TestUnmarshalTypeMismatch(t *testing.T) {
+	buf, err := Marshal(0x01, struct {
+		A string `y3:"0x01"`
+	}{A: "yomo"})
+	assert.NoError(t, err)
+
+	var res struct {
+		A testBar `y3:"0x01"`
+	}
+	assert.EqualError(t, Unmarshal(buf, &res), "y3: can not decode primitive packet 0x1 to y3.testBar")
+}
+
+type testPartialFoo struct {
+	ID      int32  `y3:"0x02"`
+	Unknown []byte `y3:",unknown"`
+}
+
+func TestUnmarshalKeepsUnknown(t *testing.T) {
+	buf, err := Marshal(0x10, &testFoo{ID: 1, Bar: &testBar{Name: "C"}, Tags: []string{"a"}})
+	assert.NoError(t, err)
+
+	var partial testPartialFoo
+	assert.NoError(t, Unmarshal(buf, &partial))
+	assert.EqualValues(t, 1, partial.ID)
+	assert.NotEmpty(t, partial.Unknown)
+
+	partial.ID = 2
+	buf, err = Marshal(0x10, &partial)
+	assert.NoError(t, err)
+
+	var res testFoo
+	assert.NoError(t, Unmarshal(buf, &res))
+	assert.EqualValues(t, 2, res.ID)
+	assert.Equal(t, &testBar{Name: "C"}, res.Bar)
+	assert.Equal(t, []string{"a"}, res.Tags)
+
+	// no unknown children
+	buf, err = Marshal(0x10, &testPartialFoo{ID: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x90, 0x03, 0x02, 0x01, 0x03}, buf)
+	assert.NoError(t, Unmarshal(buf, &partial))
+	assert.Nil(t, partial.Unknown)
+
+	// the unknown children follow the known ones, and the duplicated known
+	// child is dropped
+	buf, err = ParseText(`0x10 { 0x05: "x" 0x02: 4 0x02: 5 }`)
+	assert.NoError(t, err)
+	assert.NoError(t, Unmarshal(buf, &partial))
+	buf, err = Marshal(0x10, &partial)
+	assert.NoError(t, err)
+	assert.Equal(t, mustParseText(t, `0x10 { 0x02: 5 0x05: "x" }`), buf)
+}
+
+func TestUnknownFieldErrors(t *testing.T) {
+	_, err := Marshal(0x01, struct {
+		Unknown string `y3:",unknown"`
+	}{})
+	assert.EqualError(t, err, "y3: struct { Unknown string \"y3:\\\",unknown\\\"\" }.Unknown: unknown field should be []byte")
+
+	_, err = Marshal(0x01, struct {
+		A []byte `y3:",unknown"`
+		B []byte `y3:",unknown"`
+	}{})
+	assert.Error(t, err)
+}
+
+type testOptions struct {
+	ID    int32    `y3:"0x01,required"`
+	Level int32    `y3:"0x02,default=5,min=0,max=100"`
+	Name  *string  `y3:"0x03,default=yomo"`
+	Tags  []string `y3:"0x04,omitempty,max=2"`
+	Note  string   `y3:"0x05,omitempty"`
+	Bar   *testBar `y3:"0x06,required"`
+}
+
+func TestMarshalOptions(t *testing.T) {
+	buf, err := Marshal(0x10, &testOptions{ID: 1, Level: 10, Bar: &testBar{}})
+	assert.NoError(t, err)
+	// Tags and Note are omitted, Name is null
+	// 0x90 { 0x01: 1, 0x02: 10, 0x03: null, 0x86 { 0x04: "" } }
+	assert.Equal(t, []byte{0x90, 0x0C, 0x01, 0x01, 0x01, 0x02, 0x01, 0x0A, 0x43, 0x00, 0x86, 0x02, 0x04, 0x00}, buf)
+
+	var res testOptions
+	assert.NoError(t, Unmarshal(buf, &res))
+	assert.EqualValues(t, 10, res.Level)
+	// null is not absent
+	assert.Nil(t, res.Name)
+}
+
+func TestUnmarshalDefault(t *testing.T) {
+	// 0x90 { 0x01: 1, 0x86 {} }
+	buf := []byte{0x90, 0x05, 0x01, 0x01, 0x01, 0x86, 0x00}
+	var res, res2 testOptions
+	assert.NoError(t, Unmarshal(buf, &res))
+	assert.EqualValues(t, 1, res.ID)
+	assert.EqualValues(t, 5, res.Level)
+	assert.Equal(t, "yomo", *res.Name)
+
+	// the default pointer is not shared
+	*res.Name = "changed"
+	assert.NoError(t, Unmarshal(buf, &res2))
+	assert.Equal(t, "yomo", *res2.Name)
+
+	// the zero value round trips rather than replaced by the default
+	zero, err := Marshal(0x10, &testOptions{ID: 1, Level: 0, Bar: &testBar{}})
+	assert.NoError(t, err)
+	res = testOptions{}
+	assert.NoError(t, Unmarshal(zero, &res))
+	assert.EqualValues(t, 0, res.Level)
+}
+
+func TestRequiredField(t *testing.T) {
+	_, err := Marshal(0x10, &testOptions{ID: 1})
+	assert.ErrorIs(t, err, ErrMissingField)
+	assert.EqualError(t, err, "y3: y3.testOptions.Bar (0x6): required field is missing")
+
+	var res testOptions
+	// 0x90 { 0x86 {} }
+	err = Unmarshal([]byte{0x90, 0x02, 0x86, 0x00}, &res)
+	var fe *FieldError
+	assert.ErrorAs(t, err, &fe)
+	assert.Equal(t, &FieldError{Struct: reflect.TypeOf(res), Field: "ID", SeqID: 0x01, Err: ErrMissingField}, fe)
+
+	// null is missing for the required field
+	// 0x90 { 0x01: null, 0x86 {} }
+	err = Unmarshal([]byte{0x90, 0x04, 0x41, 0x00, 0x86, 0x00}, &res)
+	assert.ErrorIs(t, err, ErrMissingField)
+}
+
+func TestFieldRange(t *testing.T) {
+	_, err := Marshal(0x10, &testOptions{ID: 1, Level: 101, Bar: &testBar{}})
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	assert.EqualError(t, err, "y3: y3.testOptions.Level (0x2): value out of range: value 101 is greater than max 100")
+
+	_, err = Marshal(0x10, &testOptions{ID: 1, Tags: []string{"a", "b", "c"}, Bar: &testBar{}})
+	assert.EqualError(t, err, "y3: y3.testOptions.Tags (0x4): value out of range: length 3 is greater than max 2")
+
+	// 0x90 { 0x01: 1, 0x02: -1, 0x86 {} }
+	var res testOptions
+	err = Unmarshal([]byte{0x90, 0x08, 0x01, 0x01, 0x01, 0x02, 0x01, 0xFF, 0x86, 0x00}, &res)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	assert.EqualError(t, err, "y3: y3.testOptions.Level (0x2): value out of range: value -1 is less than min 0")
+}
+
+func TestInvalidOptions(t *testing.T) {
+	tests := []struct {
+		v   any
+		err string
+	}{
+		{struct {
+			A int32 `y3:"0x01,unique"`
+		}{}, `unknown option "unique"`},
+		{struct {
+			A int32 `y3:"0x01,default=x"`
+		}{}, `invalid default "x"`},
+		{struct {
+			A int8 `y3:"0x01,default=300"`
+		}{}, `invalid default "300"`},
+		{struct {
+			A []int32 `y3:"0x01,default=1"`
+		}{}, "default is not supported by []int32"},
+		{struct {
+			A bool `y3:"0x01,max=1"`
+		}{}, "max is not supported by bool"},
+		{struct {
+			A int32 `y3:"0x01,min=a"`
+		}{}, `invalid min "a"`},
+		{struct {
+			A int32 `y3:"0x01,required,default=1"`
+		}{}, "required field can not have a default value"},
+		{struct {
+			A int32 `y3:"0x01,required,omitempty"`
+		}{}, "required field can not be omitempty"},
+		{struct {
+			A int32 `y3:"0x01,omitempty,default=5"`
+		}{}, "omitempty field can not have a default value"},
+	}
+	for _, tt := range tests {
+		_, err := Marshal(0x01, tt.v)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), tt.err)
+		}
+	}
+}
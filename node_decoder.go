@@ -3,6 +3,7 @@ package y3
 import (
 	"bytes"
 	"errors"
+	"fmt"
 
 	"github.com/yomorun/y3/encoding"
 	"github.com/yomorun/y3/utils"
@@ -33,7 +34,7 @@ func DecodeToNodePacket(buf []byte, pct *NodePacket) (consumedBytes int, err err
 	}
 
 	pct.basePacket = &basePacket{
-		valbuf: buf,
+		valbuf: []byte{},
 		buf:    &bytes.Buffer{},
 	}
 
@@ -71,6 +72,9 @@ func DecodeToNodePacket(buf []byte, pct *NodePacket) (consumedBytes int, err err
 		return pos, errors.New("found L of V smaller than 0")
 	}
 	endPos := pos + vl
+	if endPos > len(buf) {
+		return pos, fmt.Errorf("beyond the boundary, pos=%v, endPos=%v", pos, endPos)
+	}
 	pct.basePacket.valbuf = buf[pos:endPos]
 	pct.buf.Write(buf[pos:endPos])
 
@@ -94,3 +98,18 @@ func DecodeToNodePacket(buf []byte, pct *NodePacket) (consumedBytes int, err err
 	consumedBytes = endPos
 	return consumedBytes, nil
 }
+
+// eachPacket calls fn with every packet in buf, buf is the Val of a node packet
+func eachPacket(buf []byte, fn func(isNode bool, np *NodePacket, pp *PrimitivePacket) error) error {
+	for pos := 0; pos < len(buf); {
+		n, isNode, np, pp, err := parsePayload(buf[pos:])
+		if err != nil {
+			return err
+		}
+		pos += n
+		if err := fn(isNode, np, pp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 // NodePacketEncoder used for encode a node packet
 type NodePacketEncoder struct {
 	*encoder
+	// canonical describes if the children are sorted by SeqID when encoding
+	canonical bool
 }
 
 // NewNodePacketEncoder returns an Encoder for node packet
@@ -22,19 +24,19 @@ func NewNodePacketEncoder(sid byte) *NodePacketEncoder {
 	return nodeEnc
 }
 
-// // NewNodeSlicePacketEncoder returns an Encoder for node packet that is a slice
-// func NewNodeSlicePacketEncoder(sid byte) *NodePacketEncoder {
-// 	nodeEnc := &NodePacketEncoder{
-// 		encoder: encoder{
-// 			isNode:  true,
-// 			isArray: true,
-// 			buf:     new(bytes.Buffer),
-// 		},
-// 	}
+// NewNodeSlicePacketEncoder returns an Encoder for node packet that is a slice
+func NewNodeSlicePacketEncoder(sid byte) *NodePacketEncoder {
+	nodeEnc := &NodePacketEncoder{
+		encoder: &encoder{
+			isNode:  true,
+			isArray: true,
+			buf:     new(bytes.Buffer),
+		},
+	}
 
-// 	nodeEnc.seqID = sid
-// 	return nodeEnc
-// }
+	nodeEnc.seqID = sid
+	return nodeEnc
+}
 
 // AddNodePacket add new node to this node
 func (enc *NodePacketEncoder) AddNodePacket(np *NodePacketEncoder) {
@@ -45,3 +47,88 @@ func (enc *NodePacketEncoder) AddNodePacket(np *NodePacketEncoder) {
 func (enc *NodePacketEncoder) AddPrimitivePacket(np *PrimitivePacketEncoder) {
 	enc.addRawPacket(np)
 }
+
+// SetNodePacket replaces the first child with the same SeqID as np, or adds
+// np if not exists
+func (enc *NodePacketEncoder) SetNodePacket(np *NodePacketEncoder) {
+	enc.set(np.Encode())
+}
+
+// SetPrimitivePacket replaces the first child with the same SeqID as pp, or
+// adds pp if not exists
+func (enc *NodePacketEncoder) SetPrimitivePacket(pp *PrimitivePacketEncoder) {
+	enc.set(pp.Encode())
+}
+
+// set replaces the first child with the same SeqID as the encoded packet, or
+// adds the packet if not exists
+func (enc *NodePacketEncoder) set(packet []byte) {
+	sid := packet[0] & 0x3F
+	start, end := -1, -1
+	_ = eachRawPacket(enc.valbuf, 0, func(p *rawPacket) error {
+		if start < 0 && p.tag.SeqID() == sid {
+			start, end = p.off, p.off+p.size()
+		}
+		return nil
+	})
+	if start < 0 {
+		enc.addRawPacket(rawEncoder(packet))
+		return
+	}
+	enc.reset()
+	valbuf := make([]byte, 0, len(enc.valbuf)-(end-start)+len(packet))
+	valbuf = append(valbuf, enc.valbuf[:start]...)
+	valbuf = append(valbuf, packet...)
+	enc.valbuf = append(valbuf, enc.valbuf[end:]...)
+}
+
+// Remove deletes all the children with the SeqID, returns false if not exists
+// or the Val is malformed
+func (enc *NodePacketEncoder) Remove(sid byte) bool {
+	var valbuf []byte
+	var found bool
+	err := eachRawPacket(enc.valbuf, 0, func(p *rawPacket) error {
+		if p.tag.SeqID() == sid {
+			found = true
+		} else {
+			valbuf = append(valbuf, p.bytes()...)
+		}
+		return nil
+	})
+	if err != nil || !found {
+		return false
+	}
+	enc.reset()
+	enc.valbuf = valbuf
+	return true
+}
+
+// rawEncoder is the encoded bytes of packet as iEncoder
+type rawEncoder []byte
+
+func (r rawEncoder) Encode() []byte {
+	return r
+}
+
+// SetCanonical makes Encode write the children in canonical order, sorted by
+// SeqID, see Canonicalize. The elements of a slice keep their order. The Val
+// added by AddBytes is kept as is if it's malformed.
+func (enc *NodePacketEncoder) SetCanonical(canonical bool) {
+	enc.canonical = canonical
+}
+
+// Encode returns a final Y3 encoded byte slice
+func (enc *NodePacketEncoder) Encode() []byte {
+	if enc.canonical && !enc.complete {
+		tag := byte(0x80)
+		if enc.isArray {
+			tag |= 0x40
+		}
+		if err := Validate(appendPacket(nil, tag, enc.valbuf), nil); err == nil {
+			if val, err := canonicalVal(enc.valbuf, 0, enc.isArray, false); err == nil {
+				enc.valbuf = val
+			}
+		}
+	}
+	return enc.encoder.Encode()
+}
@@ -8,3 +8,61 @@ type NodePacket struct {
 	// PrimitivePackets store all the primitive packets
 	PrimitivePackets map[byte]PrimitivePacket
 }
+
+// ToEncoder returns an encoder of the node with the encoded bytes of the
+// children, so the children can be changed by Set*, Remove and Add* methods
+// before re-encoding, and the unchanged children are kept as is
+func (n *NodePacket) ToEncoder() *NodePacketEncoder {
+	var enc *NodePacketEncoder
+	if n.IsSlice() {
+		enc = NewNodeSlicePacketEncoder(n.SeqID())
+	} else {
+		enc = NewNodePacketEncoder(n.SeqID())
+	}
+	enc.valbuf = append([]byte{}, n.GetValBuf()...)
+	return enc
+}
+
+// IsPresent returns true if the node has a child with the SeqID, a null child is present
+func (n *NodePacket) IsPresent(sid byte) bool {
+	if _, ok := n.PrimitivePackets[sid]; ok {
+		return true
+	}
+	_, ok := n.NodePackets[sid]
+	return ok
+}
+
+// IsNull returns true if the child with the SeqID is an explicit null
+func (n *NodePacket) IsNull(sid byte) bool {
+	p, ok := n.PrimitivePackets[sid]
+	return ok && p.IsNull()
+}
+
+// UnknownBytes returns the raw bytes of the children whose SeqID is not in
+// known, in the order they are encoded. The result can be added back by
+// AddBytes when re-encoding, so the children are kept unchanged, but they
+// follow the known children instead of their original positions. All the
+// children with a known SeqID are skipped, including the duplicated ones.
+//
+// The Val of a node decoded by DecodeToNodePacket is well-formed, otherwise
+// the result is truncated before the first malformed child.
+func (n *NodePacket) UnknownBytes(known ...byte) []byte {
+	var res []byte
+	// the error only truncates the result, see above
+	_ = eachPacket(n.GetValBuf(), func(isNode bool, np *NodePacket, pp *PrimitivePacket) error {
+		var p *basePacket
+		if isNode {
+			p = np.basePacket
+		} else {
+			p = pp.basePacket
+		}
+		for _, sid := range known {
+			if p.SeqID() == sid {
+				return nil
+			}
+		}
+		res = append(res, p.GetRawBytes()...)
+		return nil
+	})
+	return res
+}
@@ -216,3 +216,98 @@ func TestComplexNodes(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, -2, vn2p1)
 }
+
+func TestTruncatedNode(t *testing.T) {
+	res := &NodePacket{}
+	_, err := DecodeToNodePacket([]byte{0x81, 0x05, 0x01, 0x01}, res)
+	assert.EqualError(t, err, "beyond the boundary, pos=2, endPos=7")
+}
+
+// Assume a JSON object like this：
+// '0x05': {
+//   '0x01': null,
+//   '0x02': "",
+// }
+func TestNodePresence(t *testing.T) {
+	buf := []byte{0x85, 0x04, 0x41, 0x00, 0x02, 0x00}
+	res := &NodePacket{}
+	_, err := DecodeToNodePacket(buf, res)
+	assert.NoError(t, err)
+
+	assert.True(t, res.IsPresent(0x01))
+	assert.True(t, res.IsNull(0x01))
+	assert.True(t, res.IsPresent(0x02))
+	assert.False(t, res.IsNull(0x02))
+	assert.False(t, res.IsPresent(0x03))
+	assert.False(t, res.IsNull(0x03))
+}
+
+// Assume a JSON object like this：
+// '0x05': {
+//   '0x01': -1,
+//   '0x83': { '0x04': "C" },
+//   '0x02': "",
+// }
+func TestNodeUnknownBytes(t *testing.T) {
+	buf := []byte{0x85, 0x0A, 0x01, 0x01, 0xFF, 0x83, 0x03, 0x04, 0x01, 0x43, 0x02, 0x00}
+	res := &NodePacket{}
+	_, err := DecodeToNodePacket(buf, res)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []byte{0x83, 0x03, 0x04, 0x01, 0x43}, res.UnknownBytes(0x01, 0x02))
+	assert.Equal(t, []byte{0x01, 0x01, 0xFF, 0x02, 0x00}, res.UnknownBytes(0x03))
+	assert.Nil(t, res.UnknownBytes(0x01, 0x02, 0x03))
+	assert.Equal(t, buf[2:], res.UnknownBytes())
+
+	// the duplicated known children are skipped
+	buf, err = ParseText(`0x05 { 0x01: 1 0x03: 3 0x01: 2 }`)
+	assert.NoError(t, err)
+	_, err = DecodeToNodePacket(buf, res)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x03, 0x01, 0x03}, res.UnknownBytes(0x01))
+
+	// the malformed Val is truncated
+	res = &NodePacket{basePacket: &basePacket{valbuf: []byte{0x01, 0x01, 0xFF, 0x02, 0x05, 0x01}}}
+	assert.Equal(t, []byte{0x01, 0x01, 0xFF}, res.UnknownBytes(0x03))
+}
+
+func TestNodeToEncoder(t *testing.T) {
+	buf, err := ParseText(`0x3F { 0x2F { 0x01: "yomo" } 0x02: 1 0x03: "x" 0x03: "y" }`)
+	assert.NoError(t, err)
+	var np NodePacket
+	_, err = DecodeToNodePacket(buf, &np)
+	assert.NoError(t, err)
+
+	enc := np.ToEncoder()
+	assert.Equal(t, buf, enc.Encode())
+
+	p := NewPrimitivePacketEncoder(0x02)
+	p.SetStringValue("z")
+	enc.SetPrimitivePacket(p)
+	assert.True(t, enc.Remove(0x03))
+	assert.False(t, enc.Remove(0x04))
+	metaPacket := np.NodePackets[0x2F]
+	meta := metaPacket.ToEncoder()
+	id := NewPrimitivePacketEncoder(0x02)
+	id.SetInt32Value(1)
+	meta.SetPrimitivePacket(id)
+	enc.SetNodePacket(meta)
+	want, err := ParseText(`0x3F { 0x2F { 0x01: "yomo" 0x02: 1 } 0x02: "z" }`)
+	assert.NoError(t, err)
+	assert.Equal(t, want, enc.Encode())
+
+	// the encoder can be changed after encoding
+	encoded := enc.Encode()
+	list := NewNodeSlicePacketEncoder(0x05)
+	enc.SetNodePacket(list)
+	want, err = ParseText(`0x3F { 0x2F { 0x01: "yomo" 0x02: 1 } 0x02: "z" 0x05 [] }`)
+	assert.NoError(t, err)
+	assert.Equal(t, want, enc.Encode())
+	assert.Equal(t, []byte{0xBF, 0x0E}, encoded[:2])
+
+	// the slice keeps its flag
+	_, err = DecodeToNodePacket(want, &np)
+	assert.NoError(t, err)
+	list2 := np.NodePackets[0x05]
+	assert.Equal(t, []byte{0xC5, 0x00}, list2.ToEncoder().Encode())
+}
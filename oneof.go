@@ -0,0 +1,169 @@
+package y3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var (
+	// ErrOneofEmpty describes a oneof packet without child
+	ErrOneofEmpty = errors.New("y3: oneof packet has no child")
+	// ErrOneofMultiple describes a oneof packet with more than one child
+	ErrOneofMultiple = errors.New("y3: oneof packet has more than one child")
+)
+
+// OneofPacketEncoder used for encode a oneof packet, which is a node packet
+// holds exactly one child, the SeqID of the child tells which kind is set
+type OneofPacketEncoder struct {
+	*encoder
+}
+
+// NewOneofPacketEncoder returns an Encoder for oneof packet
+func NewOneofPacketEncoder(sid byte) *OneofPacketEncoder {
+	oneofEnc := &OneofPacketEncoder{
+		encoder: &encoder{
+			isNode: true,
+			buf:    new(bytes.Buffer),
+		},
+	}
+
+	oneofEnc.seqID = sid
+	return oneofEnc
+}
+
+// SetNodePacket set the node as the only child, replaces the previous one
+func (enc *OneofPacketEncoder) SetNodePacket(np *NodePacketEncoder) {
+	enc.valbuf = nil
+	enc.addRawPacket(np)
+}
+
+// SetPrimitivePacket set the primitive as the only child, replaces the previous one
+func (enc *OneofPacketEncoder) SetPrimitivePacket(pp *PrimitivePacketEncoder) {
+	enc.valbuf = nil
+	enc.addRawPacket(pp)
+}
+
+// OneofPacket describes a node packet holds exactly one child
+type OneofPacket struct {
+	NodePacket
+	which byte
+}
+
+// Which returns the SeqID of the child, it can be found in NodePackets or PrimitivePackets
+func (p *OneofPacket) Which() byte {
+	return p.which
+}
+
+// DecodeToOneofPacket parse out whole buffer to a OneofPacket, returns ErrOneofEmpty
+// or ErrOneofMultiple if the node does not hold exactly one child
+func DecodeToOneofPacket(buf []byte, p *OneofPacket) (consumedBytes int, err error) {
+	consumedBytes, err = DecodeToNodePacket(buf, &p.NodePacket)
+	if err != nil {
+		return 0, err
+	}
+	p.which, err = whichOneof(&p.NodePacket)
+	if err != nil {
+		return 0, err
+	}
+	return consumedBytes, nil
+}
+
+// whichOneof returns the SeqID of the only child of a oneof packet
+func whichOneof(np *NodePacket) (byte, error) {
+	var count int
+	var sid byte
+	err := eachPacket(np.GetValBuf(), func(isNode bool, n *NodePacket, p *PrimitivePacket) error {
+		count++
+		if isNode {
+			sid = n.SeqID()
+		} else {
+			sid = p.SeqID()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	switch count {
+	case 0:
+		return 0, ErrOneofEmpty
+	case 1:
+		return sid, nil
+	}
+	return 0, ErrOneofMultiple
+}
+
+// oneofVariants describes all the registered types of an interface
+type oneofVariants struct {
+	bySeqID map[byte]reflect.Type
+	byType  map[reflect.Type]byte
+}
+
+var oneofRegistry = struct {
+	sync.RWMutex
+	ifaces map[reflect.Type]*oneofVariants
+}{ifaces: map[reflect.Type]*oneofVariants{}}
+
+// RegisterOneof registers the type of v as a variant of interface I with SeqID sid.
+// Marshal encodes a field of type I as a oneof packet, the only child is the
+// variant with its SeqID, and Unmarshal creates the variant by the SeqID, e.g.
+//
+//	RegisterOneof[Command](0x01, &Start{})
+//	RegisterOneof[Command](0x02, &Stop{})
+//
+// It panics if I is not an interface, or the SeqID or type is already registered.
+func RegisterOneof[I any](sid byte, v I) {
+	iface := reflect.TypeOf((*I)(nil)).Elem()
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Errorf("y3: RegisterOneof needs an interface type, got %v", iface))
+	}
+	if sid > 0x3F {
+		panic(fmt.Errorf("sid should be in [0..0x3F]"))
+	}
+	typ := reflect.TypeOf(v)
+	if typ == nil {
+		panic(errors.New("y3: RegisterOneof with nil value"))
+	}
+
+	oneofRegistry.Lock()
+	defer oneofRegistry.Unlock()
+	variants, ok := oneofRegistry.ifaces[iface]
+	if !ok {
+		variants = &oneofVariants{
+			bySeqID: map[byte]reflect.Type{},
+			byType:  map[reflect.Type]byte{},
+		}
+		oneofRegistry.ifaces[iface] = variants
+	}
+	if t, ok := variants.bySeqID[sid]; ok {
+		panic(fmt.Errorf("y3: SeqID %#x of %v is registered by %v", sid, iface, t))
+	}
+	if s, ok := variants.byType[typ]; ok {
+		panic(fmt.Errorf("y3: %v of %v is registered with SeqID %#x", typ, iface, s))
+	}
+	variants.bySeqID[sid] = typ
+	variants.byType[typ] = sid
+}
+
+func lookupOneofSeqID(iface, typ reflect.Type) (byte, bool) {
+	oneofRegistry.RLock()
+	defer oneofRegistry.RUnlock()
+	if variants, ok := oneofRegistry.ifaces[iface]; ok {
+		sid, ok := variants.byType[typ]
+		return sid, ok
+	}
+	return 0, false
+}
+
+func lookupOneofType(iface reflect.Type, sid byte) (reflect.Type, bool) {
+	oneofRegistry.RLock()
+	defer oneofRegistry.RUnlock()
+	if variants, ok := oneofRegistry.ifaces[iface]; ok {
+		typ, ok := variants.bySeqID[sid]
+		return typ, ok
+	}
+	return nil, false
+}
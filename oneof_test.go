@@ -0,0 +1,111 @@
+package y3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCommand interface {
+	isTestCommand()
+}
+
+type testStart struct {
+	Speed int32 `y3:"0x01"`
+}
+
+func (*testStart) isTestCommand() {}
+
+type testStop struct {
+	Reason string `y3:"0x01"`
+}
+
+func (*testStop) isTestCommand() {}
+
+type testPing int32
+
+func (testPing) isTestCommand() {}
+
+func init() {
+	RegisterOneof[testCommand](0x01, &testStart{})
+	RegisterOneof[testCommand](0x02, &testStop{})
+	RegisterOneof[testCommand](0x03, testPing(0))
+}
+
+type testFrame struct {
+	Seq int32       `y3:"0x01"`
+	Cmd testCommand `y3:"0x02"`
+}
+
+func TestOneofPacket(t *testing.T) {
+	p := NewPrimitivePacketEncoder(0x02)
+	p.SetStringValue("C")
+	enc := NewOneofPacketEncoder(0x05)
+	enc.SetPrimitivePacket(NewPrimitivePacketEncoder(0x01))
+	// replace the previous one
+	enc.SetPrimitivePacket(p)
+	buf := enc.Encode()
+	assert.Equal(t, []byte{0x85, 0x03, 0x02, 0x01, 0x43}, buf)
+
+	res := &OneofPacket{}
+	consumedBytes, err := DecodeToOneofPacket(buf, res)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, consumedBytes)
+	assert.EqualValues(t, 0x05, res.SeqID())
+	assert.EqualValues(t, 0x02, res.Which())
+	child := res.PrimitivePackets[res.Which()]
+	v, err := child.ToUTF8String()
+	assert.NoError(t, err)
+	assert.Equal(t, "C", v)
+}
+
+func TestOneofPacketInvalid(t *testing.T) {
+	res := &OneofPacket{}
+	_, err := DecodeToOneofPacket([]byte{0x85, 0x00}, res)
+	assert.ErrorIs(t, err, ErrOneofEmpty)
+
+	_, err = DecodeToOneofPacket([]byte{0x85, 0x06, 0x01, 0x01, 0x01, 0x02, 0x01, 0x02}, res)
+	assert.ErrorIs(t, err, ErrOneofMultiple)
+}
+
+func TestMarshalOneof(t *testing.T) {
+	for _, cmd := range []testCommand{&testStart{Speed: 10}, &testStop{Reason: "done"}, testPing(3)} {
+		buf, err := Marshal(0x10, &testFrame{Seq: 1, Cmd: cmd})
+		assert.NoError(t, err)
+
+		var res testFrame
+		assert.NoError(t, Unmarshal(buf, &res))
+		assert.Equal(t, cmd, res.Cmd)
+	}
+
+	buf, err := Marshal(0x10, &testFrame{Seq: 1, Cmd: &testStop{}})
+	assert.NoError(t, err)
+	// 0x90 { 0x01: 1, 0x82 { 0x82 { 0x01: "" } } }
+	assert.Equal(t, []byte{0x90, 0x09, 0x01, 0x01, 0x01, 0x82, 0x04, 0x82, 0x02, 0x01, 0x00}, buf)
+}
+
+func TestUnmarshalOneofInvalid(t *testing.T) {
+	// two children in the oneof packet
+	buf := []byte{0x90, 0x0A, 0x82, 0x08, 0x81, 0x02, 0x01, 0x00, 0x82, 0x02, 0x01, 0x00}
+	var res testFrame
+	assert.ErrorIs(t, Unmarshal(buf, &res), ErrOneofMultiple)
+
+	// unregistered SeqID
+	buf = []byte{0x90, 0x06, 0x82, 0x04, 0x84, 0x02, 0x01, 0x00}
+	assert.EqualError(t, Unmarshal(buf, &res), "y3: SeqID 0x4 is not registered as a oneof variant of y3.testCommand")
+}
+
+type testUnregistered struct{}
+
+func (*testUnregistered) isTestCommand() {}
+
+func TestMarshalOneofUnregistered(t *testing.T) {
+	_, err := Marshal(0x10, &testFrame{Cmd: &testUnregistered{}})
+	assert.EqualError(t, err, "y3: *y3.testUnregistered is not registered as a oneof variant of y3.testCommand")
+}
+
+func TestRegisterOneofPanics(t *testing.T) {
+	assert.Panics(t, func() { RegisterOneof[testCommand](0x01, &testUnregistered{}) })
+	assert.Panics(t, func() { RegisterOneof[testCommand](0x04, &testStart{}) })
+	assert.Panics(t, func() { RegisterOneof[*testStart](0x01, &testStart{}) })
+}
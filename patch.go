@@ -0,0 +1,328 @@
+package y3
+
+import (
+	"errors"
+	"fmt"
+)
+
+// patchPacket is a packet being patched, the children of node are decoded
+// only if they are patched, the untouched packets keep their encoded bytes
+type patchPacket struct {
+	tag byte
+	// raw is the encoded packet, nil if it's changed
+	raw []byte
+	// children are the packets in the Val of node, valid if expanded
+	children []*patchPacket
+	expanded bool
+	removed  bool
+}
+
+func newPatchPacket(buf []byte) (*patchPacket, error) {
+	if err := validate(buf, nil); err != nil {
+		return nil, err
+	}
+	return &patchPacket{tag: buf[0], raw: buf}, nil
+}
+
+func (p *patchPacket) isNode() bool {
+	return p.tag&0x80 != 0
+}
+
+func (p *patchPacket) isSlice() bool {
+	return p.tag&0x40 != 0
+}
+
+// expand decodes the children of node, the packet is validated already
+func (p *patchPacket) expand() {
+	if p.expanded {
+		return
+	}
+	p.expanded = true
+	rp, _ := readRawPacket(p.raw, 0)
+	_ = eachRawPacket(rp.val, 0, func(child *rawPacket) error {
+		p.children = append(p.children, &patchPacket{tag: child.header[0], raw: child.bytes()})
+		return nil
+	})
+}
+
+// child returns the index of the child at the step, -1 if not exists. The
+// index of slice and the occurrence of SeqID are the positions before the
+// packets are removed.
+func (p *patchPacket) child(step PathStep) int {
+	p.expand()
+	if step.IsIndex {
+		if step.Index >= 0 && step.Index < len(p.children) && !p.children[step.Index].removed {
+			return step.Index
+		}
+		return -1
+	}
+	n := 0
+	for i, c := range p.children {
+		if c.tag&0x3F != step.SeqID {
+			continue
+		}
+		if n == step.Index && !c.removed {
+			return i
+		}
+		n++
+	}
+	return -1
+}
+
+// occurrences returns the count of the children with SeqID sid, including the
+// removed ones
+func (p *patchPacket) occurrences(sid byte) int {
+	n := 0
+	for _, c := range p.children {
+		if c.tag&0x3F == sid {
+			n++
+		}
+	}
+	return n
+}
+
+// encode appends the encoded packet to dst
+func (p *patchPacket) encode(dst []byte) []byte {
+	if p.raw != nil {
+		return append(dst, p.raw...)
+	}
+	var val []byte
+	for _, c := range p.children {
+		if !c.removed {
+			val = c.encode(val)
+		}
+	}
+	return appendPacket(dst, p.tag, val)
+}
+
+// parent returns the parent of the packet at path, and marks the ancestors as
+// changed
+func (p *patchPacket) parent(path Path) (*patchPacket, error) {
+	parent := p
+	for i, step := range path[:len(path)-1] {
+		if err := parent.checkStep(step); err != nil {
+			return nil, err
+		}
+		j := parent.child(step)
+		if j < 0 {
+			return nil, fmt.Errorf("%s is not found", path[:i+1])
+		}
+		parent.raw = nil
+		parent = parent.children[j]
+	}
+	if err := parent.checkStep(path[len(path)-1]); err != nil {
+		return nil, err
+	}
+	parent.expand()
+	parent.raw = nil
+	return parent, nil
+}
+
+// checkStep checks the step can be taken from p
+func (p *patchPacket) checkStep(step PathStep) error {
+	switch {
+	case !p.isNode():
+		return errors.New("primitive has no children")
+	case p.isSlice() && !step.IsIndex:
+		return fmt.Errorf("slice has no child of SeqID 0x%02X", step.SeqID)
+	case !p.isSlice() && step.IsIndex:
+		return fmt.Errorf("node has no element [%d]", step.Index)
+	}
+	return nil
+}
+
+// apply applies the change to the tree of p, the root packet is patched by
+// the caller
+func (p *patchPacket) apply(c Change) error {
+	var child *patchPacket
+	if c.Kind != Removed {
+		var err error
+		if child, err = newPatchPacket(c.New); err != nil {
+			return err
+		}
+		last := c.Path[len(c.Path)-1]
+		if !last.IsIndex && child.tag&0x3F != last.SeqID {
+			return fmt.Errorf("SeqID of the packet 0x%02X mismatches", child.tag&0x3F)
+		}
+	}
+
+	parent, err := p.parent(c.Path)
+	if err != nil {
+		return err
+	}
+	last := c.Path[len(c.Path)-1]
+	i := parent.child(last)
+	if child != nil && parent.isSlice() && !parent.sameElements(child, i) {
+		return errors.New("slice mixes node and primitive elements")
+	}
+	switch c.Kind {
+	case Added:
+		if i >= 0 {
+			return errors.New("packet already exists")
+		}
+		if last.IsIndex && last.Index != len(parent.children) {
+			return fmt.Errorf("slice of %d elements can't be added at [%d]", len(parent.children), last.Index)
+		}
+		if n := parent.occurrences(last.SeqID); !last.IsIndex && last.Index != n {
+			return fmt.Errorf("node of %d children with the SeqID can't be added at #%d", n, last.Index)
+		}
+		parent.children = append(parent.children, child)
+	case Changed, Removed:
+		if i < 0 {
+			return errors.New("packet is not found")
+		}
+		if c.Kind == Removed {
+			parent.children[i].removed = true
+		} else {
+			parent.children[i] = child
+		}
+	default:
+		return fmt.Errorf("invalid change kind %d", int(c.Kind))
+	}
+	return nil
+}
+
+// sameElements returns true if elem is a node as the elements of slice p are,
+// or a primitive as they are, the element at index skip is ignored
+func (p *patchPacket) sameElements(elem *patchPacket, skip int) bool {
+	for i, c := range p.children {
+		if i != skip && !c.removed && c.isNode() != elem.isNode() {
+			return false
+		}
+	}
+	return true
+}
+
+// Patch applies the changes to the packet in buf one after another, and
+// returns the new encoded packet with the lengths of ancestors fixed up, buf
+// is not modified. An Added packet should not exist, and is appended to the
+// children of node, or to the elements of slice if its index is the length
+// of slice. A Changed packet is replaced by New, and a Removed packet is
+// deleted. The elements of slice should be all nodes or all primitives. The
+// step of node addresses the child by its SeqID and occurrence, an Added child
+// should be the next occurrence, and the indexes of slices and the occurrences
+// are the positions before the packets are removed, so Patch(a, Diff(a, b)...)
+// returns b, up to the order of the children of nodes if b has different
+// order or new children, see Canonicalize.
+func Patch(buf []byte, changes ...Change) ([]byte, error) {
+	var root *patchPacket
+	if len(buf) > 0 {
+		var err error
+		if root, err = newPatchPacket(buf); err != nil {
+			return nil, fmt.Errorf("y3: %v", err)
+		}
+	}
+
+	for _, c := range changes {
+		var err error
+		if len(c.Path) == 0 {
+			root, err = patchRoot(root, c)
+		} else if root == nil {
+			err = errors.New("patch empty packet")
+		} else {
+			err = root.apply(c)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("y3: %s %s: %v", c.Kind, c.Path, err)
+		}
+	}
+	if root == nil {
+		return nil, nil
+	}
+	return root.encode(nil), nil
+}
+
+// patchRoot applies the change of root packet
+func patchRoot(root *patchPacket, c Change) (*patchPacket, error) {
+	switch {
+	case c.Kind == Added && root != nil:
+		return nil, errors.New("packet already exists")
+	case c.Kind != Added && root == nil:
+		return nil, errors.New("packet is not found")
+	case c.Kind == Removed:
+		return nil, nil
+	case c.Kind == Added || c.Kind == Changed:
+		return newPatchPacket(c.New)
+	}
+	return nil, fmt.Errorf("invalid change kind %d", int(c.Kind))
+}
+
+// SetAt returns the new encoded packet with the packet at path replaced by
+// packet, or added if not exists, see Patch
+func SetAt(buf []byte, path Path, packet []byte) ([]byte, error) {
+	kind := Changed
+	if root, err := newPatchPacket(buf); len(buf) == 0 || err == nil && !root.exists(path) {
+		kind = Added
+	}
+	return Patch(buf, Change{Kind: kind, Path: path, New: packet})
+}
+
+// ReplaceAt returns the new encoded packet with the packet at path replaced
+// by packet, it fails if the packet doesn't exist, see Patch
+func ReplaceAt(buf []byte, path Path, packet []byte) ([]byte, error) {
+	return Patch(buf, Change{Kind: Changed, Path: path, New: packet})
+}
+
+// DeleteAt returns the new encoded packet without the packet at path, it
+// fails if the packet doesn't exist, see Patch
+func DeleteAt(buf []byte, path Path) ([]byte, error) {
+	return Patch(buf, Change{Kind: Removed, Path: path})
+}
+
+// exists returns true if the packet at path exists
+func (p *patchPacket) exists(path Path) bool {
+	for _, step := range path {
+		if p.checkStep(step) != nil {
+			return false
+		}
+		i := p.child(step)
+		if i < 0 {
+			return false
+		}
+		p = p.children[i]
+	}
+	return true
+}
+
+// Merge merges the partial packet into the packet in buf, and returns the new
+// encoded packet. The root packets should have the same SeqID. The children of
+// partial replace the first children with the same SeqID in buf, or are
+// appended if not exist, except that the nodes of both are merged
+// recursively. Slices and primitives, including nulls, are replaced as a
+// whole.
+func Merge(buf, partial []byte) ([]byte, error) {
+	root, err := newPatchPacket(buf)
+	if err != nil {
+		return nil, fmt.Errorf("y3: %v", err)
+	}
+	src, err := newPatchPacket(partial)
+	if err != nil {
+		return nil, fmt.Errorf("y3: partial: %v", err)
+	}
+	if root.tag != src.tag {
+		return nil, fmt.Errorf("y3: merge packet of tag 0x%02X into tag 0x%02X", src.tag, root.tag)
+	}
+	if !root.isNode() || root.isSlice() {
+		return partial, nil
+	}
+	root.merge(src)
+	return root.encode(nil), nil
+}
+
+// merge merges the children of src into p, both are nodes
+func (p *patchPacket) merge(src *patchPacket) {
+	p.expand()
+	src.expand()
+	p.raw = nil
+	for _, c := range src.children {
+		i := p.child(PathStep{SeqID: c.tag & 0x3F})
+		switch {
+		case i < 0:
+			p.children = append(p.children, c)
+		case c.isNode() && !c.isSlice() && p.children[i].tag == c.tag:
+			p.children[i].merge(c)
+		default:
+			p.children[i] = c
+		}
+	}
+}
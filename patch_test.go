@@ -0,0 +1,158 @@
+package y3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustParseText(t *testing.T, text string) []byte {
+	t.Helper()
+	buf, err := ParseText(text)
+	assert.NoError(t, err)
+	return buf
+}
+
+func TestPatch(t *testing.T) {
+	buf := mustParseText(t, `0x3F { 0x2F { 0x01: "yomo" 0x02: 1 } 0x05 [1 2 3] 0x06: "x" }`)
+	res, err := Patch(buf,
+		Change{Kind: Changed, Path: NewPath(0x2F, 0x01), New: mustParseText(t, `0x01: "y3"`)},
+		Change{Kind: Added, Path: NewPath(0x2F, 0x03), New: mustParseText(t, `0x03 { 0x01: true }`)},
+		Change{Kind: Removed, Path: NewPath(0x05).Elem(0)},
+		Change{Kind: Removed, Path: NewPath(0x05).Elem(1)},
+		Change{Kind: Added, Path: NewPath(0x05).Elem(3), New: mustParseText(t, `0x00: 4`)},
+		Change{Kind: Removed, Path: NewPath(0x06)},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, mustParseText(t, `0x3F { 0x2F { 0x01: "y3" 0x02: 1 0x03 { 0x01: true } } 0x05 [3 4] }`), res)
+	assert.NoError(t, Validate(res, nil))
+	// buf is not modified
+	assert.Equal(t, mustParseText(t, `0x3F { 0x2F { 0x01: "yomo" 0x02: 1 } 0x05 [1 2 3] 0x06: "x" }`), buf)
+
+	tests := []struct {
+		change Change
+		err    string
+	}{
+		{Change{Kind: Added, Path: NewPath(0x06), New: mustParseText(t, `0x06: 1`)}, "y3: added 0x06: packet already exists"},
+		{Change{Kind: Changed, Path: NewPath(0x07), New: mustParseText(t, `0x07: 1`)}, "y3: changed 0x07: packet is not found"},
+		{Change{Kind: Removed, Path: NewPath(0x07, 0x01)}, "y3: removed 0x07.0x01: 0x07 is not found"},
+		{Change{Kind: Removed, Path: NewPath(0x06, 0x01)}, "y3: removed 0x06.0x01: primitive has no children"},
+		{Change{Kind: Removed, Path: NewPath(0x05, 0x01)}, "y3: removed 0x05.0x01: slice has no child of SeqID 0x01"},
+		{Change{Kind: Removed, Path: NewPath(0x2F).Elem(0)}, "y3: removed 0x2F[0]: node has no element [0]"},
+		{Change{Kind: Removed, Path: NewPath(0x05).Elem(AnyIndex)}, "y3: removed 0x05[*]: packet is not found"},
+		{Change{Kind: Added, Path: NewPath(0x05).Elem(3), New: []byte{0x80, 0x00}}, "y3: added 0x05[3]: slice mixes node and primitive elements"},
+		{Change{Kind: Changed, Path: NewPath(0x05).Elem(0), New: []byte{0x80, 0x00}}, "y3: changed 0x05[0]: slice mixes node and primitive elements"},
+		{Change{Kind: Added, Path: NewPath(0x05).Elem(4), New: []byte{0x00, 0x00}}, "y3: added 0x05[4]: slice of 3 elements can't be added at [4]"},
+		{Change{Kind: Changed, Path: NewPath(0x06), New: mustParseText(t, `0x07: 1`)}, "y3: changed 0x06: SeqID of the packet 0x07 mismatches"},
+		{Change{Kind: Changed, Path: NewPath(0x06), New: []byte{0x06, 0x02}}, "y3: changed 0x06: invalid packet at offset 0: length 2 exceeds the 0 bytes left"},
+		{Change{Kind: Added, Path: Path{}, New: buf}, "y3: added .: packet already exists"},
+	}
+	for _, tt := range tests {
+		_, err := Patch(buf, tt.change)
+		if assert.Error(t, err, tt.err) {
+			assert.Equal(t, tt.err, err.Error())
+		}
+	}
+
+	_, err = Patch([]byte{0x81, 0x02})
+	assert.Error(t, err)
+}
+
+func TestPatchDiff(t *testing.T) {
+	a := mustParseText(t, `0x3F { 0x2F { 0x01: "yomo" 0x02: 1 } 0x05 [1 2 3] 0x06: "x" 0x07 [{ 0x01: 1 }] }`)
+	for _, text := range []string{
+		`0x3F { 0x2F { 0x01: "y3" 0x02: 1 0x03: null } 0x05 [1] 0x07 [{ 0x01: 2 } { 0x02: 1 }] 0x08: 1 }`,
+		`0x3F { 0x2F { } 0x05 [1 2 3 4 5] 0x06 { 0x01: "x" } 0x07: null }`,
+		`0x3E: 1`,
+	} {
+		b := mustParseText(t, text)
+		res, err := Patch(a, Diff(a, b)...)
+		assert.NoError(t, err, text)
+		assert.Equal(t, b, res, text)
+	}
+
+	b := mustParseText(t, `0x3F { 0x01: 1 }`)
+	res, err := Patch(nil, Diff(nil, b)...)
+	assert.NoError(t, err)
+	assert.Equal(t, b, res)
+	res, err = Patch(b, Diff(b, nil)...)
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+}
+
+func TestPatchDiffRepeatedSeqIDs(t *testing.T) {
+	for _, c := range []struct {
+		a, b, diff string
+	}{
+		{`0x3F { 0x01: 1 0x01: 2 }`, `0x3F { 0x01: 1 0x01: 3 }`, "~ 0x01#1: h\"02\" -> h\"03\"\n"},
+		{`0x3F { 0x01: 1 0x01: 2 0x01: 3 }`, `0x3F { 0x01: 1 0x01: 2 }`, "- 0x01#2: h\"03\"\n"},
+		{`0x3F { 0x01: 1 }`, `0x3F { 0x01: 1 0x01: 2 }`, "+ 0x01#1: h\"02\"\n"},
+		{`0x3F { 0x01: 1 0x02: 1 0x01: 2 }`, `0x3F { 0x01: 3 0x02: 1 }`, "~ 0x01: h\"01\" -> h\"03\"\n- 0x01#1: h\"02\"\n"},
+	} {
+		a, b := mustParseText(t, c.a), mustParseText(t, c.b)
+		changes := Diff(a, b)
+		assert.Equal(t, c.diff, FormatDiff(changes), c.b)
+		res, err := Patch(a, changes...)
+		assert.NoError(t, err, c.b)
+		assert.Equal(t, b, res, c.b)
+	}
+
+	a := mustParseText(t, `0x3F { 0x01: 1 }`)
+	_, err := Patch(a, Change{Kind: Added, Path: NewPath(0x01), New: mustParseText(t, `0x01: 2`)})
+	assert.EqualError(t, err, "y3: added 0x01: packet already exists")
+	_, err = Patch(a, Change{Kind: Added, Path: Path{}.ChildAt(0x01, 2), New: mustParseText(t, `0x01: 2`)})
+	assert.EqualError(t, err, "y3: added 0x01#2: node of 1 children with the SeqID can't be added at #2")
+}
+
+func TestSetAt(t *testing.T) {
+	buf := mustParseText(t, `0x3F { 0x2F { 0x01: "yomo" } }`)
+	res, err := SetAt(buf, NewPath(0x2F, 0x01), mustParseText(t, `0x01: "y3"`))
+	assert.NoError(t, err)
+	assert.Equal(t, mustParseText(t, `0x3F { 0x2F { 0x01: "y3" } }`), res)
+
+	res, err = SetAt(res, NewPath(0x2F, 0x02), mustParseText(t, `0x02: 1`))
+	assert.NoError(t, err)
+	assert.Equal(t, mustParseText(t, `0x3F { 0x2F { 0x01: "y3" 0x02: 1 } }`), res)
+
+	res, err = DeleteAt(res, NewPath(0x2F, 0x01))
+	assert.NoError(t, err)
+	assert.Equal(t, mustParseText(t, `0x3F { 0x2F { 0x02: 1 } }`), res)
+
+	_, err = ReplaceAt(res, NewPath(0x2F, 0x01), mustParseText(t, `0x01: 1`))
+	assert.Error(t, err)
+	_, err = SetAt(res, NewPath(0x2E, 0x01), mustParseText(t, `0x01: 1`))
+	assert.Error(t, err)
+
+	res, err = SetAt(nil, Path{}, mustParseText(t, `0x01: 1`))
+	assert.NoError(t, err)
+	assert.Equal(t, mustParseText(t, `0x01: 1`), res)
+
+	// the elements of slice are all nodes or all primitives
+	buf = mustParseText(t, `0x3F { 0x05 [1 2] }`)
+	_, err = SetAt(buf, NewPath(0x05).Elem(2), []byte{0x80, 0x00})
+	assert.EqualError(t, err, "y3: added 0x05[2]: slice mixes node and primitive elements")
+	res, err = Patch(buf,
+		Change{Kind: Removed, Path: NewPath(0x05).Elem(0)},
+		Change{Kind: Changed, Path: NewPath(0x05).Elem(1), New: []byte{0x80, 0x00}},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, mustParseText(t, `0x3F { 0x05 [{}] }`), res)
+	assert.NoError(t, Validate(res, nil))
+}
+
+func TestMerge(t *testing.T) {
+	buf := mustParseText(t, `0x3F { 0x2F { 0x01: "yomo" 0x02: 1 } 0x05 [1 2] 0x06: "x" }`)
+	partial := mustParseText(t, `0x3F { 0x2F { 0x02: 2 0x03: true } 0x05 [3] 0x06: null 0x07 { } }`)
+	res, err := Merge(buf, partial)
+	assert.NoError(t, err)
+	assert.Equal(t, mustParseText(t, `0x3F { 0x2F { 0x01: "yomo" 0x02: 2 0x03: true } 0x05 [3] 0x06: null 0x07 { } }`), res)
+
+	_, err = Merge(buf, mustParseText(t, `0x3E { }`))
+	if assert.Error(t, err) {
+		assert.Equal(t, "y3: merge packet of tag 0xBE into tag 0xBF", err.Error())
+	}
+	_, err = Merge(buf, []byte{0xBF})
+	if assert.Error(t, err) {
+		assert.Equal(t, "y3: partial: invalid packet at offset 0: missing length", err.Error())
+	}
+}
@@ -0,0 +1,175 @@
+package y3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path locates a packet from the root packet, every step goes to a child of
+// node by its SeqID, or to an element of slice by its index. The text form is
+// the steps joined by dots, with the indexes in brackets, e.g. "0x2F.0x01"
+// and "0x05[2].0x01", the root packet is ".". The index "[*]" is AnyIndex.
+// If a node has several children with the same SeqID, the occurrence of the
+// child follows "#", e.g. "0x01#1" is the second child with SeqID 0x01.
+type Path []PathStep
+
+// AnyIndex is the index of PathStep matching every element of slice, or every
+// occurrence of SeqID, it's used to select packets, e.g. by Redactor, and
+// addresses nothing in Patch
+const AnyIndex = -1
+
+// PathStep is a step of Path
+type PathStep struct {
+	// SeqID is the SeqID of the child of node
+	SeqID byte
+	// Index is the index of the element of slice if IsIndex, otherwise it's
+	// the occurrence of the child among the children with SeqID, 0 is the
+	// first one
+	Index   int
+	IsIndex bool
+}
+
+// NewPath returns the path of the SeqIDs of children from the root packet
+func NewPath(sids ...byte) Path {
+	p := make(Path, len(sids))
+	for i, sid := range sids {
+		p[i] = PathStep{SeqID: sid}
+	}
+	return p
+}
+
+// Child returns a new path to the child of node with SeqID sid
+func (p Path) Child(sid byte) Path {
+	return p.append(PathStep{SeqID: sid})
+}
+
+// ChildAt returns a new path to the n-th child of node with SeqID sid, n
+// starts from 0
+func (p Path) ChildAt(sid byte, n int) Path {
+	return p.append(PathStep{SeqID: sid, Index: n})
+}
+
+// Elem returns a new path to the i-th element of slice
+func (p Path) Elem(i int) Path {
+	return p.append(PathStep{Index: i, IsIndex: true})
+}
+
+func (p Path) append(step PathStep) Path {
+	res := make(Path, len(p), len(p)+1)
+	copy(res, p)
+	return append(res, step)
+}
+
+// String returns the text form of path
+func (p Path) String() string {
+	if len(p) == 0 {
+		return "."
+	}
+	var sb strings.Builder
+	for i, step := range p {
+		if step.IsIndex && step.Index == AnyIndex {
+			sb.WriteString("[*]")
+			continue
+		}
+		if step.IsIndex {
+			fmt.Fprintf(&sb, "[%d]", step.Index)
+			continue
+		}
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		fmt.Fprintf(&sb, "0x%02X", step.SeqID)
+		if step.Index == AnyIndex {
+			sb.WriteString("#*")
+		} else if step.Index != 0 {
+			fmt.Fprintf(&sb, "#%d", step.Index)
+		}
+	}
+	return sb.String()
+}
+
+// ParsePath parses the text form of path, see Path
+func ParsePath(s string) (Path, error) {
+	if s == "." {
+		return Path{}, nil
+	}
+	p := Path{}
+	for i, part := range strings.Split(s, ".") {
+		sid := part
+		if j := strings.IndexByte(part, '['); j >= 0 {
+			sid, part = part[:j], part[j:]
+		} else {
+			part = ""
+		}
+		if sid != "" {
+			step := PathStep{}
+			if j := strings.IndexByte(sid, '#'); j >= 0 {
+				n, err := strconv.Atoi(sid[j+1:])
+				if sid[j+1:] == "*" {
+					n, err = AnyIndex, nil
+				} else if n < 0 {
+					err = strconv.ErrRange
+				}
+				if err != nil {
+					return nil, fmt.Errorf("y3: invalid occurrence %q in path %q", sid[j:], s)
+				}
+				sid, step.Index = sid[:j], n
+			}
+			v, err := strconv.ParseUint(sid, 0, 8)
+			if err != nil || v > 0x3F {
+				return nil, fmt.Errorf("y3: invalid SeqID %q in path %q", sid, s)
+			}
+			step.SeqID = byte(v)
+			p = append(p, step)
+		} else if i > 0 || part == "" {
+			return nil, fmt.Errorf("y3: missing SeqID in path %q", s)
+		}
+		for part != "" {
+			end := strings.IndexByte(part, ']')
+			if part[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("y3: invalid index %q in path %q", part, s)
+			}
+			v, err := strconv.Atoi(part[1:end])
+			if part[1:end] == "*" {
+				v, err = AnyIndex, nil
+			} else if v < 0 {
+				err = strconv.ErrRange
+			}
+			if err != nil {
+				return nil, fmt.Errorf("y3: invalid index %q in path %q", part[:end+1], s)
+			}
+			p = append(p, PathStep{Index: v, IsIndex: true})
+			part = part[end+1:]
+		}
+	}
+	return p, nil
+}
+
+// MustParsePath is like ParsePath but panics if s is invalid
+func MustParsePath(s string) Path {
+	p, err := ParsePath(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Match returns true if the packet at path q is selected by p, they have the
+// same steps except that AnyIndex of p matches every index or occurrence
+func (p Path) Match(q Path) bool {
+	if len(p) != len(q) {
+		return false
+	}
+	for i, step := range p {
+		switch {
+		case step.IsIndex != q[i].IsIndex:
+			return false
+		case step.Index != AnyIndex && step.Index != q[i].Index:
+			return false
+		case !step.IsIndex && step.SeqID != q[i].SeqID:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package y3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPath(t *testing.T) {
+	p := NewPath(0x2F, 0x05).Elem(2).Child(0x01)
+	assert.Equal(t, "0x2F.0x05[2].0x01", p.String())
+	assert.Equal(t, ".", Path{}.String())
+
+	for _, s := range []string{".", "0x2F.0x01", "0x05[2].0x01", "[0][1]", "0x01[10]", "0x05[*].0x01", "0x01#2.0x03", "0x05#*[1]"} {
+		p, err := ParsePath(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, s, p.String())
+	}
+	p, err := ParsePath("47.1")
+	assert.NoError(t, err)
+	assert.Equal(t, NewPath(0x2F, 0x01), p)
+
+	for s, msg := range map[string]string{
+		"":          `y3: missing SeqID in path ""`,
+		"0x01.":     `y3: missing SeqID in path "0x01."`,
+		"0x40":      `y3: invalid SeqID "0x40" in path "0x40"`,
+		"0x01[a]":   `y3: invalid index "[a]" in path "0x01[a]"`,
+		"0x01[1":    `y3: invalid index "[1" in path "0x01[1"`,
+		"0x01.[1]":  `y3: missing SeqID in path "0x01.[1]"`,
+		"0x01[1]x]": `y3: invalid index "x]" in path "0x01[1]x]"`,
+		"0x01[-1]":  `y3: invalid index "[-1]" in path "0x01[-1]"`,
+		"0x01#a":    `y3: invalid occurrence "#a" in path "0x01#a"`,
+		"0x01#-1":   `y3: invalid occurrence "#-1" in path "0x01#-1"`,
+	} {
+		_, err := ParsePath(s)
+		if assert.Error(t, err, s) {
+			assert.Equal(t, msg, err.Error())
+		}
+	}
+	assert.Panics(t, func() { MustParsePath("x") })
+}
+
+func TestPathMatch(t *testing.T) {
+	p := MustParsePath("0x05[*].0x01")
+	assert.True(t, p.Match(NewPath(0x05).Elem(3).Child(0x01)))
+	assert.True(t, p.Match(p))
+	assert.False(t, p.Match(NewPath(0x05).Elem(3)))
+	assert.False(t, p.Match(NewPath(0x05).Elem(3).Child(0x02)))
+	assert.False(t, p.Match(NewPath(0x05, 0x03, 0x01)))
+	assert.False(t, NewPath(0x05).Elem(1).Match(NewPath(0x05).Elem(3)))
+	assert.False(t, NewPath(0x05).Elem(1).Match(MustParsePath("0x05[*]")))
+	assert.True(t, MustParsePath("0x01#*").Match(Path{}.ChildAt(0x01, 2)))
+	assert.False(t, NewPath(0x01).Match(Path{}.ChildAt(0x01, 2)))
+}
@@ -20,7 +20,7 @@ type DecodeState struct {
 //
 // Examples:
 // [0x01, 0x01, 0x01] -> Key=0x01, Value=0x01
-// [0x41, 0x06, 0x03, 0x01, 0x61, 0x04, 0x01, 0x62] -> key=0x03, value=0x61; key=0x04, value=0x62
+// [0x41, 0x00] -> Key=0x01, null, see PrimitivePacketEncoder.SetNull
 func DecodeToPrimitivePacket(buf []byte, p *PrimitivePacket) (*DecodeState, error) {
 	decoder := &DecodeState{
 		ConsumedBytes: 0,
@@ -35,6 +35,7 @@ func DecodeToPrimitivePacket(buf []byte, p *PrimitivePacket) (*DecodeState, erro
 		valbuf: []byte{},
 		buf:    &bytes.Buffer{},
 	}
+	p.typed = false
 
 	var pos = 0
 	// first byte is `Tag`
@@ -2,6 +2,9 @@ package y3
 
 import (
 	"bytes"
+	"fmt"
+	"reflect"
+	"time"
 
 	"github.com/yomorun/y3/encoding"
 )
@@ -9,6 +12,11 @@ import (
 // PrimitivePacketEncoder used for encode a primitive packet
 type PrimitivePacketEncoder struct {
 	*encoder
+	// typed describes if the Kind of value is written before the value
+	typed bool
+	// untyped is true if Val is written by AddBytes in typed mode, so it has
+	// no Kind
+	untyped bool
 }
 
 // NewPrimitivePacketEncoder return an Encoder for primitive packet
@@ -24,89 +32,210 @@ func NewPrimitivePacketEncoder(sid byte) *PrimitivePacketEncoder {
 	return prim
 }
 
+// NewTypedPrimitivePacketEncoder return an Encoder for primitive packet in typed mode,
+// every Set*Value writes the Kind of value as the first byte of Val, so the packet
+// can be decoded by PrimitivePacket.Value() without knowing the type in advance.
+// The mode itself isn't written, the decoder should know the packet is typed,
+// see PrimitivePacket.AsTyped.
+func NewTypedPrimitivePacketEncoder(sid byte) *PrimitivePacketEncoder {
+	prim := NewPrimitivePacketEncoder(sid)
+	prim.typed = true
+	return prim
+}
+
+// IsTyped returns true if this encoder works in typed mode
+func (enc *PrimitivePacketEncoder) IsTyped() bool {
+	return enc.typed
+}
+
+// AddBytes appends buf to Val as is, the type code is not written in typed
+// mode, so SetValue rejects the values encoded by it
+func (enc *PrimitivePacketEncoder) AddBytes(buf []byte) {
+	enc.untyped = enc.typed
+	enc.encoder.AddBytes(buf)
+}
+
+// setValue set the encoded value as Val, with the type code prefixed in typed mode
+func (enc *PrimitivePacketEncoder) setValue(kind Kind, buf []byte) {
+	enc.reset()
+	enc.isArray = false
+	enc.untyped = false
+	if enc.typed {
+		enc.valbuf = append([]byte{byte(kind)}, buf...)
+		return
+	}
+	enc.valbuf = buf
+}
+
 // SetInt32Value encode int32 value
 func (enc *PrimitivePacketEncoder) SetInt32Value(v int32) {
 	size := encoding.SizeOfNVarInt32(v)
 	codec := encoding.VarCodec{Size: size}
-	enc.valbuf = make([]byte, size)
-	err := codec.EncodeNVarInt32(enc.valbuf, v)
+	buf := make([]byte, size)
+	err := codec.EncodeNVarInt32(buf, v)
 	if err != nil {
 		panic(err)
 	}
+	enc.setValue(KindInt32, buf)
 }
 
 // SetUInt32Value encode uint32 value
 func (enc *PrimitivePacketEncoder) SetUInt32Value(v uint32) {
 	size := encoding.SizeOfNVarUInt32(v)
 	codec := encoding.VarCodec{Size: size}
-	enc.valbuf = make([]byte, size)
-	err := codec.EncodeNVarUInt32(enc.valbuf, v)
+	buf := make([]byte, size)
+	err := codec.EncodeNVarUInt32(buf, v)
 	if err != nil {
 		panic(err)
 	}
+	enc.setValue(KindUInt32, buf)
 }
 
 // SetInt64Value encode int64 value
 func (enc *PrimitivePacketEncoder) SetInt64Value(v int64) {
 	size := encoding.SizeOfNVarInt64(v)
 	codec := encoding.VarCodec{Size: size}
-	enc.valbuf = make([]byte, size)
-	err := codec.EncodeNVarInt64(enc.valbuf, v)
+	buf := make([]byte, size)
+	err := codec.EncodeNVarInt64(buf, v)
 	if err != nil {
 		panic(err)
 	}
+	enc.setValue(KindInt64, buf)
 }
 
 // SetUInt64Value encode uint64 value
 func (enc *PrimitivePacketEncoder) SetUInt64Value(v uint64) {
 	size := encoding.SizeOfNVarUInt64(v)
 	codec := encoding.VarCodec{Size: size}
-	enc.valbuf = make([]byte, size)
-	err := codec.EncodeNVarUInt64(enc.valbuf, v)
+	buf := make([]byte, size)
+	err := codec.EncodeNVarUInt64(buf, v)
 	if err != nil {
 		panic(err)
 	}
+	enc.setValue(KindUInt64, buf)
 }
 
 // SetFloat32Value encode float32 value
 func (enc *PrimitivePacketEncoder) SetFloat32Value(v float32) {
 	var size = encoding.SizeOfVarFloat32(v)
 	codec := encoding.VarCodec{Size: size}
-	enc.valbuf = make([]byte, size)
-	err := codec.EncodeVarFloat32(enc.valbuf, v)
+	buf := make([]byte, size)
+	err := codec.EncodeVarFloat32(buf, v)
 	if err != nil {
 		panic(err)
 	}
+	enc.setValue(KindFloat32, buf)
 }
 
 // SetFloat64Value encode float64 value
 func (enc *PrimitivePacketEncoder) SetFloat64Value(v float64) {
 	var size = encoding.SizeOfVarFloat64(v)
 	codec := encoding.VarCodec{Size: size}
-	enc.valbuf = make([]byte, size)
-	err := codec.EncodeVarFloat64(enc.valbuf, v)
+	buf := make([]byte, size)
+	err := codec.EncodeVarFloat64(buf, v)
 	if err != nil {
 		panic(err)
 	}
+	enc.setValue(KindFloat64, buf)
 }
 
 // SetBoolValue encode bool value
 func (enc *PrimitivePacketEncoder) SetBoolValue(v bool) {
 	var size = encoding.SizeOfPVarUInt32(uint32(1))
 	codec := encoding.VarCodec{Size: size}
-	enc.valbuf = make([]byte, size)
-	err := codec.EncodePVarBool(enc.valbuf, v)
+	buf := make([]byte, size)
+	err := codec.EncodePVarBool(buf, v)
 	if err != nil {
 		panic(err)
 	}
+	enc.setValue(KindBool, buf)
 }
 
 // SetStringValue encode string
 func (enc *PrimitivePacketEncoder) SetStringValue(v string) {
-	enc.valbuf = []byte(v)
+	enc.setValue(KindString, []byte(v))
 }
 
 // SetBytesValue encode []byte
 func (enc *PrimitivePacketEncoder) SetBytesValue(v []byte) {
-	enc.valbuf = v
+	enc.setValue(KindBytes, v)
+}
+
+// SetTimeValue encode time.Time as nanoseconds since Unix epoch
+func (enc *PrimitivePacketEncoder) SetTimeValue(v time.Time) {
+	nsec := v.UnixNano()
+	size := encoding.SizeOfNVarInt64(nsec)
+	codec := encoding.VarCodec{Size: size}
+	buf := make([]byte, size)
+	err := codec.EncodeNVarInt64(buf, nsec)
+	if err != nil {
+		panic(err)
+	}
+	enc.setValue(KindTime, buf)
+}
+
+// SetNull encode an explicit null, which means the field is present but has no value.
+// A null is a primitive packet with the slice flag and zero length, e.g. [0x41, 0x00]
+func (enc *PrimitivePacketEncoder) SetNull() {
+	enc.reset()
+	enc.isArray = true
+	enc.untyped = false
+	enc.valbuf = nil
+}
+
+// SetOptionalValue encode *v by set, or encode null if v is nil, e.g.
+//
+//	SetOptionalValue(enc, name, (*PrimitivePacketEncoder).SetStringValue)
+func SetOptionalValue[T any](enc *PrimitivePacketEncoder, v *T, set func(*PrimitivePacketEncoder, T)) {
+	if v == nil {
+		enc.SetNull()
+		return
+	}
+	set(enc, *v)
+}
+
+// SetValue encode v by its Go type, it's the counterpart of PrimitivePacket.Value(),
+// int and uint are encoded as int64 and uint64, nil is encoded as null, other
+// types are encoded by their codecs, see RegisterCodec
+func (enc *PrimitivePacketEncoder) SetValue(v any) error {
+	switch val := v.(type) {
+	case nil:
+		enc.SetNull()
+	case int32:
+		enc.SetInt32Value(val)
+	case int64:
+		enc.SetInt64Value(val)
+	case int:
+		enc.SetInt64Value(int64(val))
+	case uint32:
+		enc.SetUInt32Value(val)
+	case uint64:
+		enc.SetUInt64Value(val)
+	case uint:
+		enc.SetUInt64Value(uint64(val))
+	case float32:
+		enc.SetFloat32Value(val)
+	case float64:
+		enc.SetFloat64Value(val)
+	case bool:
+		enc.SetBoolValue(val)
+	case string:
+		enc.SetStringValue(val)
+	case []byte:
+		enc.SetBytesValue(val)
+	case time.Time:
+		enc.SetTimeValue(val)
+	default:
+		if set := customEncoder(reflect.ValueOf(v)); set != nil {
+			if err := set(enc); err != nil {
+				return err
+			}
+			if enc.untyped {
+				return fmt.Errorf("y3: %T is encoded by AddBytes without type code", v)
+			}
+			return nil
+		}
+		return fmt.Errorf("y3: unsupported value type %T", v)
+	}
+	return nil
 }
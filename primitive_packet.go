@@ -1,6 +1,10 @@
 package y3
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/yomorun/y3/encoding"
 )
 
@@ -10,6 +14,7 @@ const primitivePacketBufferMinimalLength = 2
 // PrimitivePacket describes primitive value type,
 type PrimitivePacket struct {
 	*basePacket
+	typed bool
 }
 
 // ToInt32 parse raw as int32 value
@@ -98,3 +103,115 @@ func (p *PrimitivePacket) ToUTF8String() (string, error) {
 func (p *PrimitivePacket) ToBytes() []byte {
 	return p.valbuf
 }
+
+// ToTime parse raw as time.Time value
+func (p *PrimitivePacket) ToTime() (time.Time, error) {
+	nsec, err := p.ToInt64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nsec), nil
+}
+
+// AsTyped returns a copy of the packet marked as encoded in typed mode. The
+// typed mode isn't marked in the encoded bytes, so the decoder should know it,
+// and a decoded packet is untyped until it's marked.
+func (p *PrimitivePacket) AsTyped() *PrimitivePacket {
+	return &PrimitivePacket{basePacket: p.basePacket, typed: true}
+}
+
+// IsTyped returns true if the packet is marked as typed, see AsTyped
+func (p *PrimitivePacket) IsTyped() bool {
+	return p.typed
+}
+
+// Kind returns the type code of a typed primitive packet, KindUnknown if the
+// packet is untyped or the first byte of Val is not a type code
+func (p *PrimitivePacket) Kind() Kind {
+	if !p.typed || len(p.valbuf) == 0 {
+		return KindUnknown
+	}
+	if k := Kind(p.valbuf[0]); k.IsValid() {
+		return k
+	}
+	return KindUnknown
+}
+
+// ToEncoder returns an encoder of the primitive with the same Val, so the value
+// can be changed by Set* methods before re-encoding. The encoder is untyped,
+// the Kind of a typed primitive is kept in the Val as is, use ToTypedEncoder
+// if the primitive is typed.
+func (p *PrimitivePacket) ToEncoder() *PrimitivePacketEncoder {
+	return p.toEncoder(NewPrimitivePacketEncoder(p.SeqID()))
+}
+
+// ToTypedEncoder is like ToEncoder but returns an encoder in typed mode, so the
+// values set are prefixed with their Kind
+func (p *PrimitivePacket) ToTypedEncoder() *PrimitivePacketEncoder {
+	return p.toEncoder(NewTypedPrimitivePacketEncoder(p.SeqID()))
+}
+
+func (p *PrimitivePacket) toEncoder(enc *PrimitivePacketEncoder) *PrimitivePacketEncoder {
+	if p.IsNull() {
+		enc.SetNull()
+	} else {
+		enc.valbuf = append([]byte{}, p.GetValBuf()...)
+	}
+	return enc
+}
+
+// IsNull returns true if the packet is an explicit null, see PrimitivePacketEncoder.SetNull
+func (p *PrimitivePacket) IsNull() bool {
+	return p.basePacket != nil && p.tag != nil && p.IsSlice() && p.length == 0
+}
+
+// ToOptionalValue parse the packet by get, returns nil if the packet is null, e.g.
+//
+//	name, err := ToOptionalValue(p, (*PrimitivePacket).ToUTF8String)
+func ToOptionalValue[T any](p *PrimitivePacket, get func(*PrimitivePacket) (T, error)) (*T, error) {
+	if p.IsNull() {
+		return nil, nil
+	}
+	v, err := get(p)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Value parse a typed primitive packet, which is encoded by the encoder created
+// from NewTypedPrimitivePacketEncoder, returns the value as its Go type, or nil if
+// the packet is null. An untyped packet returns error, see AsTyped.
+func (p *PrimitivePacket) Value() (any, error) {
+	if p.IsNull() {
+		return nil, nil
+	}
+	if !p.typed || len(p.valbuf) == 0 {
+		return nil, errors.New("y3: not a typed primitive packet")
+	}
+	kind := Kind(p.valbuf[0])
+	val := &PrimitivePacket{basePacket: &basePacket{valbuf: p.valbuf[1:]}}
+	switch kind {
+	case KindInt32:
+		return val.ToInt32()
+	case KindInt64:
+		return val.ToInt64()
+	case KindUInt32:
+		return val.ToUInt32()
+	case KindUInt64:
+		return val.ToUInt64()
+	case KindFloat32:
+		return val.ToFloat32()
+	case KindFloat64:
+		return val.ToFloat64()
+	case KindBool:
+		return val.ToBool()
+	case KindString:
+		return val.ToUTF8String()
+	case KindBytes:
+		return val.ToBytes(), nil
+	case KindTime:
+		return val.ToTime()
+	}
+	return nil, fmt.Errorf("y3: unknown type code %#x", byte(kind))
+}
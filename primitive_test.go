@@ -2,6 +2,7 @@ package y3
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -310,3 +311,172 @@ func TestPrimitivePacketBytes(t *testing.T) {
 	assert.EqualValues(t, 255+2+1, state.ConsumedBytes)
 	assert.EqualValues(t, 2, state.SizeL)
 }
+
+func TestTypedPrimitivePacket(t *testing.T) {
+	now := time.Unix(0, 1617262800123456789)
+	tests := []struct {
+		val    any
+		expect []byte
+		kind   Kind
+	}{
+		{int32(255), []byte{0x0A, 0x03, 0x01, 0x00, 0xFF}, KindInt32},
+		{int64(-1), []byte{0x0A, 0x02, 0x02, 0xFF}, KindInt64},
+		{uint32(1), []byte{0x0A, 0x02, 0x03, 0x01}, KindUInt32},
+		{uint64(255), []byte{0x0A, 0x03, 0x04, 0x00, 0xFF}, KindUInt64},
+		{float32(1), []byte{0x0A, 0x03, 0x05, 0x3F, 0x80}, KindFloat32},
+		{float64(1), []byte{0x0A, 0x03, 0x06, 0x3F, 0xF0}, KindFloat64},
+		{true, []byte{0x0A, 0x02, 0x07, 0x01}, KindBool},
+		{"yomo", []byte{0x0A, 0x05, 0x08, 0x79, 0x6F, 0x6D, 0x6F}, KindString},
+		{"", []byte{0x0A, 0x01, 0x08}, KindString},
+		{[]byte{0x01, 0x02}, []byte{0x0A, 0x03, 0x09, 0x01, 0x02}, KindBytes},
+		{now, nil, KindTime},
+	}
+
+	for _, tt := range tests {
+		p := NewTypedPrimitivePacketEncoder(0x0A)
+		assert.True(t, p.IsTyped())
+		assert.NoError(t, p.SetValue(tt.val))
+		buf := p.Encode()
+		if tt.expect != nil {
+			assert.Equal(t, tt.expect, buf, "%v", tt.kind)
+		}
+
+		packet := &PrimitivePacket{}
+		_, err := DecodeToPrimitivePacket(buf, packet)
+		assert.NoError(t, err)
+		assert.False(t, packet.IsTyped())
+		packet = packet.AsTyped()
+		assert.Equal(t, tt.kind, packet.Kind())
+		v, err := packet.Value()
+		assert.NoError(t, err)
+		if tt.kind == KindTime {
+			assert.True(t, now.Equal(v.(time.Time)))
+			continue
+		}
+		assert.Equal(t, tt.val, v)
+	}
+}
+
+func TestTypedPrimitivePacketUnknownKind(t *testing.T) {
+	packet := &PrimitivePacket{}
+	_, err := DecodeToPrimitivePacket([]byte{0x0A, 0x02, 0x7F, 0x01}, packet)
+	assert.NoError(t, err)
+	assert.Equal(t, KindUnknown, packet.AsTyped().Kind())
+	_, err = packet.AsTyped().Value()
+	assert.EqualError(t, err, "y3: unknown type code 0x7f")
+
+	_, err = DecodeToPrimitivePacket([]byte{0x0A, 0x00}, packet)
+	assert.NoError(t, err)
+	_, err = packet.AsTyped().Value()
+	assert.EqualError(t, err, "y3: not a typed primitive packet")
+
+	// an untyped int32 1 looks like KindInt32
+	_, err = DecodeToPrimitivePacket([]byte{0x0A, 0x01, 0x01}, packet)
+	assert.NoError(t, err)
+	assert.Equal(t, KindUnknown, packet.Kind())
+	_, err = packet.Value()
+	assert.EqualError(t, err, "y3: not a typed primitive packet")
+}
+
+func TestPrimitivePacketEncoderSetValueUnsupported(t *testing.T) {
+	p := NewTypedPrimitivePacketEncoder(0x0A)
+	assert.EqualError(t, p.SetValue(struct{}{}), "y3: unsupported value type struct {}")
+}
+
+func TestTime(t *testing.T) {
+	v := time.Unix(1617262800, 0)
+	p := NewPrimitivePacketEncoder(0x0A)
+	p.SetTimeValue(v)
+	buf := p.Encode()
+
+	packet := &PrimitivePacket{}
+	_, err := DecodeToPrimitivePacket(buf, packet)
+	assert.NoError(t, err)
+	f, err := packet.ToTime()
+	assert.NoError(t, err)
+	assert.True(t, v.Equal(f))
+}
+
+// test for { 0x01: null }
+func TestNullPrimitivePacket(t *testing.T) {
+	p := NewPrimitivePacketEncoder(0x01)
+	p.SetNull()
+	buf := p.Encode()
+	assert.Equal(t, []byte{0x41, 0x00}, buf)
+
+	packet := &PrimitivePacket{}
+	_, err := DecodeToPrimitivePacket(buf, packet)
+	assert.NoError(t, err)
+	assert.True(t, packet.IsNull())
+	v, err := packet.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	// empty string is present, not null
+	_, err = DecodeToPrimitivePacket([]byte{0x01, 0x00}, packet)
+	assert.NoError(t, err)
+	assert.False(t, packet.IsNull())
+}
+
+func TestSetValueAfterNull(t *testing.T) {
+	p := NewTypedPrimitivePacketEncoder(0x01)
+	assert.NoError(t, p.SetValue(nil))
+	p.SetBoolValue(true)
+	assert.Equal(t, []byte{0x01, 0x02, 0x07, 0x01}, p.Encode())
+}
+
+func TestOptionalValue(t *testing.T) {
+	var name *string
+	p := NewPrimitivePacketEncoder(0x01)
+	SetOptionalValue(p, name, (*PrimitivePacketEncoder).SetStringValue)
+	assert.Equal(t, []byte{0x41, 0x00}, p.Encode())
+
+	packet := &PrimitivePacket{}
+	_, err := DecodeToPrimitivePacket(p.Encode(), packet)
+	assert.NoError(t, err)
+	v, err := ToOptionalValue(packet, (*PrimitivePacket).ToUTF8String)
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	s := "yomo"
+	p = NewPrimitivePacketEncoder(0x01)
+	SetOptionalValue(p, &s, (*PrimitivePacketEncoder).SetStringValue)
+	assert.Equal(t, []byte{0x01, 0x04, 0x79, 0x6F, 0x6D, 0x6F}, p.Encode())
+
+	_, err = DecodeToPrimitivePacket(p.Encode(), packet)
+	assert.NoError(t, err)
+	v, err = ToOptionalValue(packet, (*PrimitivePacket).ToUTF8String)
+	assert.NoError(t, err)
+	assert.Equal(t, s, *v)
+}
+
+func TestPrimitiveToEncoder(t *testing.T) {
+	for _, buf := range [][]byte{{0x01, 0x01, 0x7F}, {0x42, 0x00}, {0x03, 0x00}} {
+		var p PrimitivePacket
+		_, err := DecodeToPrimitivePacket(buf, &p)
+		assert.NoError(t, err)
+		enc := p.ToEncoder()
+		assert.Equal(t, buf, enc.Encode())
+		enc.SetStringValue("a")
+		assert.Equal(t, []byte{buf[0] & 0x3F, 0x01, 0x61}, enc.Encode())
+	}
+}
+
+func TestPrimitiveToTypedEncoder(t *testing.T) {
+	src := NewTypedPrimitivePacketEncoder(0x01)
+	src.SetInt32Value(-1)
+	buf := src.Encode()
+
+	var p PrimitivePacket
+	_, err := DecodeToPrimitivePacket(buf, &p)
+	assert.NoError(t, err)
+	enc := p.ToTypedEncoder()
+	assert.True(t, enc.IsTyped())
+	assert.Equal(t, buf, enc.Encode())
+	enc.SetStringValue("a")
+	v, err := ParseTyped(enc.Encode())
+	assert.NoError(t, err)
+	val, err := v.(*PrimitiveValue).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", val)
+}
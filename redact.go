@@ -0,0 +1,171 @@
+package y3
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// RedactMarker is the Val of the redacted primitives if Redactor has no Mask
+const RedactMarker = "[REDACTED]"
+
+// Redactor replaces the values of the selected primitives in packets, e.g.
+// to log the frames holding credentials or personal data. The redacted packet
+// keeps its structure, only the selected primitives and the lengths of their
+// ancestors are changed. A string, bytes or primitive of unknown type is
+// masked, while a primitive of other known types is replaced by null, so it's
+// not read as a fake value of its type. Nulls are kept as is.
+type Redactor struct {
+	// Paths select the packets to redact from the root packet, a path of node
+	// selects all the primitives in it, a SeqID step matches all the children
+	// with the SeqID, so its occurrence should be 0, and AnyIndex matches
+	// every element of slice
+	Paths []Path
+	// Match selects the packets to redact besides Paths, root is the SeqID of
+	// the root packet, it's optional
+	Match func(root byte, path Path) bool
+	// Mask returns the new Val of the selected primitive at path, nil means
+	// RedactMarker
+	Mask func(path Path, val []byte) []byte
+	// Kind returns the type of the primitive at path, KindUnknown if it's not
+	// known, it's optional
+	Kind func(root byte, path Path) Kind
+	// Typed means the primitives are in typed mode, the type code of a masked
+	// primitive is kept
+	Typed bool
+}
+
+// NewRedactor returns a Redactor replacing the packets at paths by
+// RedactMarker
+func NewRedactor(paths ...Path) *Redactor {
+	return &Redactor{Paths: paths}
+}
+
+// Redact returns the packet in buf with the primitives at paths replaced by
+// RedactMarker, see Redactor
+func Redact(buf []byte, paths ...Path) ([]byte, error) {
+	return NewRedactor(paths...).Redact(buf)
+}
+
+// HashMask returns a Mask replacing the value by the hex of the first 16 bytes
+// of its HMAC-SHA256 with key, so the equal values can still be correlated.
+// The values of low entropy, e.g. phone numbers, can be guessed from their
+// hashes if key is known or empty.
+func HashMask(key []byte) func(path Path, val []byte) []byte {
+	return func(path Path, val []byte) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(val)
+		sum := mac.Sum(nil)
+		return []byte(hex.EncodeToString(sum[:16]))
+	}
+}
+
+// Redact returns the redacted packet in buf, buf is not modified
+func (r *Redactor) Redact(buf []byte) ([]byte, error) {
+	if err := validate(buf, nil); err != nil {
+		return nil, fmt.Errorf("y3: %v", err)
+	}
+	p, _ := readRawPacket(buf, 0)
+	return r.redact(nil, p.tag.SeqID(), p, Path{}, false), nil
+}
+
+// redact appends the redacted packet p to dst, selected is true if an
+// ancestor is selected
+func (r *Redactor) redact(dst []byte, root byte, p *rawPacket, path Path, selected bool) []byte {
+	selected = selected || r.selects(root, path)
+	if !selected && !r.mayMatch(path) {
+		return append(dst, p.bytes()...)
+	}
+	if !p.tag.IsNode() {
+		if !selected || p.isNull() {
+			return append(dst, p.bytes()...)
+		}
+		return r.mask(dst, root, p, path)
+	}
+
+	// the packet is validated
+	children, _ := rawChildren(p)
+	var val []byte
+	for i, c := range children {
+		cp := path.Child(c.tag.SeqID())
+		if p.tag.IsSlice() {
+			cp = path.Elem(i)
+		}
+		val = r.redact(val, root, c, cp, selected)
+	}
+	return appendPacket(dst, p.header[0], val)
+}
+
+// mask appends the redacted primitive p to dst, see Redactor
+func (r *Redactor) mask(dst []byte, root byte, p *rawPacket, path Path) []byte {
+	kind, val := KindUnknown, p.val
+	if r.Typed && len(val) > 0 && Kind(val[0]).IsValid() {
+		kind, val = Kind(val[0]), val[1:]
+	} else if r.Kind != nil {
+		kind = r.Kind(root, path)
+	}
+	if kind != KindString && kind != KindBytes && (r.Typed || kind != KindUnknown) {
+		// null
+		return appendPacket(dst, p.tag.SeqID()|0x40, nil)
+	}
+
+	masked := []byte(RedactMarker)
+	if r.Mask != nil {
+		masked = r.Mask(path, val)
+	}
+	if r.Typed {
+		masked = append([]byte{byte(kind)}, masked...)
+	}
+	return appendPacket(dst, p.header[0], masked)
+}
+
+// selects returns true if the packet at path is selected
+func (r *Redactor) selects(root byte, path Path) bool {
+	for _, p := range r.Paths {
+		if p.Match(path) {
+			return true
+		}
+	}
+	return r.Match != nil && r.Match(root, path)
+}
+
+// mayMatch returns true if a descendant of the packet at path may be selected
+func (r *Redactor) mayMatch(path Path) bool {
+	if r.Match != nil {
+		return true
+	}
+	for _, p := range r.Paths {
+		if len(p) > len(path) && p[:len(path)].Match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter reads the packets from src, and writes the redacted packets to dst
+// until src returns io.EOF. A malformed packet stops the filter with error,
+// nothing of it is written.
+func (r *Redactor) Filter(dst io.Writer, src io.Reader) error {
+	br := bufio.NewReader(src)
+	for {
+		if _, err := br.Peek(1); err == io.EOF {
+			return nil
+		}
+		buf, err := ReadPacket(br)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if buf, err = r.Redact(buf); err != nil {
+			return err
+		}
+		if _, err := dst.Write(buf); err != nil {
+			return err
+		}
+	}
+}
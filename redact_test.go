@@ -0,0 +1,109 @@
+package y3
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	buf, err := ParseText(`0x3F { 0x01: "alice" 0x02: "secret" 0x03 { 0x01: "13800000000" 0x02: null 0x03: 18 }
+		0x05 [ { 0x01: "token-a" 0x02: 1 } { 0x01: "token-b" 0x02: 2 } ] 0x06 ["a" "b"] }`)
+	assert.NoError(t, err)
+
+	res, err := Redact(buf, MustParsePath("0x02"), MustParsePath("0x03"), MustParsePath("0x05[*].0x01"), MustParsePath("0x06[1]"))
+	assert.NoError(t, err)
+	assert.NoError(t, Validate(res, nil))
+	assert.Equal(t, `0x3F{0x01:"alice" 0x02:"[REDACTED]" 0x03{0x01:"[REDACTED]" 0x02:null 0x03:"[REDACTED]"} `+
+		`0x05[{0x01:"[REDACTED]" 0x02:h"01"} {0x01:"[REDACTED]" 0x02:h"02"}] 0x06["a" "[REDACTED]"]}`, packetString(t, res))
+
+	res, err = Redact(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, buf, res)
+	res, err = Redact(buf, Path{})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(res), "alice")
+
+	_, err = Redact([]byte{0x81, 0x02, 0x01})
+	assert.Error(t, err)
+}
+
+func TestRedactor(t *testing.T) {
+	buf, err := ParseText(`0x3F { 0x01: "alice" 0x02: "secret" 0x03: "secret" }`)
+	assert.NoError(t, err)
+	r := &Redactor{
+		Paths: []Path{NewPath(0x02)},
+		Match: func(root byte, path Path) bool { return root == 0x3F && path.Match(NewPath(0x03)) },
+		Mask:  HashMask([]byte("key")),
+	}
+	res, err := r.Redact(buf)
+	assert.NoError(t, err)
+	var np NodePacket
+	_, err = DecodeToNodePacket(res, &np)
+	assert.NoError(t, err)
+	name, a, b := np.PrimitivePackets[0x01], np.PrimitivePackets[0x02], np.PrimitivePackets[0x03]
+	assert.Equal(t, "alice", string(name.ToBytes()))
+	assert.Len(t, a.ToBytes(), 32)
+	assert.Equal(t, a.ToBytes(), b.ToBytes())
+	assert.NotContains(t, string(res), "secret")
+
+	var src, dst bytes.Buffer
+	for i := 0; i < 3; i++ {
+		src.Write(buf)
+	}
+	assert.NoError(t, r.Filter(&dst, &src))
+	assert.Equal(t, bytes.Repeat(res, 3), dst.Bytes())
+
+	dst.Reset()
+	src.Write(buf)
+	src.Write(buf[:5])
+	assert.Error(t, r.Filter(&dst, &src))
+	assert.Equal(t, res, dst.Bytes())
+}
+
+func packetString(t *testing.T, buf []byte) string {
+	var np NodePacket
+	_, err := DecodeToNodePacket(buf, &np)
+	assert.NoError(t, err)
+	return fmt.Sprint(np)
+}
+
+func TestRedactTyped(t *testing.T) {
+	pin, _ := NewPrimitive(0x02, int32(1234))
+	name, _ := NewPrimitive(0x01, "alice")
+	token, _ := NewPrimitive(0x03, []byte("secret"))
+	buf := NewNode(0x3F, name, pin, token).Encode()
+
+	r := NewRedactor(NewPath(0x02), NewPath(0x03))
+	r.Typed = true
+	res, err := r.Redact(buf)
+	assert.NoError(t, err)
+	v, err := ParseTyped(res)
+	assert.NoError(t, err)
+	root := v.(*NodeValue)
+	assert.True(t, root.Get(0x02).(*PrimitiveValue).IsNull())
+	val, err := root.Get(0x03).(*PrimitiveValue).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(RedactMarker), val)
+	val, err = root.Get(0x01).(*PrimitiveValue).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", val)
+
+	// the type is given by Kind
+	r = &Redactor{
+		Paths: []Path{NewPath(0x01), NewPath(0x02)},
+		Kind: func(root byte, path Path) Kind {
+			if path.Match(NewPath(0x02)) {
+				return KindInt32
+			}
+			return KindUnknown
+		},
+	}
+	buf, err = ParseText(`0x3F { 0x01: "alice" 0x02: 1234 }`)
+	assert.NoError(t, err)
+	res, err = r.Redact(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, mustParseText(t, `0x3F { 0x01: "[REDACTED]" 0x02: null }`), res)
+}
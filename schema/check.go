@@ -0,0 +1,126 @@
+package schema
+
+import "fmt"
+
+// Incompatibility describes a change which breaks the readers of the old schema,
+// or the readers of the new schema when decoding packets written by the old one
+type Incompatibility struct {
+	// Message is the full name of the message in the new schema
+	Message string
+	// Field is the name of the field, empty if the message itself is changed
+	Field string
+	SeqID byte
+	// Pos is the position in the new schema, or in the old one if removed
+	Pos    Pos
+	Reason string
+}
+
+func (i *Incompatibility) Error() string {
+	if i.Field == "" {
+		return fmt.Sprintf("%v: message %s: %s", i.Pos, i.Message, i.Reason)
+	}
+	return fmt.Sprintf("%v: field %s.%s (%#x): %s", i.Pos, i.Message, i.Field, i.SeqID, i.Reason)
+}
+
+// Check compares the messages with the same full name in both schemas, and
+// returns the incompatible changes. Fields are matched by SeqID, so renaming
+// a field is safe, and so is adding an optional or repeated field. Reusing a
+// SeqID with another type, changing between node and primitive, or between
+// slice and non-slice, removing or adding a required field, and changing
+// between required and optional are reported, since the old writers may omit
+// the optional field. Enums are compared as int32.
+func Check(old, new *Schema) []*Incompatibility {
+	c := &checker{checked: map[[2]*Message]bool{}}
+	old.Walk(func(om *Message) {
+		nm := new.Lookup(om.FullName())
+		if nm == nil {
+			if om.HasSeqID {
+				c.report(om, nil, om.Pos, "removed")
+			}
+			return
+		}
+		if om.HasSeqID && (!nm.HasSeqID || nm.SeqID != om.SeqID) {
+			c.report(nm, nil, nm.Pos, fmt.Sprintf("SeqID changed from %#x", om.SeqID))
+		}
+		c.message(om, nm)
+	})
+	return c.res
+}
+
+type checker struct {
+	res []*Incompatibility
+	// checked is the message pairs already compared, avoids the infinite
+	// loop of recursive messages
+	checked map[[2]*Message]bool
+}
+
+func (c *checker) report(m *Message, f *Field, pos Pos, reason string) {
+	i := &Incompatibility{Message: m.FullName(), Pos: pos, Reason: reason}
+	if f != nil {
+		i.Field = f.Name
+		i.SeqID = f.SeqID
+	}
+	c.res = append(c.res, i)
+}
+
+func (c *checker) message(om, nm *Message) {
+	key := [2]*Message{om, nm}
+	if c.checked[key] {
+		return
+	}
+	c.checked[key] = true
+
+	for _, of := range om.Fields {
+		nf := nm.Field(of.SeqID)
+		if nf == nil {
+			if of.Label == Required {
+				c.report(nm, of, of.Pos, "required field removed")
+			}
+			continue
+		}
+		c.field(nm, of, nf)
+	}
+	for _, nf := range nm.Fields {
+		if om.Field(nf.SeqID) == nil && nf.Label == Required {
+			c.report(nm, nf, nf.Pos, "required field added")
+		}
+	}
+}
+
+func (c *checker) field(nm *Message, of, nf *Field) {
+	switch {
+	case (of.Label == Repeated) != (nf.Label == Repeated):
+		c.report(nm, nf, nf.Pos, fmt.Sprintf("changed from %s to %s", of.Label, nf.Label))
+		return
+	case of.Label == Required && nf.Label == Optional:
+		c.report(nm, nf, nf.Pos, "required field became optional")
+	case of.Label == Optional && nf.Label == Required:
+		c.report(nm, nf, nf.Pos, "optional field became required")
+	}
+
+	switch {
+	case (of.Message == nil) != (nf.Message == nil):
+		c.report(nm, nf, nf.Pos, fmt.Sprintf("changed from %s to %s", kindOf(of), kindOf(nf)))
+	case of.Message == nil:
+		if wireType(of) != wireType(nf) {
+			c.report(nm, nf, nf.Pos, fmt.Sprintf("type changed from %s to %s", of.Type, nf.Type))
+		}
+	default:
+		c.message(of.Message, nf.Message)
+	}
+}
+
+func kindOf(f *Field) string {
+	if f.Message != nil {
+		return "node " + f.Message.FullName()
+	}
+	return "primitive " + f.Type
+}
+
+// wireType returns the scalar type of primitive field, enums are int32
+func wireType(f *Field) string {
+	if f.Enum != nil {
+		return "int32"
+	}
+	return f.Type
+}
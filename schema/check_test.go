@@ -0,0 +1,151 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustParse(t *testing.T, src string) *Schema {
+	s, err := Parse([]byte(src))
+	assert.NoError(t, err)
+	return s
+}
+
+const checkOld = `
+message Frame = 0x3F {
+    string id = 0x01;
+    optional int32 retry = 0x02;
+    Meta meta = 0x03;
+    repeated string tags = 0x04;
+    bytes payload = 0x05;
+    int64 at = 0x06;
+}
+
+message Meta {
+    string name = 0x01;
+    optional bool debug = 0x02;
+}
+
+message Ack = 0x3E {
+    string id = 0x01;
+}
+`
+
+func TestCheckSafeChanges(t *testing.T) {
+	s := mustParse(t, `
+message Frame = 0x3F {
+    string id = 0x01;
+    // optional field removed
+    Meta meta = 0x03;
+    repeated string tags = 0x04;
+    // renamed
+    bytes data = 0x05;
+    int64 at = 0x06;
+    // new optional and repeated fields
+    optional string note = 0x07;
+    repeated Meta history = 0x08;
+}
+
+message Meta {
+    string name = 0x01;
+    optional bool debug = 0x02;
+}
+
+message Ack = 0x3E {
+    string id = 0x01;
+}
+
+message Ping = 0x3D {
+    int64 at = 0x01;
+}
+`)
+	assert.Empty(t, Check(mustParse(t, checkOld), s))
+}
+
+func TestCheckIncompatibleChanges(t *testing.T) {
+	s := mustParse(t, `
+message Frame = 0x3F {
+    optional string id = 0x01;
+    string retry = 0x02;
+    bytes meta = 0x03;
+    string tags = 0x04;
+    // payload removed
+    int32 at = 0x06;
+    int32 seq = 0x07;
+}
+
+message Meta {
+    string name = 0x01;
+    bool debug = 0x02;
+}
+`)
+	res := Check(mustParse(t, checkOld), s)
+	var msgs []string
+	for _, i := range res {
+		msgs = append(msgs, i.Error())
+	}
+	assert.Equal(t, []string{
+		"3:5: field Frame.id (0x1): required field became optional",
+		"4:5: field Frame.retry (0x2): optional field became required",
+		"4:5: field Frame.retry (0x2): type changed from int32 to string",
+		"5:5: field Frame.meta (0x3): changed from node Meta to primitive bytes",
+		"6:5: field Frame.tags (0x4): changed from repeated to required",
+		"7:5: field Frame.payload (0x5): required field removed",
+		"8:5: field Frame.at (0x6): type changed from int64 to int32",
+		"9:5: field Frame.seq (0x7): required field added",
+		"14:5: field Meta.debug (0x2): optional field became required",
+		"16:1: message Ack: removed",
+	}, msgs)
+}
+
+func TestCheckNestedAndRecursive(t *testing.T) {
+	old := mustParse(t, `
+message Tree = 0x01 {
+    repeated Tree children = 0x01;
+    Node node = 0x02;
+    message Node {
+        string value = 0x01;
+    }
+}
+`)
+	s := mustParse(t, `
+message Tree = 0x02 {
+    repeated Tree children = 0x01;
+    Node node = 0x02;
+    message Node {
+        int64 value = 0x01;
+    }
+}
+`)
+	res := Check(old, s)
+	assert.Len(t, res, 2)
+	assert.Equal(t, "2:1: message Tree: SeqID changed from 0x1", res[0].Error())
+	assert.Equal(t, &Incompatibility{
+		Message: "Tree.Node",
+		Field:   "value",
+		SeqID:   0x01,
+		Pos:     Pos{Line: 6, Col: 9},
+		Reason:  "type changed from string to int64",
+	}, res[1])
+}
+
+func TestCheckEnum(t *testing.T) {
+	old := mustParse(t, `
+message A = 0x01 {
+    int32 code = 0x01;
+    Status status = 0x02;
+}
+enum Status { OK = 0; }
+`)
+	s := mustParse(t, `
+message A = 0x01 {
+    Code code = 0x01;
+    string status = 0x02;
+}
+enum Code { OK = 0; }
+`)
+	res := Check(old, s)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "4:5: field A.status (0x2): type changed from Status to string", res[0].Error())
+}
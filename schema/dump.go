@@ -0,0 +1,155 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/yomorun/y3"
+)
+
+// Dump returns the hex dump of the packets in buf like y3.Dump, the packets
+// described by s are commented with the field names and the typed values,
+// enums are rendered by their symbolic names
+func Dump(s *Schema, buf []byte) string {
+	return y3.DumpWith(buf, &y3.DumpOptions{
+		Annotate: func(path []byte, isNode bool, val []byte) string {
+			return s.annotate(path, isNode, val)
+		},
+	})
+}
+
+// ValueFunc returns the y3.ValueFunc printing the primitives described by s
+// as the types of their fields, enums by their symbolic names, e.g.
+//
+//	text, err := y3.FormatTextWith(buf, schema.ValueFunc(s))
+func ValueFunc(s *Schema) y3.ValueFunc {
+	return func(path []byte, val []byte) string {
+		root := s.rootMessage(path[0])
+		if root == nil || len(path) == 1 {
+			return ""
+		}
+		f, elem := root.fieldAt(path[1:])
+		if f == nil || f.Message != nil || f.Label == Repeated && !elem {
+			return ""
+		}
+		v, _ := f.format(path[len(path)-1], val)
+		return v
+	}
+}
+
+func (s *Schema) annotate(path []byte, isNode bool, val []byte) string {
+	sid := path[len(path)-1]
+	root := s.rootMessage(path[0])
+	if root == nil {
+		return ""
+	}
+	if len(path) == 1 {
+		return fmt.Sprintf("0x%02X %s", sid, root.FullName())
+	}
+
+	f, elem := root.fieldAt(path[1:])
+	if f == nil {
+		return ""
+	}
+	name := f.Name
+	if elem {
+		name += "[]"
+	}
+	switch {
+	case f.Label == Repeated && !elem:
+		return fmt.Sprintf("0x%02X %s: repeated %s", sid, name, f.Type)
+	case isNode:
+		return fmt.Sprintf("0x%02X %s: %s", sid, name, f.Type)
+	}
+	v, ok := f.format(sid, val)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("0x%02X %s = %s", sid, name, v)
+}
+
+// rootMessage returns the top level message encoded as the root packet with
+// SeqID sid, nil if not exists
+func (s *Schema) rootMessage(sid byte) *Message {
+	for _, m := range s.Messages {
+		if m.HasSeqID && m.SeqID == sid {
+			return m
+		}
+	}
+	return nil
+}
+
+// fieldAt returns the field of the packet at path from m, and if the packet
+// is an element of the field, f is nil if the packet is not described
+func (m *Message) fieldAt(path []byte) (f *Field, elem bool) {
+	for _, sid := range path {
+		if f != nil && f.Label == Repeated && !elem {
+			elem = true
+			continue
+		}
+		if f != nil {
+			if m = f.Message; m == nil {
+				return nil, false
+			}
+		}
+		if f, elem = m.Field(sid), false; f == nil {
+			return nil, false
+		}
+	}
+	return f, elem
+}
+
+// format returns the text of the Val decoded as the type of f
+func (f *Field) format(sid byte, val []byte) (string, bool) {
+	p := y3.NewRawPrimitive(sid, val)
+	if f.Enum != nil {
+		var v int32
+		if err := p.Decode(&v); err != nil {
+			return "", false
+		}
+		for _, ev := range f.Enum.Values {
+			if ev.Value == v {
+				return ev.Name, true
+			}
+		}
+		return strconv.Itoa(int(v)), true
+	}
+
+	var v any
+	switch f.Type {
+	case "int32":
+		v = new(int32)
+	case "int64":
+		v = new(int64)
+	case "uint32":
+		v = new(uint32)
+	case "uint64":
+		v = new(uint64)
+	case "float32":
+		v = new(float32)
+	case "float64":
+		v = new(float64)
+	case "bool":
+		v = new(bool)
+	case "string":
+		v = new(string)
+	case "bytes":
+		return fmt.Sprintf("h\"%X\"", val), true
+	case "time":
+		v = new(time.Time)
+	default:
+		return "", false
+	}
+	if err := p.Decode(v); err != nil {
+		return "", false
+	}
+	switch v := v.(type) {
+	case *string:
+		return strconv.Quote(*v), true
+	case *time.Time:
+		return v.Format(time.RFC3339Nano), true
+	}
+	return fmt.Sprint(reflect.ValueOf(v).Elem().Interface()), true
+}
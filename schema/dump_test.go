@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/y3"
+)
+
+func TestDump(t *testing.T) {
+	s := mustParse(t, `
+message Response = 0x01 {
+    Status status = 0x01;
+    repeated string tags = 0x02;
+    optional Detail detail = 0x03;
+
+    message Detail {
+        int64 code = 0x01;
+    }
+}
+enum Status { OK = 0; ERROR = 1; }
+`)
+	buf, err := y3.ParseText(`0x01 { 0x01: 1 0x02 ["a"] 0x03 { 0x01: -1 0x02: 2 } 0x04: null }`)
+	assert.NoError(t, err)
+	assert.Equal(t, `000000  81 12     |                                                  # 0x01 Response
+000002    01 01   | 01                                               # 0x01 status = ERROR
+000005    C2 03   |                                                  # 0x02 tags: repeated string
+000007      00 01 | 61                                               # 0x00 tags[] = "a"
+00000A    83 06   |                                                  # 0x03 detail: Detail
+00000C      01 01 | FF                                               # 0x01 code = -1
+00000F      02 01 | 02                                               # 0x02 h"02"
+000012    44 00   |                                                  # 0x04 null
+`, Dump(s, buf))
+}
+
+func TestValueFunc(t *testing.T) {
+	s := mustParse(t, `
+message Response = 0x01 {
+    Status status = 0x01;
+    repeated Status history = 0x02;
+    int64 code = 0x03;
+}
+enum Status { OK = 0; ERROR = 1; }
+`)
+	buf, err := y3.ParseText(`0x01 { 0x01: 1 0x02 [0 3] 0x03: -1 0x04: 2 }`)
+	assert.NoError(t, err)
+
+	text, err := y3.FormatTextWith(buf, ValueFunc(s))
+	assert.NoError(t, err)
+	assert.Equal(t, `0x01 {
+    0x01: ERROR
+    0x02 [
+        OK
+        3
+    ]
+    0x03: -1
+    0x04: h"02"
+}
+`, text)
+	assert.Equal(t, `0x01{0x01:ERROR 0x02[OK 3] 0x03:-1 0x04:h"02"}`, fmt.Sprint(y3.Formatted(buf, ValueFunc(s))))
+	assert.Contains(t, y3.DumpWith(buf, &y3.DumpOptions{Value: ValueFunc(s)}), "# 0x01 ERROR")
+}
@@ -0,0 +1,73 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Format returns the schema source of s, which can be parsed by Parse
+func Format(s *Schema) []byte {
+	var buf bytes.Buffer
+	if s.Package != "" {
+		fmt.Fprintf(&buf, "package %s;\n", s.Package)
+	}
+	first := s.Package == ""
+	sep := func() {
+		if !first {
+			buf.WriteByte('\n')
+		}
+		first = false
+	}
+	for _, e := range s.Enums {
+		sep()
+		formatEnum(&buf, e, 0)
+	}
+	for _, m := range s.Messages {
+		sep()
+		formatMessage(&buf, m, 0)
+	}
+	return buf.Bytes()
+}
+
+func formatEnum(buf *bytes.Buffer, e *Enum, depth int) {
+	indent := strings.Repeat("    ", depth)
+	buf.WriteString(indent)
+	if e.Closed {
+		buf.WriteString("closed ")
+	}
+	fmt.Fprintf(buf, "enum %s {\n", e.Name)
+	for _, v := range e.Values {
+		fmt.Fprintf(buf, "%s    %s = %d;\n", indent, v.Name, v.Value)
+	}
+	fmt.Fprintf(buf, "%s}\n", indent)
+}
+
+func formatMessage(buf *bytes.Buffer, m *Message, depth int) {
+	indent := strings.Repeat("    ", depth)
+	fmt.Fprintf(buf, "%smessage %s", indent, m.Name)
+	if m.HasSeqID {
+		fmt.Fprintf(buf, " = %s", hexID(m.SeqID))
+	}
+	buf.WriteString(" {\n")
+	for _, f := range m.Fields {
+		buf.WriteString(indent + "    ")
+		if f.Label != Required {
+			fmt.Fprintf(buf, "%v ", f.Label)
+		}
+		fmt.Fprintf(buf, "%s %s = %s", f.Type, f.Name, hexID(f.SeqID))
+		if f.Redact {
+			buf.WriteString(" [redact]")
+		}
+		buf.WriteString(";\n")
+	}
+	for _, e := range m.Enums {
+		buf.WriteByte('\n')
+		formatEnum(buf, e, depth+1)
+	}
+	for _, nested := range m.Messages {
+		buf.WriteByte('\n')
+		formatMessage(buf, nested, depth+1)
+	}
+	fmt.Fprintf(buf, "%s}\n", indent)
+}
@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	s, err := Parse([]byte(testSchema))
+	assert.NoError(t, err)
+	src := Format(s)
+	assert.Equal(t, `package demo;
+
+message DataFrame = 0x3F {
+    MetaFrame meta = 0x2F;
+    optional Payload payload = 0x2E;
+
+    message Payload {
+        bytes carriage = 0x01;
+    }
+}
+
+message MetaFrame {
+    string transaction_id = 0x01;
+    optional int64 timestamp = 0x02;
+    repeated string tags = 0x03 [redact];
+    repeated DataFrame.Payload history = 0x04;
+}
+`, string(src))
+
+	// parse the formatted source again
+	res, err := Parse(src)
+	assert.NoError(t, err)
+	assert.Equal(t, src, Format(res))
+}
+
+func TestFormatEnum(t *testing.T) {
+	src := `closed enum Status {
+    OK = 0;
+    ERROR = -1;
+}
+
+message Response = 0x01 {
+    Status status = 0x01;
+    optional Level level = 0x02;
+
+    enum Level {
+        INFO = 1;
+    }
+
+    message Detail {
+    }
+}
+`
+	s, err := Parse([]byte(src))
+	assert.NoError(t, err)
+	assert.Equal(t, src, string(Format(s)))
+}
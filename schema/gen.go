@@ -0,0 +1,425 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// scalarType describes how a primitive type is encoded and decoded in Go
+type scalarType struct {
+	goType string
+	// setter is the method of y3.PrimitivePacketEncoder, or a
+	// func(*y3.PrimitivePacketEncoder, T) expression
+	setter string
+	// getter is a func(*y3.PrimitivePacket) (T, error) expression
+	getter string
+}
+
+// scalarOf returns the scalar type of the primitive field
+func scalarOf(f *Field) scalarType {
+	if f.Enum != nil {
+		name := GoEnumName(f.Enum)
+		return scalarType{name, "y3.SetEnumValue[" + name + "]", "y3.ToEnum[" + name + "]"}
+	}
+	return scalarTypes[f.Type]
+}
+
+// setterFunc returns the func(*y3.PrimitivePacketEncoder, T) expression
+func (t scalarType) setterFunc() string {
+	if strings.HasPrefix(t.setter, "y3.") {
+		return t.setter
+	}
+	return "(*y3.PrimitivePacketEncoder)." + t.setter
+}
+
+// setterCall returns the statement encoding v by the encoder variable p
+func (t scalarType) setterCall(p, v string) string {
+	if strings.HasPrefix(t.setter, "y3.") {
+		return t.setter + "(" + p + ", " + v + ")"
+	}
+	return p + "." + t.setter + "(" + v + ")"
+}
+
+// getterCall returns the expression decoding the primitive packet variable p
+func (t scalarType) getterCall(p string) string {
+	if method := strings.TrimPrefix(t.getter, "(*y3.PrimitivePacket)."); method != t.getter {
+		return p + "." + method + "()"
+	}
+	return t.getter + "(&" + p + ")"
+}
+
+var scalarTypes = map[string]scalarType{
+	"int32":   {"int32", "SetInt32Value", "(*y3.PrimitivePacket).ToInt32"},
+	"int64":   {"int64", "SetInt64Value", "(*y3.PrimitivePacket).ToInt64"},
+	"uint32":  {"uint32", "SetUInt32Value", "(*y3.PrimitivePacket).ToUInt32"},
+	"uint64":  {"uint64", "SetUInt64Value", "(*y3.PrimitivePacket).ToUInt64"},
+	"float32": {"float32", "SetFloat32Value", "(*y3.PrimitivePacket).ToFloat32"},
+	"float64": {"float64", "SetFloat64Value", "(*y3.PrimitivePacket).ToFloat64"},
+	"bool":    {"bool", "SetBoolValue", "(*y3.PrimitivePacket).ToBool"},
+	"string":  {"string", "SetStringValue", "(*y3.PrimitivePacket).ToUTF8String"},
+	"bytes":   {"[]byte", "SetBytesValue", "y3BytesValue"},
+	"time":    {"time.Time", "SetTimeValue", "(*y3.PrimitivePacket).ToTime"},
+}
+
+// GoOptions describes how Go code is generated
+type GoOptions struct {
+	// Package overrides the package name declared in the schema
+	Package string
+	// Source is the schema file name written in the header comment
+	Source string
+}
+
+// UnknownFieldsName is the name of the generated field which keeps the
+// children not declared in the schema, they are encoded back unchanged after
+// the declared fields, see y3.NodePacket.UnknownBytes
+const UnknownFieldsName = "UnknownFields"
+
+// GenerateGo generates Go types with Encode and Decode methods for all the
+// messages in the schema. The fields have y3 tags, so the types can also be
+// used with y3.Marshal and y3.Unmarshal, but the encodings may differ, e.g. a
+// nil required message is encoded as an empty node by Encode and as null by
+// y3.Marshal.
+func GenerateGo(s *Schema, opts GoOptions) ([]byte, error) {
+	pkg := opts.Package
+	if pkg == "" {
+		pkg = s.Package
+	}
+	if pkg == "" {
+		return nil, fmt.Errorf("schema: no package name")
+	}
+
+	var err error
+	s.Walk(func(m *Message) {
+		for _, f := range m.Fields {
+			if err == nil && GoFieldName(f.Name) == UnknownFieldsName {
+				err = fmt.Errorf("schema: %v: field %s.%s conflicts with the generated %s", f.Pos, m.FullName(), f.Name, UnknownFieldsName)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{}
+	s.WalkEnums(func(e *Enum) {
+		name := e.FullName()
+		if s.Package != "" {
+			name = s.Package + "." + name
+		}
+		g.enum(e, name)
+	})
+	s.Walk(func(m *Message) {
+		g.message(m)
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by y3 schema compile. DO NOT EDIT.\n")
+	if opts.Source != "" {
+		fmt.Fprintf(&buf, "// source: %s\n", opts.Source)
+	}
+	fmt.Fprintf(&buf, "\npackage %s\n\nimport (\n", pkg)
+	if g.needErrors {
+		buf.WriteString("\t\"errors\"\n")
+	}
+	if g.needTime {
+		buf.WriteString("\t\"time\"\n")
+	}
+	buf.WriteString("\n\t\"github.com/yomorun/y3\"\n)\n")
+	buf.Write(g.buf.Bytes())
+	if g.needBytes {
+		buf.WriteString(`
+func y3BytesValue(p *y3.PrimitivePacket) ([]byte, error) {
+	return append([]byte{}, p.ToBytes()...), nil
+}
+`)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("schema: format generated code: %v", err)
+	}
+	return src, nil
+}
+
+// GoName returns the Go type name of message, nested messages are joined by "_"
+func GoName(m *Message) string {
+	return goTypeName(m.FullName())
+}
+
+// GoEnumName returns the Go type name of enum, like GoName
+func GoEnumName(e *Enum) string {
+	return goTypeName(e.FullName())
+}
+
+func goTypeName(fullName string) string {
+	return strings.ReplaceAll(fullName, ".", "_")
+}
+
+// GoFieldName converts snake_case field name to CamelCase
+func GoFieldName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]))
+		b.WriteString(part[1:])
+	}
+	return b.String()
+}
+
+func hexID(sid byte) string {
+	return fmt.Sprintf("0x%02X", sid)
+}
+
+type generator struct {
+	buf        bytes.Buffer
+	needErrors bool
+	needTime   bool
+	needBytes  bool
+}
+
+func (g *generator) p(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+// goType returns the Go type of field
+func (g *generator) goType(f *Field) string {
+	var typ string
+	if f.Message != nil {
+		typ = "*" + GoName(f.Message)
+	} else {
+		typ = scalarOf(f).goType
+		if f.Type == "time" {
+			g.needTime = true
+		}
+		if f.Label == Optional && f.Type != "bytes" {
+			typ = "*" + typ
+		}
+	}
+	if f.Label == Repeated {
+		typ = "[]" + typ
+	}
+	return typ
+}
+
+// enum generates the enum type, its constants and registers it with the name
+func (g *generator) enum(e *Enum, name string) {
+	typ := GoEnumName(e)
+	g.p("")
+	g.p("// %s is the enum %s", typ, e.FullName())
+	g.p("type %s int32", typ)
+	g.p("")
+	g.p("const (")
+	for _, v := range e.Values {
+		g.p("\t%s_%s %s = %d", typ, v.Name, typ, v.Value)
+	}
+	g.p(")")
+
+	register := "RegisterEnum"
+	if e.Closed {
+		register = "RegisterClosedEnum"
+	}
+	g.p("")
+	g.p("// %sEnum is the registered enum of %s", typ, typ)
+	g.p("var %sEnum = y3.%s(%q, map[string]%s{", typ, register, name, typ)
+	for _, v := range e.Values {
+		g.p("\t%q: %s_%s,", v.Name, typ, v.Name)
+	}
+	g.p("})")
+
+	g.p("")
+	g.p("// String returns the symbolic name of x")
+	g.p("func (x %s) String() string {", typ)
+	g.p("\treturn %sEnum.String(int32(x))", typ)
+	g.p("}")
+}
+
+func (g *generator) message(m *Message) {
+	name := GoName(m)
+	g.p("")
+	g.p("// %s is the message %s", name, m.FullName())
+	g.p("type %s struct {", name)
+	for _, f := range m.Fields {
+		g.p("\t%s %s `y3:\"%s\"`", GoFieldName(f.Name), g.goType(f), hexID(f.SeqID))
+	}
+	g.p("\t// %s keeps the children not declared in the schema", UnknownFieldsName)
+	g.p("\t%s []byte `y3:\",unknown\"`", UnknownFieldsName)
+	g.p("}")
+
+	if m.HasSeqID {
+		g.p("")
+		g.p("// SeqIDOf%s is the SeqID of %s when encoded as a root packet", name, name)
+		g.p("const SeqIDOf%s byte = %s", name, hexID(m.SeqID))
+	}
+
+	g.p("")
+	g.p("// Encode returns the Y3 encoded bytes of %s with SeqID %s", name, hexID(m.SeqID))
+	g.p("func (m *%s) Encode() []byte {", name)
+	g.p("\treturn m.ToNodePacketEncoder(%s).Encode()", hexID(m.SeqID))
+	g.p("}")
+
+	g.p("")
+	g.p("// ToNodePacketEncoder returns the encoder of %s with SeqID sid", name)
+	g.p("func (m *%s) ToNodePacketEncoder(sid byte) *y3.NodePacketEncoder {", name)
+	g.p("\tenc := y3.NewNodePacketEncoder(sid)")
+	for _, f := range m.Fields {
+		g.encodeField(f)
+	}
+	g.p("\tenc.AddBytes(m.%s)", UnknownFieldsName)
+	g.p("\treturn enc")
+	g.p("}")
+
+	g.p("")
+	g.p("// Decode parse Y3 encoded bytes to %s", name)
+	g.p("func (m *%s) Decode(buf []byte) error {", name)
+	g.p("\tnp := &y3.NodePacket{}")
+	g.p("\tif _, err := y3.DecodeToNodePacket(buf, np); err != nil {")
+	g.p("\t\treturn err")
+	g.p("\t}")
+	g.p("\treturn m.DecodeNodePacket(np)")
+	g.p("}")
+
+	g.p("")
+	g.p("// DecodeNodePacket parse a decoded node packet to %s", name)
+	g.p("func (m *%s) DecodeNodePacket(np *y3.NodePacket) error {", name)
+	sids := make([]string, len(m.Fields))
+	for i, f := range m.Fields {
+		g.decodeField(m, f)
+		sids[i] = hexID(f.SeqID)
+	}
+	g.p("\tm.%s = np.UnknownBytes(%s)", UnknownFieldsName, strings.Join(sids, ", "))
+	g.p("\treturn nil")
+	g.p("}")
+}
+
+func (g *generator) encodeField(f *Field) {
+	field := "m." + GoFieldName(f.Name)
+	sid := hexID(f.SeqID)
+
+	if f.Label == Repeated {
+		g.p("\t{")
+		g.p("\t\ts := y3.NewNodeSlicePacketEncoder(%s)", sid)
+		g.p("\t\tfor _, v := range %s {", field)
+		if f.Message != nil {
+			g.p("\t\t\ts.AddNodePacket(v.ToNodePacketEncoder(y3.SliceElementSeqID))")
+		} else {
+			g.p("\t\t\tp := y3.NewPrimitivePacketEncoder(y3.SliceElementSeqID)")
+			g.p("\t\t\t%s", scalarOf(f).setterCall("p", "v"))
+			g.p("\t\t\ts.AddPrimitivePacket(p)")
+		}
+		g.p("\t\t}")
+		g.p("\t\tenc.AddNodePacket(s)")
+		g.p("\t}")
+		return
+	}
+
+	if f.Message != nil {
+		g.p("\tif %s != nil {", field)
+		g.p("\t\tenc.AddNodePacket(%s.ToNodePacketEncoder(%s))", field, sid)
+		g.p("\t} else {")
+		if f.Label == Optional {
+			g.p("\t\tp := y3.NewPrimitivePacketEncoder(%s)", sid)
+			g.p("\t\tp.SetNull()")
+			g.p("\t\tenc.AddPrimitivePacket(p)")
+		} else {
+			g.p("\t\tenc.AddNodePacket((&%s{}).ToNodePacketEncoder(%s))", GoName(f.Message), sid)
+		}
+		g.p("\t}")
+		return
+	}
+
+	typ := scalarOf(f)
+	g.p("\t{")
+	g.p("\t\tp := y3.NewPrimitivePacketEncoder(%s)", sid)
+	switch {
+	case f.Label == Optional && f.Type == "bytes":
+		g.p("\t\tif %s == nil {", field)
+		g.p("\t\t\tp.SetNull()")
+		g.p("\t\t} else {")
+		g.p("\t\t\t%s", typ.setterCall("p", field))
+		g.p("\t\t}")
+	case f.Label == Optional:
+		g.p("\t\ty3.SetOptionalValue(p, %s, %s)", field, typ.setterFunc())
+	default:
+		g.p("\t\t%s", typ.setterCall("p", field))
+	}
+	g.p("\t\tenc.AddPrimitivePacket(p)")
+	g.p("\t}")
+}
+
+func (g *generator) decodeField(m *Message, f *Field) {
+	field := "m." + GoFieldName(f.Name)
+	sid := hexID(f.SeqID)
+	if f.Type == "bytes" {
+		g.needBytes = true
+	}
+
+	switch {
+	case f.Label == Repeated:
+		g.p("\tif n, ok := np.NodePackets[%s]; ok {", sid)
+		if f.Message != nil {
+			g.p("\t\tv, err := y3.DecodeNodeSlice(&n, func(n *y3.NodePacket) (*%s, error) {", GoName(f.Message))
+			g.p("\t\t\tv := &%s{}", GoName(f.Message))
+			g.p("\t\t\treturn v, v.DecodeNodePacket(n)")
+			g.p("\t\t})")
+		} else {
+			g.p("\t\tv, err := y3.DecodePrimitiveSlice(&n, %s)", scalarOf(f).getter)
+		}
+		g.p("\t\tif err != nil {")
+		g.p("\t\t\treturn err")
+		g.p("\t\t}")
+		g.p("\t\t%s = v", field)
+		g.p("\t}")
+
+	case f.Message != nil:
+		g.p("\tif n, ok := np.NodePackets[%s]; ok {", sid)
+		g.p("\t\t%s = &%s{}", field, GoName(f.Message))
+		g.p("\t\tif err := %s.DecodeNodePacket(&n); err != nil {", field)
+		g.p("\t\t\treturn err")
+		g.p("\t\t}")
+		if f.Label == Optional {
+			g.p("\t} else if np.IsNull(%s) {", sid)
+			g.p("\t\t%s = nil", field)
+		} else {
+			g.missing(m, f)
+		}
+		g.p("\t}")
+
+	case f.Label == Optional:
+		g.p("\tif p, ok := np.PrimitivePackets[%s]; ok {", sid)
+		if f.Type == "bytes" {
+			g.p("\t\tif p.IsNull() {")
+			g.p("\t\t\t%s = nil", field)
+			g.p("\t\t} else {")
+			g.p("\t\t\t%s, _ = y3BytesValue(&p)", field)
+			g.p("\t\t}")
+		} else {
+			g.p("\t\tv, err := y3.ToOptionalValue(&p, %s)", scalarOf(f).getter)
+			g.p("\t\tif err != nil {")
+			g.p("\t\t\treturn err")
+			g.p("\t\t}")
+			g.p("\t\t%s = v", field)
+		}
+		g.p("\t}")
+
+	default:
+		g.p("\tif p, ok := np.PrimitivePackets[%s]; ok {", sid)
+		g.p("\t\tv, err := %s", scalarOf(f).getterCall("p"))
+		g.p("\t\tif err != nil {")
+		g.p("\t\t\treturn err")
+		g.p("\t\t}")
+		g.p("\t\t%s = v", field)
+		g.missing(m, f)
+		g.p("\t}")
+	}
+}
+
+func (g *generator) missing(m *Message, f *Field) {
+	g.needErrors = true
+	g.p("\t} else {")
+	g.p("\t\treturn errors.New(\"y3: required field %s.%s (%s) is missing\")", m.FullName(), f.Name, hexID(f.SeqID))
+}
@@ -0,0 +1,75 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateGo(t *testing.T) {
+	s, err := Parse([]byte(testSchema))
+	assert.NoError(t, err)
+
+	src, err := GenerateGo(s, GoOptions{Source: "demo.y3"})
+	assert.NoError(t, err)
+	code := string(src)
+	assert.True(t, strings.HasPrefix(code, "// Code generated by y3 schema compile. DO NOT EDIT.\n// source: demo.y3\n\npackage demo\n"))
+	assert.Contains(t, code, "type DataFrame struct {\n\tMeta    *MetaFrame         `y3:\"0x2F\"`\n\tPayload *DataFrame_Payload `y3:\"0x2E\"`\n\t// UnknownFields keeps the children not declared in the schema\n\tUnknownFields []byte `y3:\",unknown\"`\n}")
+	assert.Contains(t, code, "\tenc.AddBytes(m.UnknownFields)\n")
+	assert.Contains(t, code, "\tm.UnknownFields = np.UnknownBytes(0x2F, 0x2E)\n")
+	assert.Contains(t, code, "const SeqIDOfDataFrame byte = 0x3F")
+	assert.Contains(t, code, "\tTimestamp     *int64               `y3:\"0x02\"`")
+	assert.Contains(t, code, "\tHistory       []*DataFrame_Payload `y3:\"0x04\"`")
+	assert.Contains(t, code, "return errors.New(\"y3: required field MetaFrame.transaction_id (0x01) is missing\")")
+	assert.Contains(t, code, "func y3BytesValue(p *y3.PrimitivePacket) ([]byte, error) {")
+	assert.NotContains(t, code, "\"time\"")
+
+	src, err = GenerateGo(s, GoOptions{Package: "frames"})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(src), "// Code generated by y3 schema compile. DO NOT EDIT.\n\npackage frames\n"))
+}
+
+func TestGenerateGoNoPackage(t *testing.T) {
+	s, err := Parse([]byte(`message A { int32 a = 0x01; }`))
+	assert.NoError(t, err)
+	_, err = GenerateGo(s, GoOptions{})
+	assert.EqualError(t, err, "schema: no package name")
+}
+
+func TestGoFieldName(t *testing.T) {
+	assert.Equal(t, "TransactionId", GoFieldName("transaction_id"))
+	assert.Equal(t, "Name", GoFieldName("name"))
+	assert.Equal(t, "ABC", GoFieldName("a_b__c"))
+}
+
+func TestGenerateGoUnknownFieldsConflict(t *testing.T) {
+	s, err := Parse([]byte(`package demo; message A { bytes unknown_fields = 0x01; }`))
+	assert.NoError(t, err)
+	_, err = GenerateGo(s, GoOptions{})
+	assert.EqualError(t, err, "schema: 1:27: field A.unknown_fields conflicts with the generated UnknownFields")
+}
+
+func TestGenerateGoEnum(t *testing.T) {
+	s, err := Parse([]byte(`package demo;
+closed enum Status { OK = 0; NOT_FOUND = 404; }
+message Response {
+    Status status = 0x01;
+    optional Level level = 0x02;
+    repeated Status history = 0x03;
+    enum Level { INFO = 1; }
+}`))
+	assert.NoError(t, err)
+	src, err := GenerateGo(s, GoOptions{})
+	assert.NoError(t, err)
+	code := string(src)
+	assert.Contains(t, code, "type Status int32\n\nconst (\n\tStatus_OK        Status = 0\n\tStatus_NOT_FOUND Status = 404\n)")
+	assert.Contains(t, code, "var StatusEnum = y3.RegisterClosedEnum(\"demo.Status\", map[string]Status{")
+	assert.Contains(t, code, "var Response_LevelEnum = y3.RegisterEnum(\"demo.Response.Level\", map[string]Response_Level{\n\t\"INFO\": Response_Level_INFO,\n})")
+	assert.Contains(t, code, "func (x Status) String() string {\n\treturn StatusEnum.String(int32(x))\n}")
+	assert.Contains(t, code, "\tStatus  Status          `y3:\"0x01\"`")
+	assert.Contains(t, code, "\t\ty3.SetEnumValue[Status](p, m.Status)")
+	assert.Contains(t, code, "\t\ty3.SetOptionalValue(p, m.Level, y3.SetEnumValue[Response_Level])")
+	assert.Contains(t, code, "\t\tv, err := y3.ToEnum[Status](&p)")
+	assert.Contains(t, code, "\t\tv, err := y3.DecodePrimitiveSlice(&n, y3.ToEnum[Status])")
+}
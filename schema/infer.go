@@ -0,0 +1,291 @@
+package schema
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yomorun/y3"
+)
+
+// Infer guesses a draft schema from sample packets, every packet is a node
+// packet encoded without schema. A top level message is inferred for every
+// SeqID of the packets, named by the SeqID, e.g. Message3F, and a nested
+// message for every node child, e.g. Node01. Fields are named by their SeqID,
+// e.g. field_01.
+//
+// A field present in all the samples of its message is required, otherwise
+// it is optional, and a slice packet is a repeated field. The types of
+// primitives are guessed from the values: bool for 0x00 and 0x01, string for
+// printable UTF-8 text, float64 for VarFloat64 of moderate magnitude, int32 or
+// int64 for the values encoded as the shortest NVarInt, bytes otherwise. The
+// guesses can be wrong, e.g. an integer may look like a float or a string, so
+// review the result before using it.
+func Infer(packets [][]byte) (*Schema, error) {
+	roots := map[byte]*sample{}
+	for i, buf := range packets {
+		v, err := y3.Parse(buf)
+		if err != nil {
+			return nil, fmt.Errorf("schema: packet %d: %v", i, err)
+		}
+		n, ok := v.(*y3.NodeValue)
+		if !ok {
+			return nil, fmt.Errorf("schema: packet %d is not a node packet", i)
+		}
+		root, ok := roots[n.SeqID()]
+		if !ok {
+			root = newSample(fmt.Sprintf("Message%02X", n.SeqID()), nil)
+			roots[n.SeqID()] = root
+		}
+		if err := root.observe(n.Children()); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Schema{}
+	for _, sid := range sortedKeys(roots) {
+		m := roots[sid].message(nil)
+		m.SeqID = sid
+		m.HasSeqID = true
+		s.Messages = append(s.Messages, m)
+	}
+	return s, nil
+}
+
+type sampleKind int
+
+const (
+	samplePrimitive sampleKind = iota
+	sampleNode
+	sampleSlice
+)
+
+func (k sampleKind) String() string {
+	switch k {
+	case sampleNode:
+		return "node"
+	case sampleSlice:
+		return "slice"
+	}
+	return "primitive"
+}
+
+// sample collects the observed children of a message
+type sample struct {
+	name string
+	// path is the dotted name used in errors
+	path string
+	// seen is the number of observed nodes
+	seen   int
+	fields map[byte]*sampleField
+}
+
+// sampleField collects the observed values of a field
+type sampleField struct {
+	kind sampleKind
+	// present is the number of observed non-null values
+	present int
+	// elemKind is the kind of the elements of a slice
+	elemKind  sampleKind
+	hasElem   bool
+	primitive [][]byte
+	node      *sample
+}
+
+func newSample(name string, parent *sample) *sample {
+	s := &sample{name: name, path: name, fields: map[byte]*sampleField{}}
+	if parent != nil {
+		s.path = parent.path + "." + name
+	}
+	return s
+}
+
+func (s *sample) observe(children []y3.Value) error {
+	s.seen++
+	present := map[byte]bool{}
+	for _, child := range children {
+		sid := child.SeqID()
+		kind := kindOfValue(child)
+		f, ok := s.fields[sid]
+		if !ok {
+			f = &sampleField{kind: kind}
+			s.fields[sid] = f
+		}
+		if p, ok := child.(*y3.PrimitiveValue); ok && p.IsNull() {
+			continue
+		}
+		if f.present == 0 {
+			// the previous values are all null
+			f.kind = kind
+		} else if f.kind != kind {
+			return fmt.Errorf("schema: SeqID %#x of %s is both %v and %v", sid, s.path, f.kind, kind)
+		}
+		if !present[sid] {
+			present[sid] = true
+			f.present++
+		}
+
+		var err error
+		switch v := child.(type) {
+		case *y3.PrimitiveValue:
+			f.primitive = append(f.primitive, v.Bytes())
+		case *y3.NodeValue:
+			err = f.observeNode(s, sid, v.Children())
+		case *y3.SliceValue:
+			for _, elem := range v.Elems() {
+				kind := kindOfValue(elem)
+				if f.hasElem && f.elemKind != kind {
+					return fmt.Errorf("schema: elements of SeqID %#x of %s are both %v and %v", sid, s.path, f.elemKind, kind)
+				}
+				f.elemKind, f.hasElem = kind, true
+				switch e := elem.(type) {
+				case *y3.PrimitiveValue:
+					if !e.IsNull() {
+						f.primitive = append(f.primitive, e.Bytes())
+					}
+				case *y3.NodeValue:
+					err = f.observeNode(s, sid, e.Children())
+				default:
+					err = fmt.Errorf("schema: SeqID %#x of %s is a slice of slices", sid, s.path)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *sampleField) observeNode(parent *sample, sid byte, children []y3.Value) error {
+	if f.node == nil {
+		f.node = newSample(fmt.Sprintf("Node%02X", sid), parent)
+	}
+	return f.node.observe(children)
+}
+
+// message builds the inferred message
+func (s *sample) message(parent *Message) *Message {
+	m := &Message{Name: s.name, Parent: parent}
+	for _, sid := range sortedKeys(s.fields) {
+		sf := s.fields[sid]
+		f := &Field{Name: fmt.Sprintf("field_%02x", sid), SeqID: sid}
+		switch {
+		case sf.kind == sampleSlice:
+			f.Label = Repeated
+		case sf.present < s.seen:
+			f.Label = Optional
+		}
+		if sf.node != nil {
+			f.Message = sf.node.message(m)
+			f.Type = f.Message.Name
+			m.Messages = append(m.Messages, f.Message)
+		} else {
+			f.Type = guessType(sf.primitive)
+		}
+		m.Fields = append(m.Fields, f)
+	}
+	return m
+}
+
+func kindOfValue(v y3.Value) sampleKind {
+	switch v.(type) {
+	case *y3.NodeValue:
+		return sampleNode
+	case *y3.SliceValue:
+		return sampleSlice
+	}
+	return samplePrimitive
+}
+
+// guessType returns the most plausible scalar type of the values
+func guessType(values [][]byte) string {
+	var nonEmpty [][]byte
+	for _, v := range values {
+		if len(v) > 0 {
+			nonEmpty = append(nonEmpty, v)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return "bytes"
+	}
+	if all(nonEmpty, func(v []byte) bool { return len(v) == 1 && v[0] <= 0x01 }) {
+		return "bool"
+	}
+	if all(nonEmpty, isText) && (!all(nonEmpty, func(v []byte) bool { return len(v) == 1 }) || all(nonEmpty, isLetterByte)) {
+		return "string"
+	}
+	if all(nonEmpty, isFloat64) {
+		return "float64"
+	}
+	if all(nonEmpty, isShortestNVarInt) {
+		if all(nonEmpty, func(v []byte) bool { return len(v) <= 4 }) {
+			return "int32"
+		}
+		return "int64"
+	}
+	return "bytes"
+}
+
+func all(values [][]byte, fn func([]byte) bool) bool {
+	for _, v := range values {
+		if !fn(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func isText(v []byte) bool {
+	if !utf8.Valid(v) {
+		return false
+	}
+	for _, r := range string(v) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetterByte(v []byte) bool {
+	return len(v) == 1 && unicode.IsLetter(rune(v[0]))
+}
+
+// isFloat64 returns true if v looks like a VarFloat64 between 2^-20 and 2^40,
+// or zero
+func isFloat64(v []byte) bool {
+	if len(v) == 1 {
+		return v[0] == 0x00
+	}
+	if len(v) > 8 {
+		return false
+	}
+	exp := int(binary.BigEndian.Uint16(v)>>4&0x7FF) - 1023
+	return exp >= -20 && exp < 40
+}
+
+// isShortestNVarInt returns true if v is an NVarInt without redundant sign bytes
+func isShortestNVarInt(v []byte) bool {
+	if len(v) > 8 {
+		return false
+	}
+	if len(v) == 1 {
+		return true
+	}
+	return !(v[0] == 0x00 && v[1]&0x80 == 0) && !(v[0] == 0xFF && v[1]&0x80 != 0)
+}
+
+func sortedKeys[V any](m map[byte]V) []byte {
+	keys := make([]byte, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,113 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/y3"
+)
+
+type testRoute struct {
+	Name string `y3:"0x01"`
+	Hops int32  `y3:"0x02"`
+}
+
+type testFrame struct {
+	ID     string      `y3:"0x01"`
+	At     int64       `y3:"0x02"`
+	OK     bool        `y3:"0x03"`
+	Temp   float64     `y3:"0x04"`
+	Tags   []string    `y3:"0x05"`
+	Routes []testRoute `y3:"0x06"`
+	Note   *string     `y3:"0x07"`
+	Data   []byte      `y3:"0x08"`
+	Meta   *testRoute  `y3:"0x09"`
+}
+
+func TestInfer(t *testing.T) {
+	note := "n"
+	var packets [][]byte
+	for _, f := range []testFrame{
+		{ID: "a1", At: 1 << 40, OK: true, Temp: 36.6, Tags: []string{"x"}, Routes: []testRoute{{"r", 300}}, Data: []byte{0x00, 0x00, 0xFF}},
+		{ID: "b2", At: 5, Temp: 1e-3, Note: &note, Meta: &testRoute{"m", 2}},
+	} {
+		buf, err := y3.Marshal(0x3F, &f)
+		assert.NoError(t, err)
+		packets = append(packets, buf)
+	}
+	packets = append(packets, []byte{0x81, 0x00})
+
+	s, err := Infer(packets)
+	assert.NoError(t, err)
+	s.Package = "draft"
+	assert.Equal(t, `package draft;
+
+message Message01 = 0x01 {
+}
+
+message Message3F = 0x3F {
+    string field_01 = 0x01;
+    int64 field_02 = 0x02;
+    bool field_03 = 0x03;
+    float64 field_04 = 0x04;
+    repeated string field_05 = 0x05;
+    repeated Node06 field_06 = 0x06;
+    optional string field_07 = 0x07;
+    bytes field_08 = 0x08;
+    optional Node09 field_09 = 0x09;
+
+    message Node06 {
+        string field_01 = 0x01;
+        int32 field_02 = 0x02;
+    }
+
+    message Node09 {
+        string field_01 = 0x01;
+        int32 field_02 = 0x02;
+    }
+}
+`, string(Format(s)))
+
+	// the draft can be compiled
+	_, err = GenerateGo(s, GoOptions{})
+	assert.NoError(t, err)
+}
+
+func TestInferErrors(t *testing.T) {
+	_, err := Infer([][]byte{{0x01, 0x01, 0x01}})
+	assert.EqualError(t, err, "schema: packet 0 is not a node packet")
+
+	_, err = Infer([][]byte{{0x81, 0x05, 0x01}})
+	assert.Error(t, err)
+
+	// 0x01 is a primitive and then a node
+	_, err = Infer([][]byte{{0x81, 0x03, 0x01, 0x01, 0x01}, {0x81, 0x02, 0x81, 0x00}})
+	assert.EqualError(t, err, "schema: SeqID 0x1 of Message01 is both primitive and node")
+
+	// null before the node is fine
+	s, err := Infer([][]byte{{0x81, 0x02, 0x41, 0x00}, {0x81, 0x02, 0x81, 0x00}})
+	assert.NoError(t, err)
+	assert.Equal(t, Optional, s.Messages[0].Fields[0].Label)
+	assert.Equal(t, "Node01", s.Messages[0].Fields[0].Type)
+}
+
+func TestGuessType(t *testing.T) {
+	tests := []struct {
+		values [][]byte
+		typ    string
+	}{
+		{nil, "bytes"},
+		{[][]byte{{}}, "bytes"},
+		{[][]byte{{0x00}, {0x01}}, "bool"},
+		{[][]byte{[]byte("yomo"), []byte("y")}, "string"},
+		{[][]byte{[]byte("y")}, "string"},
+		{[][]byte{{0x40, 0x42, 0x40}, {0x00}}, "float64"},
+		{[][]byte{{0x7F}, {0x01, 0x2C}}, "int32"},
+		{[][]byte{{0xFF, 0x7F}, {0x01, 0x00, 0x00, 0x00, 0x00, 0x00}}, "int64"},
+		{[][]byte{{0x00, 0x01}}, "bytes"},
+		{[][]byte{{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}}, "bytes"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.typ, guessType(tt.values), "%x", tt.values)
+	}
+}
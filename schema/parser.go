@@ -0,0 +1,532 @@
+package schema
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParseFile reads and parses a .y3 schema file
+func ParseFile(path string) (*Schema, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses the schema source, all the type names are resolved and the
+// SeqIDs are validated
+func Parse(src []byte) (*Schema, error) {
+	p := &parser{lex: &lexer{src: src, line: 1, col: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	s, err := p.parseSchema()
+	if err != nil {
+		return nil, err
+	}
+	if err := resolve(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  Pos
+}
+
+type lexer struct {
+	src  []byte
+	off  int
+	line int
+	col  int
+}
+
+func (l *lexer) peek() byte {
+	if l.off < len(l.src) {
+		return l.src[l.off]
+	}
+	return 0
+}
+
+func (l *lexer) read() byte {
+	c := l.src[l.off]
+	l.off++
+	if c == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return c
+}
+
+func (l *lexer) next() (token, error) {
+	// skip spaces and comments
+	for l.off < len(l.src) {
+		c := l.peek()
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			l.read()
+			continue
+		}
+		if c == '/' && l.off+1 < len(l.src) && l.src[l.off+1] == '/' {
+			for l.off < len(l.src) && l.peek() != '\n' {
+				l.read()
+			}
+			continue
+		}
+		break
+	}
+
+	pos := Pos{Line: l.line, Col: l.col}
+	if l.off >= len(l.src) {
+		return token{kind: tokEOF, pos: pos}, nil
+	}
+
+	start := l.off
+	c := l.peek()
+	switch {
+	case isLetter(c):
+		for l.off < len(l.src) && (isLetter(l.peek()) || isDigit(l.peek())) {
+			l.read()
+		}
+		return token{kind: tokIdent, text: string(l.src[start:l.off]), pos: pos}, nil
+	case isDigit(c):
+		for l.off < len(l.src) && (isLetter(l.peek()) || isDigit(l.peek())) {
+			l.read()
+		}
+		return token{kind: tokNumber, text: string(l.src[start:l.off]), pos: pos}, nil
+	case strings.IndexByte("{};=.-[],", c) >= 0:
+		l.read()
+		return token{kind: tokPunct, text: string(c), pos: pos}, nil
+	}
+	return token{}, fmt.Errorf("%v: unexpected character %q", pos, c)
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%v: %s", p.tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) describe() string {
+	if p.tok.kind == tokEOF {
+		return "EOF"
+	}
+	return strconv.Quote(p.tok.text)
+}
+
+func (p *parser) expect(text string) error {
+	if p.tok.kind == tokEOF || p.tok.text != text {
+		return p.errorf("expected %q, found %s", text, p.describe())
+	}
+	return p.advance()
+}
+
+func (p *parser) ident() (string, error) {
+	if p.tok.kind != tokIdent {
+		return "", p.errorf("expected identifier, found %s", p.describe())
+	}
+	name := p.tok.text
+	return name, p.advance()
+}
+
+func (p *parser) seqID() (byte, error) {
+	if p.tok.kind != tokNumber {
+		return 0, p.errorf("expected SeqID, found %s", p.describe())
+	}
+	v, err := strconv.ParseUint(p.tok.text, 0, 8)
+	if err != nil || v > 0x3F {
+		return 0, p.errorf("SeqID %s should be in [0..0x3F]", p.tok.text)
+	}
+	return byte(v), p.advance()
+}
+
+func (p *parser) parseSchema() (*Schema, error) {
+	s := &Schema{}
+	if p.tok.kind == tokIdent && p.tok.text == "package" {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		s.Package = name
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+	}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.isEnum():
+			e, err := p.parseEnum(nil)
+			if err != nil {
+				return nil, err
+			}
+			s.Enums = append(s.Enums, e)
+		case p.tok.kind == tokIdent && p.tok.text == "message":
+			m, err := p.parseMessage(nil)
+			if err != nil {
+				return nil, err
+			}
+			s.Messages = append(s.Messages, m)
+		default:
+			return nil, p.errorf("expected \"message\" or \"enum\", found %s", p.describe())
+		}
+	}
+	return s, nil
+}
+
+func (p *parser) isEnum() bool {
+	return p.tok.kind == tokIdent && (p.tok.text == "enum" || p.tok.text == "closed")
+}
+
+// enum := [ "closed" ] "enum" ident "{" { ident "=" [ "-" ] number ";" } "}"
+func (p *parser) parseEnum(parent *Message) (*Enum, error) {
+	e := &Enum{Parent: parent, Pos: p.tok.pos}
+	if p.tok.text == "closed" {
+		e.Closed = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("enum"); err != nil {
+		return nil, err
+	}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	e.Name = name
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for p.tok.text != "}" {
+		if p.tok.kind == tokEOF {
+			return nil, p.errorf("expected \"}\", found EOF")
+		}
+		v := &EnumValue{Pos: p.tok.pos}
+		if v.Name, err = p.ident(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		if v.Value, err = p.int32(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		e.Values = append(e.Values, v)
+	}
+	return e, p.advance()
+}
+
+func (p *parser) int32() (int32, error) {
+	sign := ""
+	if p.tok.text == "-" {
+		sign = "-"
+		if err := p.advance(); err != nil {
+			return 0, err
+		}
+	}
+	if p.tok.kind != tokNumber {
+		return 0, p.errorf("expected number, found %s", p.describe())
+	}
+	v, err := strconv.ParseInt(sign+p.tok.text, 0, 32)
+	if err != nil {
+		return 0, p.errorf("invalid int32 %s%s", sign, p.tok.text)
+	}
+	return int32(v), p.advance()
+}
+
+// message := "message" ident [ "=" SeqID ] "{" { field | message | enum } "}"
+func (p *parser) parseMessage(parent *Message) (*Message, error) {
+	m := &Message{Parent: parent, Pos: p.tok.pos}
+	if err := p.expect("message"); err != nil {
+		return nil, err
+	}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	m.Name = name
+	if p.tok.text == "=" {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if m.SeqID, err = p.seqID(); err != nil {
+			return nil, err
+		}
+		m.HasSeqID = true
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for p.tok.text != "}" {
+		if p.tok.kind == tokEOF {
+			return nil, p.errorf("expected \"}\", found EOF")
+		}
+		if p.tok.kind == tokIdent && p.tok.text == "message" {
+			nested, err := p.parseMessage(m)
+			if err != nil {
+				return nil, err
+			}
+			m.Messages = append(m.Messages, nested)
+			continue
+		}
+		if p.isEnum() {
+			e, err := p.parseEnum(m)
+			if err != nil {
+				return nil, err
+			}
+			m.Enums = append(m.Enums, e)
+			continue
+		}
+		f, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		m.Fields = append(m.Fields, f)
+	}
+	return m, p.advance()
+}
+
+// field := [ "optional" | "repeated" ] type ident "=" SeqID [ options ] ";"
+// options := "[" ident { "," ident } "]"
+func (p *parser) parseField() (*Field, error) {
+	f := &Field{Pos: p.tok.pos}
+	switch p.tok.text {
+	case "optional":
+		f.Label = Optional
+	case "repeated":
+		f.Label = Repeated
+	}
+	if f.Label != Required {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	typ, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.text == "." {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		typ += "." + name
+	}
+	f.Type = typ
+
+	if f.Name, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	if f.SeqID, err = p.seqID(); err != nil {
+		return nil, err
+	}
+	if p.tok.text == "[" {
+		if err := p.parseFieldOptions(f); err != nil {
+			return nil, err
+		}
+	}
+	return f, p.expect(";")
+}
+
+func (p *parser) parseFieldOptions(f *Field) error {
+	for p.tok.text == "[" || p.tok.text == "," {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		pos := p.tok.pos
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if name != "redact" {
+			return fmt.Errorf("%v: unknown option %s of field %s", pos, name, f.Name)
+		}
+		f.Redact = true
+	}
+	return p.expect("]")
+}
+
+// resolve checks duplicated names and SeqIDs, and links the message and enum
+// types of fields
+func resolve(s *Schema) error {
+	if err := checkNames(s.Messages, s.Enums); err != nil {
+		return err
+	}
+	var err error
+	s.WalkEnums(func(e *Enum) {
+		if err == nil {
+			err = checkEnum(e)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	s.Walk(func(m *Message) {
+		if err != nil {
+			return
+		}
+		if err = checkNames(m.Messages, m.Enums); err != nil {
+			return
+		}
+		names := map[string]bool{}
+		sids := map[byte]string{}
+		for _, f := range m.Fields {
+			if names[f.Name] {
+				err = fmt.Errorf("%v: duplicated field %s in message %s", f.Pos, f.Name, m.FullName())
+				return
+			}
+			names[f.Name] = true
+			if name, ok := sids[f.SeqID]; ok {
+				err = fmt.Errorf("%v: field %s reuses SeqID %#x of field %s in message %s", f.Pos, f.Name, f.SeqID, name, m.FullName())
+				return
+			}
+			sids[f.SeqID] = f.Name
+			if IsScalar(f.Type) {
+				continue
+			}
+			if f.Message, f.Enum = lookupType(s, m, f.Type); f.Message == nil && f.Enum == nil {
+				err = fmt.Errorf("%v: unknown type %s of field %s", f.Pos, f.Type, f.Name)
+				return
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return checkRequiredCycles(s)
+}
+
+// checkRequiredCycles rejects the required message fields which lead back to
+// their message, such a message can't be encoded in finite bytes
+func checkRequiredCycles(s *Schema) error {
+	// done are the messages checked, visiting are the ones on the path
+	done := map[*Message]bool{}
+	visiting := map[*Message]bool{}
+	var visit func(m *Message) error
+	visit = func(m *Message) error {
+		if done[m] {
+			return nil
+		}
+		visiting[m] = true
+		for _, f := range m.Fields {
+			if f.Message == nil || f.Label != Required {
+				continue
+			}
+			if visiting[f.Message] {
+				return fmt.Errorf("%v: required field %s of message %s makes a cycle, declare it optional", f.Pos, f.Name, m.FullName())
+			}
+			if err := visit(f.Message); err != nil {
+				return err
+			}
+		}
+		visiting[m] = false
+		done[m] = true
+		return nil
+	}
+	var err error
+	s.Walk(func(m *Message) {
+		if err == nil {
+			err = visit(m)
+		}
+	})
+	return err
+}
+
+func checkNames(msgs []*Message, enums []*Enum) error {
+	names := map[string]bool{}
+	for _, m := range msgs {
+		if names[m.Name] {
+			return fmt.Errorf("%v: duplicated message %s", m.Pos, m.FullName())
+		}
+		names[m.Name] = true
+	}
+	for _, e := range enums {
+		if names[e.Name] {
+			return fmt.Errorf("%v: duplicated enum %s", e.Pos, e.FullName())
+		}
+		names[e.Name] = true
+	}
+	return nil
+}
+
+func checkEnum(e *Enum) error {
+	names := map[string]bool{}
+	values := map[int32]string{}
+	for _, v := range e.Values {
+		if names[v.Name] {
+			return fmt.Errorf("%v: duplicated name %s in enum %s", v.Pos, v.Name, e.FullName())
+		}
+		names[v.Name] = true
+		if name, ok := values[v.Value]; ok {
+			return fmt.Errorf("%v: %s reuses value %d of %s in enum %s", v.Pos, v.Name, v.Value, name, e.FullName())
+		}
+		values[v.Value] = v.Name
+	}
+	return nil
+}
+
+// lookupType finds the message or enum by name from the scope of m to the top level
+func lookupType(s *Schema, m *Message, name string) (*Message, *Enum) {
+	for scope := m; scope != nil; scope = scope.Parent {
+		fullName := scope.FullName() + "." + name
+		if found := s.Lookup(fullName); found != nil {
+			return found, nil
+		}
+		if found := s.LookupEnum(fullName); found != nil {
+			return nil, found
+		}
+	}
+	return s.Lookup(name), s.LookupEnum(name)
+}
@@ -0,0 +1,133 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSchema = `
+// frames
+package demo;
+
+message DataFrame = 0x3F {
+    MetaFrame meta = 0x2F;
+    optional Payload payload = 0x2E;
+
+    message Payload {
+        bytes carriage = 0x01;
+    }
+}
+
+message MetaFrame {
+    string transaction_id = 0x01; // trailing comment
+    optional int64 timestamp = 2;
+    repeated string tags = 0x03 [redact];
+    repeated DataFrame.Payload history = 0x04;
+}
+`
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(testSchema))
+	assert.NoError(t, err)
+	assert.Equal(t, "demo", s.Package)
+	assert.Len(t, s.Messages, 2)
+
+	data := s.Messages[0]
+	assert.Equal(t, "DataFrame", data.Name)
+	assert.True(t, data.HasSeqID)
+	assert.EqualValues(t, 0x3F, data.SeqID)
+	assert.Equal(t, Pos{Line: 5, Col: 1}, data.Pos)
+
+	meta := s.Lookup("MetaFrame")
+	assert.Equal(t, meta, data.Field(0x2F).Message)
+	assert.False(t, meta.HasSeqID)
+
+	payload := s.Lookup("DataFrame.Payload")
+	assert.NotNil(t, payload)
+	assert.Equal(t, data, payload.Parent)
+	assert.Equal(t, payload, data.Field(0x2E).Message)
+	assert.Equal(t, Optional, data.Field(0x2E).Label)
+
+	assert.Len(t, meta.Fields, 4)
+	assert.Equal(t, &Field{Name: "timestamp", SeqID: 0x02, Label: Optional, Type: "int64", Pos: Pos{Line: 16, Col: 5}}, meta.Fields[1])
+	assert.Equal(t, Repeated, meta.Field(0x03).Label)
+	assert.True(t, meta.Field(0x03).Redact)
+	assert.Equal(t, payload, meta.Field(0x04).Message)
+	assert.Nil(t, meta.Field(0x05))
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{`message A { int32 a = 0x40; }`, "1:23: SeqID 0x40 should be in [0..0x3F]"},
+		{`message A { int32 a = 0x01 }`, "1:28: expected \";\", found \"}\""},
+		{`message A { int32 a = 0x01;`, "1:28: expected \"}\", found EOF"},
+		{`message A { B b = 0x01; }`, "1:13: unknown type B of field b"},
+		{`message A { int32 a = 0x01; string b = 1; }`, "1:29: field b reuses SeqID 0x1 of field a in message A"},
+		{`message A { int32 a = 0x01; string a = 2; }`, "1:29: duplicated field a in message A"},
+		{`message A {} message A {}`, "1:14: duplicated message A"},
+		{`service A {}`, "1:1: expected \"message\" or \"enum\", found \"service\""},
+		{`enum A { X = 1; Y = 1; }`, "1:17: Y reuses value 1 of X in enum A"},
+		{`enum A { X = 1; X = 2; }`, "1:17: duplicated name X in enum A"},
+		{`enum A { X = 0x80000000; }`, "1:14: invalid int32 0x80000000"},
+		{`enum A { X = -; }`, "1:15: expected number, found \";\""},
+		{`message A {} enum A {}`, "1:14: duplicated enum A"},
+		{`message A { int32 a = 0x01; } #`, "1:31: unexpected character '#'"},
+		{`message A { int32 a = 0x01 [secret]; }`, "1:29: unknown option secret of field a"},
+		{`message A { int32 a = 0x01 [redact; }`, "1:35: expected \"]\", found \";\""},
+		{`message Node { string name = 0x01; Node next = 0x02; }`, "1:36: required field next of message Node makes a cycle, declare it optional"},
+		{`message A { B b = 0x01; } message B { A a = 0x01; }`, "1:39: required field a of message B makes a cycle, declare it optional"},
+		{`message A { int32 a = 0x01 []; }`, "1:29: expected identifier, found \"]\""},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.src))
+		assert.EqualError(t, err, tt.err, tt.src)
+	}
+}
+
+func TestLabel(t *testing.T) {
+	assert.Equal(t, "required", Required.String())
+	assert.Equal(t, "optional", Optional.String())
+	assert.Equal(t, "repeated", Repeated.String())
+}
+
+func TestParseEnum(t *testing.T) {
+	s, err := Parse([]byte(`
+closed enum Status {
+    OK = 0;
+    ERROR = -1;
+    NOT_FOUND = 0x194;
+}
+
+message Response {
+    Status status = 0x01;
+    optional Level level = 0x02;
+    repeated Status history = 0x03;
+
+    enum Level {
+        INFO = 1;
+    }
+}
+`))
+	assert.NoError(t, err)
+	status := s.LookupEnum("Status")
+	assert.True(t, status.Closed)
+	assert.Equal(t, []*EnumValue{
+		{Name: "OK", Value: 0, Pos: Pos{Line: 3, Col: 5}},
+		{Name: "ERROR", Value: -1, Pos: Pos{Line: 4, Col: 5}},
+		{Name: "NOT_FOUND", Value: 404, Pos: Pos{Line: 5, Col: 5}},
+	}, status.Values)
+
+	resp := s.Lookup("Response")
+	level := s.LookupEnum("Response.Level")
+	assert.False(t, level.Closed)
+	assert.Equal(t, resp, level.Parent)
+	assert.Equal(t, status, resp.Field(0x01).Enum)
+	assert.Nil(t, resp.Field(0x01).Message)
+	assert.Equal(t, level, resp.Field(0x02).Enum)
+	assert.Equal(t, status, resp.Field(0x03).Enum)
+	assert.Nil(t, s.LookupEnum("Level"))
+}
@@ -0,0 +1,51 @@
+package schema
+
+import "github.com/yomorun/y3"
+
+// NewRedactor returns a y3.Redactor replacing the values of the fields declared
+// with the redact option, including all the packets in them, of the root
+// packets described by the top level messages of s. The fields of string and
+// bytes are masked, and the others are replaced by null. Set Typed of the
+// result if the primitives are typed.
+func NewRedactor(s *Schema) *y3.Redactor {
+	return &y3.Redactor{Match: s.redacted, Kind: s.kindAt}
+}
+
+// redacted returns true if the packet at path is a field declared with the
+// redact option, the elements of a repeated field are selected by the field
+func (s *Schema) redacted(root byte, path y3.Path) bool {
+	f, elem := s.fieldAtPath(root, path)
+	return f != nil && !elem && f.Redact
+}
+
+// kindAt returns the type of the primitive at path, KindUnknown if it's not
+// described
+func (s *Schema) kindAt(root byte, path y3.Path) y3.Kind {
+	f, elem := s.fieldAtPath(root, path)
+	if f == nil || f.Message != nil || f.Label == Repeated && !elem {
+		return y3.KindUnknown
+	}
+	if f.Enum != nil {
+		return y3.KindInt32
+	}
+	for k := y3.KindInt32; k.IsValid(); k++ {
+		if k.String() == f.Type {
+			return k
+		}
+	}
+	return y3.KindUnknown
+}
+
+// fieldAtPath returns the field of the packet at path from the root message
+// with SeqID root, see Message.fieldAt
+func (s *Schema) fieldAtPath(root byte, path y3.Path) (f *Field, elem bool) {
+	m := s.rootMessage(root)
+	if m == nil || len(path) == 0 {
+		return nil, false
+	}
+	sids := make([]byte, len(path))
+	for i, step := range path {
+		sids[i] = step.SeqID
+	}
+	return m.fieldAt(sids)
+}
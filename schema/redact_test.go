@@ -0,0 +1,48 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/y3"
+)
+
+func TestNewRedactor(t *testing.T) {
+	s := mustParse(t, `
+message Login = 0x01 {
+    string user = 0x01;
+    string password = 0x02 [redact];
+    repeated string tokens = 0x03 [redact];
+    int32 pin = 0x07 [redact];
+    optional Profile profile = 0x04;
+    repeated Profile friends = 0x05;
+}
+message Profile {
+    string name = 0x01;
+    Contact contact = 0x02 [redact];
+}
+message Contact {
+    string phone = 0x01;
+    string email = 0x02;
+}
+`)
+	buf, err := y3.ParseText(`0x01 { 0x01: "alice" 0x02: "secret" 0x03 ["a" "b"] 0x04 { 0x01: "Alice" 0x02 { 0x01: "138" 0x02: null } }
+		0x05 [ { 0x01: "Bob" 0x02 { 0x01: "139" } } ] 0x06: "unknown" 0x07: 1234 }`)
+	assert.NoError(t, err)
+	res, err := NewRedactor(s).Redact(buf)
+	assert.NoError(t, err)
+	text, err := y3.FormatText(res)
+	assert.NoError(t, err)
+	want, err := y3.ParseText(`0x01 { 0x01: "alice" 0x02: "[REDACTED]" 0x03 ["[REDACTED]" "[REDACTED]"]
+		0x04 { 0x01: "Alice" 0x02 { 0x01: "[REDACTED]" 0x02: null } }
+		0x05 [ { 0x01: "Bob" 0x02 { 0x01: "[REDACTED]" } } ] 0x06: "unknown" 0x07: null }`)
+	assert.NoError(t, err)
+	assert.Equal(t, want, res, text)
+
+	// the root packet is not described
+	buf, err = y3.ParseText(`0x02 { 0x02: "secret" }`)
+	assert.NoError(t, err)
+	res, err = NewRedactor(s).Redact(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, buf, res)
+}
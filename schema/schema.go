@@ -0,0 +1,213 @@
+// Package schema implements the Y3 schema language, which describes the
+// messages, their fields with SeqIDs and value types, e.g.
+//
+//	package demo;
+//
+//	message DataFrame = 0x3F {
+//	    MetaFrame meta = 0x2F;
+//	    bytes payload = 0x2E;
+//	}
+//
+//	message MetaFrame {
+//	    string transaction_id = 0x01;
+//	    optional int64 timestamp = 0x02;
+//	    repeated string tags = 0x03;
+//	    Status status = 0x04;
+//	    string token = 0x05 [redact];
+//	}
+//
+//	closed enum Status {
+//	    OK = 0;
+//	    NOT_FOUND = 404;
+//	}
+//
+// A field is required unless it is declared as optional or repeated. Messages
+// and enums can be nested, a nested type is referenced by its name inside the
+// parent, or by the dotted full name elsewhere. An enum is encoded as int32, a
+// closed enum rejects unknown values when decoding while an open one keeps them.
+// The fields of sensitive data are declared with the redact option, see
+// NewRedactor.
+package schema
+
+import "fmt"
+
+// Pos describes a position in the schema source
+type Pos struct {
+	Line int
+	Col  int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// Label describes the cardinality of a field
+type Label int
+
+const (
+	// Required field must be present
+	Required Label = iota
+	// Optional field may be absent or null
+	Optional
+	// Repeated field is a slice
+	Repeated
+)
+
+func (l Label) String() string {
+	switch l {
+	case Optional:
+		return "optional"
+	case Repeated:
+		return "repeated"
+	}
+	return "required"
+}
+
+// Scalars are the names of primitive types
+var Scalars = []string{
+	"int32", "int64", "uint32", "uint64",
+	"float32", "float64", "bool", "string", "bytes", "time",
+}
+
+// IsScalar returns true if the type name is a primitive type
+func IsScalar(typ string) bool {
+	for _, s := range Scalars {
+		if s == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Schema is a parsed .y3 file
+type Schema struct {
+	// Package is the name declared by the package statement
+	Package string
+	// Messages are the top level messages
+	Messages []*Message
+	// Enums are the top level enums
+	Enums []*Enum
+}
+
+// Message describes a node packet
+type Message struct {
+	Name string
+	// SeqID is used when the message is encoded as a root packet
+	SeqID byte
+	// HasSeqID describes if SeqID is declared
+	HasSeqID bool
+	Fields   []*Field
+	// Messages are the nested messages
+	Messages []*Message
+	// Enums are the nested enums
+	Enums []*Enum
+	// Parent is the message this one nested in, nil for top level messages
+	Parent *Message
+	Pos    Pos
+}
+
+// FullName returns the dotted name from the top level message
+func (m *Message) FullName() string {
+	if m.Parent == nil {
+		return m.Name
+	}
+	return m.Parent.FullName() + "." + m.Name
+}
+
+// Field returns the field with SeqID, nil if not exists
+func (m *Message) Field(sid byte) *Field {
+	for _, f := range m.Fields {
+		if f.SeqID == sid {
+			return f
+		}
+	}
+	return nil
+}
+
+// Field describes a child of message
+type Field struct {
+	Name  string
+	SeqID byte
+	Label Label
+	// Type is the type name as declared
+	Type string
+	// Message is the resolved message type, nil for scalar and enum types
+	Message *Message
+	// Enum is the resolved enum type, nil for scalar and message types
+	Enum *Enum
+	// Redact is declared by the redact option, see NewRedactor
+	Redact bool
+	Pos    Pos
+}
+
+// Enum describes the symbolic names of an int32 field
+type Enum struct {
+	Name string
+	// Closed enum rejects the unknown values when decoding
+	Closed bool
+	Values []*EnumValue
+	// Parent is the message this one nested in, nil for top level enums
+	Parent *Message
+	Pos    Pos
+}
+
+// EnumValue is a symbolic name of enum
+type EnumValue struct {
+	Name  string
+	Value int32
+	Pos   Pos
+}
+
+// FullName returns the dotted name from the top level message
+func (e *Enum) FullName() string {
+	if e.Parent == nil {
+		return e.Name
+	}
+	return e.Parent.FullName() + "." + e.Name
+}
+
+// Lookup returns the message by its full name, nil if not exists
+func (s *Schema) Lookup(fullName string) *Message {
+	var found *Message
+	s.Walk(func(m *Message) {
+		if found == nil && m.FullName() == fullName {
+			found = m
+		}
+	})
+	return found
+}
+
+// Walk calls fn with every message, parents before their nested messages
+func (s *Schema) Walk(fn func(*Message)) {
+	var walk func([]*Message)
+	walk = func(msgs []*Message) {
+		for _, m := range msgs {
+			fn(m)
+			walk(m.Messages)
+		}
+	}
+	walk(s.Messages)
+}
+
+// LookupEnum returns the enum by its full name, nil if not exists
+func (s *Schema) LookupEnum(fullName string) *Enum {
+	var found *Enum
+	s.WalkEnums(func(e *Enum) {
+		if found == nil && e.FullName() == fullName {
+			found = e
+		}
+	})
+	return found
+}
+
+// WalkEnums calls fn with every enum, top level enums first
+func (s *Schema) WalkEnums(fn func(*Enum)) {
+	for _, e := range s.Enums {
+		fn(e)
+	}
+	s.Walk(func(m *Message) {
+		for _, e := range m.Enums {
+			fn(e)
+		}
+	})
+}
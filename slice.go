@@ -0,0 +1,44 @@
+package y3
+
+import "errors"
+
+// DecodePrimitiveSlice parse every element of a slice packet as primitive packet by get,
+// e.g. (*PrimitivePacket).ToUTF8String
+func DecodePrimitiveSlice[T any](np *NodePacket, get func(*PrimitivePacket) (T, error)) ([]T, error) {
+	s := []T{}
+	err := eachPacket(np.GetValBuf(), func(isNode bool, _ *NodePacket, p *PrimitivePacket) error {
+		if isNode {
+			return errors.New("y3: slice element should be a primitive packet")
+		}
+		v, err := get(p)
+		if err != nil {
+			return err
+		}
+		s = append(s, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DecodeNodeSlice parse every element of a slice packet as node packet by get
+func DecodeNodeSlice[T any](np *NodePacket, get func(*NodePacket) (T, error)) ([]T, error) {
+	s := []T{}
+	err := eachPacket(np.GetValBuf(), func(isNode bool, n *NodePacket, _ *PrimitivePacket) error {
+		if !isNode {
+			return errors.New("y3: slice element should be a node packet")
+		}
+		v, err := get(n)
+		if err != nil {
+			return err
+		}
+		s = append(s, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,53 @@
+package y3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodePrimitiveSlice(t *testing.T) {
+	buf, err := Marshal(0x01, struct {
+		Tags []string `y3:"0x05"`
+	}{Tags: []string{"a", "b"}})
+	assert.NoError(t, err)
+
+	np := &NodePacket{}
+	_, err = DecodeToNodePacket(buf, np)
+	assert.NoError(t, err)
+	slice := np.NodePackets[0x05]
+	tags, err := DecodePrimitiveSlice(&slice, (*PrimitivePacket).ToUTF8String)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, tags)
+
+	_, err = DecodeNodeSlice(&slice, func(n *NodePacket) (int, error) { return 0, nil })
+	assert.EqualError(t, err, "y3: slice element should be a node packet")
+}
+
+func TestDecodeNodeSlice(t *testing.T) {
+	buf, err := Marshal(0x01, struct {
+		Bars []testBar `y3:"0x05"`
+	}{Bars: []testBar{{Name: "C"}, {Name: "D"}}})
+	assert.NoError(t, err)
+
+	np := &NodePacket{}
+	_, err = DecodeToNodePacket(buf, np)
+	assert.NoError(t, err)
+	slice := np.NodePackets[0x05]
+	bars, err := DecodeNodeSlice(&slice, func(n *NodePacket) (string, error) {
+		p := n.PrimitivePackets[0x04]
+		return p.ToUTF8String()
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"C", "D"}, bars)
+
+	_, err = DecodePrimitiveSlice(&slice, (*PrimitivePacket).ToUTF8String)
+	assert.EqualError(t, err, "y3: slice element should be a primitive packet")
+
+	empty := &NodePacket{}
+	_, err = DecodeToNodePacket([]byte{0xC5, 0x00}, empty)
+	assert.NoError(t, err)
+	s, err := DecodeNodeSlice(empty, func(n *NodePacket) (string, error) { return "", nil })
+	assert.NoError(t, err)
+	assert.Empty(t, s)
+}
@@ -0,0 +1,74 @@
+//go:build go1.21
+
+package y3
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+// LogValue implements slog.LogValuer, a node packet is a group keyed by the
+// SeqIDs of the children like "0x01", a slice packet is a group keyed by the
+// indexes of the elements, a primitive packet is its Val as a string if it's
+// printable, or in hex like h"0102" if not, and null is nil
+func (n NodePacket) LogValue() slog.Value {
+	return logValue(n.rawBytes())
+}
+
+// LogValue implements slog.LogValuer, see NodePacket.LogValue
+func (p PrimitivePacket) LogValue() slog.Value {
+	return logValue(p.rawBytes())
+}
+
+// LogValue implements slog.LogValuer, see NodePacket.LogValue, the encoder is
+// not completed by logging
+func (enc *NodePacketEncoder) LogValue() slog.Value {
+	return logValue(enc.encoder.bytes())
+}
+
+// LogValue implements slog.LogValuer, see NodePacket.LogValue
+func (enc *PrimitivePacketEncoder) LogValue() slog.Value {
+	return logValue(enc.encoder.bytes())
+}
+
+func logValue(raw []byte) slog.Value {
+	p, err := readRawPacket(raw, 0)
+	if err == nil {
+		var v slog.Value
+		if v, err = rawLogValue(p); err == nil {
+			return v
+		}
+	}
+	return slog.AnyValue(fmt.Errorf("y3: %w", err))
+}
+
+func rawLogValue(p *rawPacket) (slog.Value, error) {
+	if !p.tag.IsNode() {
+		switch {
+		case p.isNull():
+			return slog.AnyValue(nil), nil
+		case isPrintableText(p.val):
+			return slog.StringValue(string(p.val)), nil
+		}
+		return slog.StringValue(textValue(p)), nil
+	}
+
+	var attrs []slog.Attr
+	err := eachRawPacket(p.val, p.off+len(p.header), func(child *rawPacket) error {
+		v, err := rawLogValue(child)
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("0x%02X", child.tag.SeqID())
+		if p.tag.IsSlice() {
+			key = strconv.Itoa(len(attrs))
+		}
+		attrs = append(attrs, slog.Attr{Key: key, Value: v})
+		return nil
+	})
+	if err != nil {
+		return slog.Value{}, err
+	}
+	return slog.GroupValue(attrs...), nil
+}
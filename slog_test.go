@@ -0,0 +1,36 @@
+//go:build go1.21
+
+package y3
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogValue(t *testing.T) {
+	buf, err := ParseText(`0x3F { 0x2F { 0x01: "yomo" 0x03: null } 0x05 [1 2] }`)
+	assert.NoError(t, err)
+	var n NodePacket
+	_, err = DecodeToNodePacket(buf, &n)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	logger.Info("recv", "packet", n)
+	assert.Equal(t, `level=INFO msg=recv packet.0x2F.0x01=yomo packet.0x2F.0x03=<nil> packet.0x05.0="h\"01\"" packet.0x05.1="h\"02\""`+"\n", out.String())
+
+	enc := NewPrimitivePacketEncoder(0x01)
+	enc.SetStringValue("a")
+	assert.Equal(t, "a", enc.LogValue().String())
+	assert.Equal(t, slog.KindAny, NodePacket{}.LogValue().Kind())
+}
@@ -0,0 +1,418 @@
+package y3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The text format is a human-readable representation of packets, e.g.
+//
+//	0x3F {
+//	    0x2F {
+//	        0x01: "yomo"
+//	    }
+//	    0x2E: h"0102"
+//	    0x2D [
+//	        "a"
+//	        { 0x01: 1 }
+//	    ]
+//	    0x2C: null
+//	}
+//
+// A node packet is its SeqID followed by the children in braces, a slice
+// packet is followed by the elements in brackets, and a primitive packet is
+// followed by a colon and the value. The SeqID of an element is omitted if
+// it's SliceElementSeqID. The values are:
+//
+//	"yomo"     string, encoded by SetStringValue, with the escapes of Go
+//	h"0102"    raw bytes of Val in hex
+//	-1, 0x10   integer, encoded by SetInt64Value, or SetUInt64Value if it
+//	           overflows int64
+//	1.5        float64, encoded by SetFloat64Value
+//	1.5f       float32, encoded by SetFloat32Value
+//	true       bool, encoded by SetBoolValue
+//	null       explicit null
+//
+// Comments start with # or // and end at the end of line, commas between
+// packets are optional. The printer writes a Val as a string if it's
+// printable UTF-8, as raw bytes otherwise, so printing and parsing keeps the
+// bytes unchanged.
+
+// FormatText returns the text format of the Y3 encoded packets in buf
+func FormatText(buf []byte) (string, error) {
+	return FormatTextWith(buf, nil)
+}
+
+// FormatTextWith is like FormatText, but the primitives are printed by value,
+// e.g. the enums by their symbolic names, so the text may not be parsed by
+// ParseText. value can be nil.
+func FormatTextWith(buf []byte, value ValueFunc) (string, error) {
+	var w bytes.Buffer
+	if err := writeTree(&w, buf, &treeOptions{indent: "    ", value: value}); err != nil {
+		return "", fmt.Errorf("y3: %v", err)
+	}
+	return w.String(), nil
+}
+
+// Text returns the text format of the node packet
+func (n *NodePacket) Text() string {
+	text, err := FormatText(n.rawBytes())
+	if err != nil {
+		return fmt.Sprintf("<invalid: %v>", err)
+	}
+	return text
+}
+
+// Text returns the text format of the primitive packet
+func (p *PrimitivePacket) Text() string {
+	text, _ := FormatText(p.rawBytes())
+	return text
+}
+
+// ParseText parses the packets in text format, and returns the Y3 encoded
+// bytes of them, e.g.
+//
+//	buf, err := y3.ParseText(`0x3F { 0x2F { 0x01: "yomo" } 0x2E: h"0102" }`)
+func ParseText(text string) ([]byte, error) {
+	p := &textParser{lex: &textLexer{src: text, line: 1, col: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var res []byte
+	for p.tok.kind != textEOF {
+		enc, err := p.packet(false)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, enc.Encode()...)
+	}
+	return res, nil
+}
+
+type textTokenKind int
+
+const (
+	textEOF textTokenKind = iota
+	textIdent
+	textNumber
+	textString
+	textHex
+	textPunct
+)
+
+type textToken struct {
+	kind textTokenKind
+	// text is the source of token, or the unquoted value of string and hex
+	text string
+	line int
+	col  int
+}
+
+type textLexer struct {
+	src  string
+	off  int
+	line int
+	col  int
+}
+
+func (l *textLexer) peek() byte {
+	if l.off < len(l.src) {
+		return l.src[l.off]
+	}
+	return 0
+}
+
+func (l *textLexer) read() byte {
+	c := l.src[l.off]
+	l.off++
+	if c == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return c
+}
+
+func (l *textLexer) next() (textToken, error) {
+	// skip spaces and comments
+	for l.off < len(l.src) {
+		c := l.peek()
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == ',' {
+			l.read()
+			continue
+		}
+		if c == '#' || strings.HasPrefix(l.src[l.off:], "//") {
+			for l.off < len(l.src) && l.peek() != '\n' {
+				l.read()
+			}
+			continue
+		}
+		break
+	}
+
+	tok := textToken{line: l.line, col: l.col}
+	if l.off >= len(l.src) {
+		return tok, nil
+	}
+
+	start := l.off
+	c := l.peek()
+	switch {
+	case c == 'h' && strings.HasPrefix(l.src[l.off:], `h"`):
+		l.read()
+		s, err := l.quoted(tok)
+		if err != nil {
+			return tok, err
+		}
+		tok.kind, tok.text = textHex, s
+	case c == '"':
+		s, err := l.quoted(tok)
+		if err != nil {
+			return tok, err
+		}
+		tok.kind, tok.text = textString, s
+	case isTextLetter(c):
+		for l.off < len(l.src) && (isTextLetter(l.peek()) || isTextDigit(l.peek())) {
+			l.read()
+		}
+		tok.kind, tok.text = textIdent, l.src[start:l.off]
+	case isTextDigit(c) || c == '-' || c == '+' || c == '.':
+		l.read()
+		for l.off < len(l.src) {
+			c := l.peek()
+			// the sign of exponent
+			if (c == '-' || c == '+') && strings.ContainsRune("eE", rune(l.src[l.off-1])) && !strings.HasPrefix(l.src[start:], "0x") {
+				l.read()
+				continue
+			}
+			if !isTextLetter(c) && !isTextDigit(c) && c != '.' {
+				break
+			}
+			l.read()
+		}
+		tok.kind, tok.text = textNumber, l.src[start:l.off]
+	case strings.IndexByte("{}[]:", c) >= 0:
+		l.read()
+		tok.kind, tok.text = textPunct, string(c)
+	default:
+		return tok, fmt.Errorf("y3: %d:%d: unexpected character %q", tok.line, tok.col, c)
+	}
+	return tok, nil
+}
+
+// quoted reads the quoted string and returns it unquoted
+func (l *textLexer) quoted(tok textToken) (string, error) {
+	start := l.off
+	l.read()
+	for {
+		if l.off >= len(l.src) || l.peek() == '\n' {
+			return "", fmt.Errorf("y3: %d:%d: string not terminated", tok.line, tok.col)
+		}
+		c := l.read()
+		if c == '\\' && l.off < len(l.src) {
+			l.read()
+			continue
+		}
+		if c == '"' {
+			break
+		}
+	}
+	s, err := strconv.Unquote(l.src[start:l.off])
+	if err != nil {
+		return "", fmt.Errorf("y3: %d:%d: invalid string %s", tok.line, tok.col, l.src[start:l.off])
+	}
+	return s, nil
+}
+
+func isTextLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isTextDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type textParser struct {
+	lex *textLexer
+	tok textToken
+	// next is the token after tok if peeked
+	next *textToken
+}
+
+func (p *textParser) advance() error {
+	if p.next != nil {
+		p.tok, p.next = *p.next, nil
+		return nil
+	}
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// peek returns the token after tok
+func (p *textParser) peek() (textToken, error) {
+	if p.next == nil {
+		tok, err := p.lex.next()
+		if err != nil {
+			return tok, err
+		}
+		p.next = &tok
+	}
+	return *p.next, nil
+}
+
+func (p *textParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("y3: %d:%d: %s", p.tok.line, p.tok.col, fmt.Sprintf(format, args...))
+}
+
+func (p *textParser) describe() string {
+	switch p.tok.kind {
+	case textEOF:
+		return "EOF"
+	case textString:
+		return strconv.Quote(p.tok.text)
+	case textHex:
+		return `h"` + p.tok.text + `"`
+	}
+	return strconv.Quote(p.tok.text)
+}
+
+func (p *textParser) isPunct(text string) bool {
+	return p.tok.kind == textPunct && p.tok.text == text
+}
+
+// packet parses a packet, elem means it's an element of slice, which can
+// omit the SeqID
+func (p *textParser) packet(elem bool) (encoder iEncoder, err error) {
+	sid := SliceElementSeqID
+	hasSeqID := !elem
+	if elem && p.tok.kind == textNumber {
+		next, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		hasSeqID = next.kind == textPunct && strings.Contains("{[:", next.text)
+	}
+	if hasSeqID {
+		if sid, err = p.seqID(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case p.isPunct("{"), p.isPunct("["):
+		return p.node(sid)
+	case hasSeqID:
+		if !p.isPunct(":") {
+			return nil, p.errorf("expected \"{\", \"[\" or \":\", found %s", p.describe())
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return p.primitive(sid)
+}
+
+func (p *textParser) seqID() (byte, error) {
+	if p.tok.kind != textNumber {
+		return 0, p.errorf("expected SeqID, found %s", p.describe())
+	}
+	v, err := strconv.ParseUint(p.tok.text, 0, 8)
+	if err != nil || v > 0x3F {
+		return 0, p.errorf("SeqID %s should be in [0..0x3F]", p.tok.text)
+	}
+	return byte(v), p.advance()
+}
+
+// node parses the children of node packet or the elements of slice packet
+func (p *textParser) node(sid byte) (iEncoder, error) {
+	slice := p.isPunct("[")
+	enc, close := NewNodePacketEncoder(sid), "}"
+	if slice {
+		enc, close = NewNodeSlicePacketEncoder(sid), "]"
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	for !p.isPunct(close) {
+		if p.tok.kind == textEOF {
+			return nil, p.errorf("expected %q, found EOF", close)
+		}
+		child, err := p.packet(slice)
+		if err != nil {
+			return nil, err
+		}
+		enc.addRawPacket(child)
+	}
+	return enc, p.advance()
+}
+
+func (p *textParser) primitive(sid byte) (iEncoder, error) {
+	enc := NewPrimitivePacketEncoder(sid)
+	switch p.tok.kind {
+	case textString:
+		enc.SetStringValue(p.tok.text)
+	case textHex:
+		buf, err := hex.DecodeString(p.tok.text)
+		if err != nil {
+			return nil, p.errorf("invalid hex %s", p.describe())
+		}
+		enc.SetBytesValue(buf)
+	case textIdent:
+		switch p.tok.text {
+		case "true", "false":
+			enc.SetBoolValue(p.tok.text == "true")
+		case "null":
+			enc.SetNull()
+		default:
+			return nil, p.errorf("unknown value %s", p.describe())
+		}
+	case textNumber:
+		if err := p.number(enc); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.errorf("expected value, found %s", p.describe())
+	}
+	return enc, p.advance()
+}
+
+func (p *textParser) number(enc *PrimitivePacketEncoder) error {
+	s := p.tok.text
+	isHex := strings.HasPrefix(strings.TrimLeft(s, "+-"), "0x") || strings.HasPrefix(strings.TrimLeft(s, "+-"), "0X")
+	if !isHex && strings.ContainsAny(s, ".eEfF") {
+		if strings.HasSuffix(s, "f") || strings.HasSuffix(s, "F") {
+			v, err := strconv.ParseFloat(s[:len(s)-1], 32)
+			if err != nil {
+				return p.errorf("invalid float32 %s", s)
+			}
+			enc.SetFloat32Value(float32(v))
+			return nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsInf(v, 0) {
+			return p.errorf("invalid float64 %s", s)
+		}
+		enc.SetFloat64Value(v)
+		return nil
+	}
+
+	if v, err := strconv.ParseInt(s, 0, 64); err == nil {
+		enc.SetInt64Value(v)
+		return nil
+	}
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "+"), 0, 64)
+	if err != nil {
+		return p.errorf("invalid integer %s", s)
+	}
+	enc.SetUInt64Value(v)
+	return nil
+}
@@ -0,0 +1,149 @@
+package y3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseText(t *testing.T) {
+	buf, err := ParseText(`0x3F { 0x2F { 0x01: "yomo" } 0x2E: h"0102" }`)
+	assert.NoError(t, err)
+
+	inner := NewNodePacketEncoder(0x2F)
+	name := NewPrimitivePacketEncoder(0x01)
+	name.SetStringValue("yomo")
+	inner.AddPrimitivePacket(name)
+	raw := NewPrimitivePacketEncoder(0x2E)
+	raw.SetBytesValue([]byte{0x01, 0x02})
+	root := NewNodePacketEncoder(0x3F)
+	root.AddNodePacket(inner)
+	root.AddPrimitivePacket(raw)
+	assert.Equal(t, root.Encode(), buf)
+}
+
+func TestParseTextValues(t *testing.T) {
+	tests := []struct {
+		text string
+		set  func(*PrimitivePacketEncoder)
+	}{
+		{`-1`, func(enc *PrimitivePacketEncoder) { enc.SetInt64Value(-1) }},
+		{`0x10`, func(enc *PrimitivePacketEncoder) { enc.SetInt64Value(16) }},
+		{`-0x10`, func(enc *PrimitivePacketEncoder) { enc.SetInt64Value(-16) }},
+		{`18446744073709551615`, func(enc *PrimitivePacketEncoder) { enc.SetUInt64Value(1<<64 - 1) }},
+		{`1.5`, func(enc *PrimitivePacketEncoder) { enc.SetFloat64Value(1.5) }},
+		{`-2.5e-3`, func(enc *PrimitivePacketEncoder) { enc.SetFloat64Value(-2.5e-3) }},
+		{`1.5f`, func(enc *PrimitivePacketEncoder) { enc.SetFloat32Value(1.5) }},
+		{`true`, func(enc *PrimitivePacketEncoder) { enc.SetBoolValue(true) }},
+		{`false`, func(enc *PrimitivePacketEncoder) { enc.SetBoolValue(false) }},
+		{`null`, func(enc *PrimitivePacketEncoder) { enc.SetNull() }},
+		{`"a\tb中"`, func(enc *PrimitivePacketEncoder) { enc.SetStringValue("a\tb中") }},
+		{`h""`, func(enc *PrimitivePacketEncoder) { enc.SetBytesValue(nil) }},
+	}
+	for _, tt := range tests {
+		buf, err := ParseText("0x01: " + tt.text)
+		assert.NoError(t, err, tt.text)
+		enc := NewPrimitivePacketEncoder(0x01)
+		tt.set(enc)
+		assert.Equal(t, enc.Encode(), buf, tt.text)
+	}
+}
+
+const testText = `0x3F {
+    0x01: "yomo"
+    0x02: h"FF"
+    0x03: null
+    0x04: h""
+    0x05 [
+        "a"
+        0x01: "b"
+        {
+            0x01: h"0A"
+        }
+        0x02 {}
+        [
+            h"00"
+        ]
+    ]
+    0x06 {}
+    0x07 []
+    0x08: h"6C696E650A"
+}
+`
+
+func TestFormatText(t *testing.T) {
+	buf, err := ParseText(`
+# comments and commas are allowed
+0x3F {
+	0x01: "yomo", 0x02: -1 // NVarInt
+	0x03: null 0x04: h""
+	0x05 [ "a" 0x01: "b" { 0x01: 10 } 0x02 {} [ h"00" ] ]
+	0x06 {} 0x07 [] 0x08: "line\n"
+}`)
+	assert.NoError(t, err)
+
+	text, err := FormatText(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, testText, text)
+
+	buf2, err := ParseText(text)
+	assert.NoError(t, err)
+	assert.Equal(t, buf, buf2)
+
+	var np NodePacket
+	_, err = DecodeToNodePacket(buf, &np)
+	assert.NoError(t, err)
+	assert.Equal(t, testText, np.Text())
+	pp := np.PrimitivePackets[0x01]
+	assert.Equal(t, "0x01: \"yomo\"\n", pp.Text())
+
+	// multiple packets
+	text, err = FormatText(append(buf2[:0:0], []byte{0x01, 0x01, 0x01, 0x02, 0x00}...))
+	assert.NoError(t, err)
+	assert.Equal(t, "0x01: h\"01\"\n0x02: h\"\"\n", text)
+	buf, err = ParseText(text)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x01, 0x01, 0x01, 0x02, 0x00}, buf)
+}
+
+func TestFormatTextWith(t *testing.T) {
+	buf, err := ParseText(`0x01 { 0x02: 1 0x03: 2 }`)
+	assert.NoError(t, err)
+	text, err := FormatTextWith(buf, func(path []byte, val []byte) string {
+		if path[len(path)-1] == 0x02 {
+			return "OK"
+		}
+		return ""
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0x01 {\n    0x02: OK\n    0x03: h\"02\"\n}\n", text)
+}
+
+func TestParseTextErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{`0x40: 1`, "y3: 1:1: SeqID 0x40 should be in [0..0x3F]"},
+		{`"a"`, `y3: 1:1: expected SeqID, found "a"`},
+		{`0x01 1`, `y3: 1:6: expected "{", "[" or ":", found "1"`},
+		{`0x01 { 0x02: 1`, `y3: 1:15: expected "}", found EOF`},
+		{`0x01 [ 1 ]`, ``},
+		{`0x01: abc`, `y3: 1:7: unknown value "abc"`},
+		{`0x01: }`, `y3: 1:7: expected value, found "}"`},
+		{`0x01: 1x`, `y3: 1:7: invalid integer 1x`},
+		{`0x01: 1e999`, `y3: 1:7: invalid float64 1e999`},
+		{`0x01: h"0"`, `y3: 1:7: invalid hex h"0"`},
+		{`0x01: "a`, `y3: 1:7: string not terminated`},
+		{`0x01: "\q"`, `y3: 1:7: invalid string "\q"`},
+		{"0x01 {\n  0x02: @", "y3: 2:9: unexpected character '@'"},
+	}
+	for _, tt := range tests {
+		_, err := ParseText(tt.text)
+		if tt.err == "" {
+			assert.NoError(t, err, tt.text)
+			continue
+		}
+		assert.EqualError(t, err, tt.err, tt.text)
+	}
+}
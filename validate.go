@@ -0,0 +1,151 @@
+package y3
+
+import "fmt"
+
+// maxValidateDepth is the max nesting depth of packets Validate can check
+const maxValidateDepth = 256
+
+// ValidateOptions limits the packets accepted by Validate
+type ValidateOptions struct {
+	// MaxDepth is the max nesting depth of packets, the root packet is at
+	// depth 1, 0 or the values greater than 256 mean 256
+	MaxDepth int
+}
+
+// element kinds of a slice packet being validated
+const (
+	elemNone byte = iota
+	elemPrimitive
+	elemNode
+)
+
+// validateFrame is a node packet being validated
+type validateFrame struct {
+	// end is the offset after the Val
+	end   int
+	slice bool
+	// elem is the kind of the elements if slice
+	elem byte
+}
+
+// Validate checks buf is exactly one well-formed packet without decoding it:
+// the lengths are valid PVarInts, the packets of every node sum exactly to its
+// Val, a primitive with the slice flag is an empty null, the elements of a
+// slice are either all nodes or all primitives except nulls, and there are no
+// trailing bytes. It doesn't allocate unless buf is invalid, opts can be nil.
+func Validate(buf []byte, opts *ValidateOptions) error {
+	if err := validate(buf, opts); err != nil {
+		return fmt.Errorf("y3: %w", err)
+	}
+	return nil
+}
+
+// validate is Validate without the prefix of error
+func validate(buf []byte, opts *ValidateOptions) error {
+	maxDepth := maxValidateDepth
+	if opts != nil && opts.MaxDepth > 0 && opts.MaxDepth < maxDepth {
+		maxDepth = opts.MaxDepth
+	}
+	if len(buf) == 0 {
+		return validateError(0, "empty buf")
+	}
+
+	var stack [maxValidateDepth]validateFrame
+	depth := 0
+	pos := 0
+	for {
+		// the parent is completed
+		for depth > 0 && pos == stack[depth-1].end {
+			depth--
+		}
+		if depth == 0 && pos > 0 {
+			break
+		}
+
+		start := pos
+		if depth == maxDepth {
+			return validateErrorf(start, "exceeded max depth %d", maxDepth)
+		}
+		end := len(buf)
+		if depth > 0 {
+			end = stack[depth-1].end
+		}
+		tag := buf[pos]
+		pos++
+		length, n, msg := validateLength(buf[pos:end])
+		if msg != "" {
+			return validateError(start, msg)
+		}
+		pos += n
+		if length > end-pos {
+			if depth == 0 {
+				return validateErrorf(start, "length %d exceeds the %d bytes left", length, end-pos)
+			}
+			return validateErrorf(start, "length %d exceeds the %d bytes left of the parent", length, end-pos)
+		}
+
+		isNode, isSlice := tag&0x80 != 0, tag&0x40 != 0
+		if !isNode && isSlice && length != 0 {
+			return validateErrorf(start, "primitive 0x%02X with slice flag should be null of length 0", tag&0x3F)
+		}
+		if depth > 0 && stack[depth-1].slice && !(!isNode && isSlice) {
+			kind := elemPrimitive
+			if isNode {
+				kind = elemNode
+			}
+			parent := &stack[depth-1]
+			if parent.elem != elemNone && parent.elem != kind {
+				return validateError(start, "slice mixes node and primitive elements")
+			}
+			parent.elem = kind
+		}
+
+		if !isNode || length == 0 {
+			pos += length
+			continue
+		}
+		stack[depth] = validateFrame{end: pos + length, slice: isSlice}
+		depth++
+	}
+
+	if pos != len(buf) {
+		return validateErrorf(pos, "%d trailing bytes", len(buf)-pos)
+	}
+	return nil
+}
+
+// validateLength decodes the PVarInt length at the beginning of buf, returns
+// the length, the size of it, or the message why it's malformed
+func validateLength(buf []byte) (length, size int, msg string) {
+	if len(buf) == 0 {
+		return 0, 0, "missing length"
+	}
+	// the sign bit is the second bit of the first byte
+	v := int64(int8(buf[0]) << 1 >> 7)
+	for size < len(buf) {
+		b := buf[size]
+		size++
+		if size > 5 {
+			return 0, 0, "length overflows int32"
+		}
+		v = v<<7 | int64(b&0x7F)
+		if b&0x80 == 0 {
+			if v < 0 {
+				return 0, 0, "negative length"
+			}
+			if v > 1<<31-1 {
+				return 0, 0, "length overflows int32"
+			}
+			return int(v), size, ""
+		}
+	}
+	return 0, 0, "truncated length"
+}
+
+func validateError(off int, msg string) error {
+	return fmt.Errorf("invalid packet at offset %d: %s", off, msg)
+}
+
+func validateErrorf(off int, format string, args ...any) error {
+	return validateError(off, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,56 @@
+package y3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	buf, err := ParseText(`0x3F { 0x2F { 0x01: "yomo" 0x03: null } 0x05 [1 null 2] 0x06 [{} null] 0x2E: h"0102" }`)
+	assert.NoError(t, err)
+	assert.NoError(t, Validate(buf, nil))
+	assert.NoError(t, Validate([]byte{0x01, 0x00}, nil))
+	assert.NoError(t, Validate([]byte{0x81, 0x00}, nil))
+
+	tests := []struct {
+		buf []byte
+		err string
+	}{
+		{nil, "y3: invalid packet at offset 0: empty buf"},
+		{[]byte{0x81}, "y3: invalid packet at offset 0: missing length"},
+		{[]byte{0x81, 0x80}, "y3: invalid packet at offset 0: truncated length"},
+		{[]byte{0x81, 0x7F}, "y3: invalid packet at offset 0: negative length"},
+		{[]byte{0x81, 0x81, 0x80, 0x80, 0x80, 0x80, 0x00}, "y3: invalid packet at offset 0: length overflows int32"},
+		{[]byte{0x81, 0x02, 0x01}, "y3: invalid packet at offset 0: length 2 exceeds the 1 bytes left"},
+		{[]byte{0x81, 0x01, 0x01, 0x00}, "y3: invalid packet at offset 2: missing length"},
+		{[]byte{0x81, 0x03, 0x01, 0x02, 0x61}, "y3: invalid packet at offset 2: length 2 exceeds the 1 bytes left of the parent"},
+		{[]byte{0x01, 0x01, 0x61, 0x00}, "y3: invalid packet at offset 3: 1 trailing bytes"},
+		{[]byte{0x41, 0x01, 0x61}, "y3: invalid packet at offset 0: primitive 0x01 with slice flag should be null of length 0"},
+		{[]byte{0xC1, 0x04, 0x00, 0x00, 0x80, 0x00}, "y3: invalid packet at offset 4: slice mixes node and primitive elements"},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.buf, nil)
+		if assert.Error(t, err, "% X", tt.buf) {
+			assert.Equal(t, tt.err, err.Error())
+		}
+	}
+
+	nested := []byte{0x81, 0x04, 0x82, 0x02, 0x83, 0x00}
+	assert.NoError(t, Validate(nested, &ValidateOptions{MaxDepth: 3}))
+	err = Validate(nested, &ValidateOptions{MaxDepth: 2})
+	if assert.Error(t, err) {
+		assert.Equal(t, "y3: invalid packet at offset 4: exceeded max depth 2", err.Error())
+	}
+}
+
+func TestValidateAllocs(t *testing.T) {
+	buf, err := ParseText(`0x3F { 0x2F { 0x01: "yomo" 0x03: null } 0x05 [1 2] 0x2E: h"0102" }`)
+	assert.NoError(t, err)
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := Validate(buf, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Equal(t, float64(0), allocs)
+}
@@ -0,0 +1,300 @@
+package y3
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Value is the dynamic representation of a Y3 packet, it can be decoded from
+// and encoded to bytes without knowing the Go types of the message.
+// A Value is one of *NodeValue, *SliceValue or *PrimitiveValue.
+type Value interface {
+	// SeqID returns the sequence ID of the packet
+	SeqID() byte
+	// Encode returns the Y3 encoded bytes of the packet
+	Encode() []byte
+
+	value()
+}
+
+// NodeValue is a node packet, holds children in the order they are encoded
+type NodeValue struct {
+	seqID    byte
+	children []Value
+}
+
+// NewNode creates a NodeValue with children
+func NewNode(sid byte, children ...Value) *NodeValue {
+	return &NodeValue{seqID: sid, children: children}
+}
+
+func (n *NodeValue) value() {}
+
+// SeqID returns the sequence ID of the node
+func (n *NodeValue) SeqID() byte {
+	return n.seqID
+}
+
+// Children returns all the children of the node
+func (n *NodeValue) Children() []Value {
+	return n.children
+}
+
+// Get returns the first child with the sequence ID, nil if not exists
+func (n *NodeValue) Get(sid byte) Value {
+	for _, c := range n.children {
+		if c.SeqID() == sid {
+			return c
+		}
+	}
+	return nil
+}
+
+// Set replaces the first child with the same sequence ID as v, or appends v
+// to the children if not exists
+func (n *NodeValue) Set(v Value) {
+	for i, c := range n.children {
+		if c.SeqID() == v.SeqID() {
+			n.children[i] = v
+			return
+		}
+	}
+	n.children = append(n.children, v)
+}
+
+// Remove deletes all the children with the sequence ID, returns false if not exists
+func (n *NodeValue) Remove(sid byte) bool {
+	var found bool
+	children := n.children[:0]
+	for _, c := range n.children {
+		if c.SeqID() == sid {
+			found = true
+			continue
+		}
+		children = append(children, c)
+	}
+	n.children = children
+	return found
+}
+
+// Encode returns the Y3 encoded bytes of the node
+func (n *NodeValue) Encode() []byte {
+	enc := NewNodePacketEncoder(n.seqID)
+	for _, c := range n.children {
+		enc.AddBytes(c.Encode())
+	}
+	return enc.Encode()
+}
+
+// SliceValue is a node packet with slice flag, holds its elements in order
+type SliceValue struct {
+	seqID byte
+	elems []Value
+}
+
+// NewSlice creates a SliceValue with elements
+func NewSlice(sid byte, elems ...Value) *SliceValue {
+	return &SliceValue{seqID: sid, elems: elems}
+}
+
+func (s *SliceValue) value() {}
+
+// SeqID returns the sequence ID of the slice
+func (s *SliceValue) SeqID() byte {
+	return s.seqID
+}
+
+// Len returns the count of elements
+func (s *SliceValue) Len() int {
+	return len(s.elems)
+}
+
+// Index returns the i-th element
+func (s *SliceValue) Index(i int) Value {
+	return s.elems[i]
+}
+
+// Elems returns all the elements of the slice
+func (s *SliceValue) Elems() []Value {
+	return s.elems
+}
+
+// Append adds elements to the end of the slice
+func (s *SliceValue) Append(elems ...Value) {
+	s.elems = append(s.elems, elems...)
+}
+
+// Encode returns the Y3 encoded bytes of the slice
+func (s *SliceValue) Encode() []byte {
+	enc := NewNodeSlicePacketEncoder(s.seqID)
+	for _, e := range s.elems {
+		enc.AddBytes(e.Encode())
+	}
+	return enc.Encode()
+}
+
+// PrimitiveValue is a primitive packet, its Val is kept as encoded bytes
+type PrimitiveValue struct {
+	seqID  byte
+	typed  bool
+	null   bool
+	valbuf []byte
+}
+
+// NewPrimitive creates a typed PrimitiveValue from a Go value, see
+// PrimitivePacketEncoder.SetValue for the supported types, nil creates a null
+func NewPrimitive(sid byte, v any) (*PrimitiveValue, error) {
+	if v == nil {
+		return NewNull(sid), nil
+	}
+	enc := NewTypedPrimitivePacketEncoder(sid)
+	if err := enc.SetValue(v); err != nil {
+		return nil, err
+	}
+	return &PrimitiveValue{seqID: sid, typed: true, valbuf: enc.GetValBuf()}, nil
+}
+
+// NewRawPrimitive creates an untyped PrimitiveValue with the encoded Val bytes
+func NewRawPrimitive(sid byte, valbuf []byte) *PrimitiveValue {
+	return &PrimitiveValue{seqID: sid, valbuf: valbuf}
+}
+
+// NewNull creates a PrimitiveValue of explicit null
+func NewNull(sid byte) *PrimitiveValue {
+	return &PrimitiveValue{seqID: sid, null: true}
+}
+
+func (p *PrimitiveValue) value() {}
+
+// SeqID returns the sequence ID of the primitive
+func (p *PrimitiveValue) SeqID() byte {
+	return p.seqID
+}
+
+// IsNull returns true if the primitive is an explicit null
+func (p *PrimitiveValue) IsNull() bool {
+	return p.null
+}
+
+// IsTyped returns true if the Val is prefixed with a type code
+func (p *PrimitiveValue) IsTyped() bool {
+	return p.typed
+}
+
+// Kind returns the type code of a typed primitive, KindUnknown if untyped
+func (p *PrimitiveValue) Kind() Kind {
+	if !p.typed {
+		return KindUnknown
+	}
+	return p.Packet().Kind()
+}
+
+// Bytes returns the encoded Val bytes
+func (p *PrimitiveValue) Bytes() []byte {
+	return p.valbuf
+}
+
+// Value returns the Go value of a typed primitive, nil if it's null
+func (p *PrimitiveValue) Value() (any, error) {
+	if p.null {
+		return nil, nil
+	}
+	if !p.typed {
+		return nil, errors.New("y3: not a typed primitive packet")
+	}
+	return p.Packet().Value()
+}
+
+// Decode decodes the primitive to the value pointed by v like Unmarshal does,
+// the registered codecs and the unmarshaler interfaces are consulted
+func (p *PrimitiveValue) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("y3: Decode needs a non-nil pointer, got %T", v)
+	}
+	pp := p.Packet()
+	if p.typed && !p.null {
+		if len(p.valbuf) == 0 {
+			return errors.New("y3: not a typed primitive packet")
+		}
+		// skip the type code
+		pp = NewRawPrimitive(p.seqID, p.valbuf[1:]).Packet()
+	}
+	return unmarshalPrimitive(pp, rv.Elem())
+}
+
+// Packet returns the primitive as a PrimitivePacket, so the To* methods can be used
+func (p *PrimitiveValue) Packet() *PrimitivePacket {
+	pp := &PrimitivePacket{}
+	// the bytes are produced by the encoder, decoding never fails
+	_, _ = DecodeToPrimitivePacket(p.Encode(), pp)
+	pp.typed = p.typed
+	return pp
+}
+
+// Encode returns the Y3 encoded bytes of the primitive
+func (p *PrimitiveValue) Encode() []byte {
+	enc := NewPrimitivePacketEncoder(p.seqID)
+	if p.null {
+		enc.SetNull()
+	} else {
+		enc.AddBytes(p.valbuf)
+	}
+	return enc.Encode()
+}
+
+// Parse decodes Y3 encoded bytes to a Value tree, primitives are untyped. buf
+// should be exactly one packet, the trailing bytes are rejected.
+func Parse(buf []byte) (Value, error) {
+	return parseValue(buf, false)
+}
+
+// ParseTyped decodes Y3 encoded bytes to a Value tree, all the primitives are
+// treated as typed primitives, see Parse
+func ParseTyped(buf []byte) (Value, error) {
+	return parseValue(buf, true)
+}
+
+func parseValue(buf []byte, typed bool) (Value, error) {
+	if len(buf) == 0 {
+		return nil, errors.New("y3: empty buf")
+	}
+	p, err := readRawPacket(buf, 0)
+	if err != nil {
+		return nil, fmt.Errorf("y3: %v", err)
+	}
+	if p.size() != len(buf) {
+		return nil, fmt.Errorf("y3: offset %d: %d trailing bytes", p.size(), len(buf)-p.size())
+	}
+	v, err := rawValue(p, typed)
+	if err != nil {
+		return nil, fmt.Errorf("y3: %v", err)
+	}
+	return v, nil
+}
+
+// rawValue returns the Value of p, the Val of nodes are parsed only once
+func rawValue(p *rawPacket, typed bool) (Value, error) {
+	if !p.tag.IsNode() {
+		// the Value doesn't share the bytes with buf
+		val := append([]byte{}, p.val...)
+		return &PrimitiveValue{seqID: p.tag.SeqID(), typed: typed, null: p.isNull(), valbuf: val}, nil
+	}
+	var children []Value
+	err := eachRawPacket(p.val, p.off+len(p.header), func(child *rawPacket) error {
+		v, err := rawValue(child, typed)
+		if err != nil {
+			return err
+		}
+		children = append(children, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.tag.IsSlice() {
+		return NewSlice(p.tag.SeqID(), children...), nil
+	}
+	return NewNode(p.tag.SeqID(), children...), nil
+}
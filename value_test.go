@@ -0,0 +1,123 @@
+package y3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueEncode(t *testing.T) {
+	id, err := NewPrimitive(0x02, int32(-1))
+	assert.NoError(t, err)
+	name := NewRawPrimitive(0x04, []byte("C"))
+	foo := NewNode(0x01, id, NewNode(0x03, name))
+	assert.Equal(t, []byte{0x81, 0x09, 0x02, 0x02, 0x01, 0xFF, 0x83, 0x03, 0x04, 0x01, 0x43}, foo.Encode())
+
+	tags := NewSlice(0x05, NewRawPrimitive(0x00, []byte("a")), NewRawPrimitive(0x00, []byte("b")))
+	assert.Equal(t, []byte{0xC5, 0x06, 0x00, 0x01, 0x61, 0x00, 0x01, 0x62}, tags.Encode())
+}
+
+func TestParse(t *testing.T) {
+	buf := []byte{0x85, 0x0D, 0x84, 0x06, 0x01, 0x01, 0xFF, 0x02, 0x01, 0x43, 0x83, 0x03, 0x01, 0x01, 0xFE}
+	v, err := Parse(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, buf, v.Encode())
+
+	root, ok := v.(*NodeValue)
+	assert.True(t, ok)
+	assert.EqualValues(t, 0x05, root.SeqID())
+	assert.Len(t, root.Children(), 2)
+	// children keep the order of encoding
+	assert.EqualValues(t, 0x04, root.Children()[0].SeqID())
+	assert.EqualValues(t, 0x03, root.Children()[1].SeqID())
+
+	n1 := root.Get(0x04).(*NodeValue)
+	p := n1.Get(0x01).(*PrimitiveValue)
+	assert.False(t, p.IsTyped())
+	assert.Equal(t, KindUnknown, p.Kind())
+	i, err := p.Packet().ToInt32()
+	assert.NoError(t, err)
+	assert.EqualValues(t, -1, i)
+	assert.Nil(t, root.Get(0x10))
+}
+
+func TestParseSlice(t *testing.T) {
+	buf := []byte{0xC5, 0x06, 0x00, 0x01, 0x61, 0x00, 0x01, 0x62}
+	v, err := Parse(buf)
+	assert.NoError(t, err)
+	s, ok := v.(*SliceValue)
+	assert.True(t, ok)
+	assert.Equal(t, 2, s.Len())
+	assert.Equal(t, []byte("b"), s.Index(1).(*PrimitiveValue).Bytes())
+	assert.Equal(t, buf, v.Encode())
+}
+
+func TestParseTyped(t *testing.T) {
+	name, _ := NewPrimitive(0x01, "yomo")
+	temp, _ := NewPrimitive(0x02, float32(36.5))
+	buf := NewNode(0x10, name, temp).Encode()
+
+	v, err := ParseTyped(buf)
+	assert.NoError(t, err)
+	root := v.(*NodeValue)
+	val, err := root.Get(0x02).(*PrimitiveValue).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, float32(36.5), val)
+	assert.Equal(t, KindString, root.Get(0x01).(*PrimitiveValue).Kind())
+
+	v, err = Parse(buf)
+	assert.NoError(t, err)
+	_, err = v.(*NodeValue).Get(0x01).(*PrimitiveValue).Value()
+	assert.EqualError(t, err, "y3: not a typed primitive packet")
+
+	// empty Val has no type code
+	v, err = ParseTyped([]byte{0x81, 0x02, 0x01, 0x00})
+	assert.NoError(t, err)
+	var s string
+	err = v.(*NodeValue).Get(0x01).(*PrimitiveValue).Decode(&s)
+	assert.EqualError(t, err, "y3: not a typed primitive packet")
+}
+
+func TestNodeValueSetRemove(t *testing.T) {
+	root := NewNode(0x01)
+	a, _ := NewPrimitive(0x01, int64(1))
+	b, _ := NewPrimitive(0x01, int64(2))
+	root.Set(a)
+	root.Set(b)
+	assert.Len(t, root.Children(), 1)
+	assert.Equal(t, b, root.Get(0x01))
+
+	assert.True(t, root.Remove(0x01))
+	assert.False(t, root.Remove(0x01))
+	assert.Equal(t, []byte{0x81, 0x00}, root.Encode())
+}
+
+func TestParseMalformed(t *testing.T) {
+	_, err := Parse(nil)
+	assert.EqualError(t, err, "y3: empty buf")
+	_, err = Parse([]byte{0x81, 0x04, 0x01, 0x05, 0x01})
+	assert.Error(t, err)
+	_, err = Parse([]byte{0x81, 0x03, 0x01, 0x05, 0x01})
+	assert.EqualError(t, err, "y3: offset 2: length 5 exceeds the 1 bytes left")
+	_, err = ParseTyped([]byte{0x81, 0x00, 0x01})
+	assert.EqualError(t, err, "y3: offset 2: 1 trailing bytes")
+}
+
+func TestNewPrimitiveUnsupported(t *testing.T) {
+	_, err := NewPrimitive(0x01, map[string]string{})
+	assert.Error(t, err)
+}
+
+func TestNullValue(t *testing.T) {
+	n, err := NewPrimitive(0x01, nil)
+	assert.NoError(t, err)
+	root := NewNode(0x05, n, NewRawPrimitive(0x02, nil))
+	buf := root.Encode()
+	assert.Equal(t, []byte{0x85, 0x04, 0x41, 0x00, 0x02, 0x00}, buf)
+
+	v, err := Parse(buf)
+	assert.NoError(t, err)
+	assert.True(t, v.(*NodeValue).Get(0x01).(*PrimitiveValue).IsNull())
+	assert.False(t, v.(*NodeValue).Get(0x02).(*PrimitiveValue).IsNull())
+	assert.Equal(t, buf, v.Encode())
+}
@@ -0,0 +1,67 @@
+// Package y3cbor converts Y3 packets to CBOR (RFC 8949) and back.
+//
+// A node packet is converted to a map with unsigned integer keys of the
+// SeqIDs, a slice packet to an array and a null to null. Typed primitives
+// (see y3.NewTypedPrimitivePacketEncoder) are converted to the CBOR values of
+// their types, time is a date/time string with tag 0, and untyped primitives
+// are byte strings of the encoded Val.
+//
+// When converting CBOR to Y3, integers are encoded as int32 if they fit, or as
+// int64, or uint64, half and single precision floats are encoded as float32,
+// and epoch-based date/time with tag 1 is accepted too. Without Typed, a byte
+// string is taken as the encoded Val, and other values are encoded by the
+// Set*Value methods of their types.
+package y3cbor
+
+import (
+	"fmt"
+
+	"github.com/yomorun/y3/internal/bridge"
+)
+
+// Options describes how the packets are converted, nil is the same as the zero value
+type Options struct {
+	// SeqID is the SeqID of the root packet when converting CBOR to Y3
+	SeqID byte
+	// Typed means the primitives are typed, they are decoded by their type
+	// codes, and encoded with type codes
+	Typed bool
+}
+
+// Encode converts a Y3 encoded node packet to CBOR
+func Encode(buf []byte, opts *Options) ([]byte, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	m, err := bridge.FromPacket(buf, opts.Typed)
+	if err != nil {
+		return nil, fmt.Errorf("y3cbor: %w", err)
+	}
+	var e encoder
+	e.value(m)
+	return e.buf, nil
+}
+
+// Decode converts a CBOR map to a Y3 encoded node packet
+func Decode(data []byte, opts *Options) ([]byte, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	d := &decoder{data: data}
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if d.off < len(d.data) {
+		return nil, fmt.Errorf("y3cbor: invalid data after top-level value at offset %d", d.off)
+	}
+	m, ok := v.(bridge.Map)
+	if !ok {
+		return nil, fmt.Errorf("y3cbor: expected map, found %T", v)
+	}
+	buf, err := bridge.ToPacket(opts.SeqID, m, opts.Typed)
+	if err != nil {
+		return nil, fmt.Errorf("y3cbor: %w", err)
+	}
+	return buf, nil
+}
@@ -0,0 +1,161 @@
+package y3cbor
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/y3"
+)
+
+func TestTypedRoundTrip(t *testing.T) {
+	// {1: "yomo", 2: -1, 3: [1, 1000000], 4: {1: true}, 5: null, 6: 1.5, 7: h'0102', 8: 4294967296}
+	data := []byte{
+		0xA8,
+		0x01, 0x64, 'y', 'o', 'm', 'o',
+		0x02, 0x20,
+		0x03, 0x82, 0x01, 0x1A, 0x00, 0x0F, 0x42, 0x40,
+		0x04, 0xA1, 0x01, 0xF5,
+		0x05, 0xF6,
+		0x06, 0xFB, 0x3F, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x07, 0x42, 0x01, 0x02,
+		0x08, 0x1B, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+	}
+	opts := &Options{SeqID: 0x30, Typed: true}
+	buf, err := Decode(data, opts)
+	assert.NoError(t, err)
+
+	v, err := y3.ParseTyped(buf)
+	assert.NoError(t, err)
+	n := v.(*y3.NodeValue)
+	assert.Equal(t, byte(0x30), n.SeqID())
+	assert.Equal(t, y3.KindInt32, n.Get(0x02).(*y3.PrimitiveValue).Kind())
+	assert.Equal(t, y3.KindInt64, n.Get(0x08).(*y3.PrimitiveValue).Kind())
+	assert.True(t, n.Get(0x05).(*y3.PrimitiveValue).IsNull())
+
+	res, err := Encode(buf, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, data, res)
+}
+
+func TestUntyped(t *testing.T) {
+	// {1: "yomo", 2: -1}
+	data := []byte{0xA2, 0x01, 0x64, 'y', 'o', 'm', 'o', 0x02, 0x20}
+	buf, err := Decode(data, nil)
+	assert.NoError(t, err)
+
+	enc := y3.NewNodePacketEncoder(0x01)
+	p1 := y3.NewPrimitivePacketEncoder(0x01)
+	p1.SetStringValue("yomo")
+	enc.AddPrimitivePacket(p1)
+	p2 := y3.NewPrimitivePacketEncoder(0x02)
+	p2.SetInt32Value(-1)
+	enc.AddPrimitivePacket(p2)
+	assert.Equal(t, enc.Encode()[1:], buf[1:])
+
+	// the encoded Val bytes are byte strings
+	res, err := Encode(buf, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xA2, 0x01, 0x44, 'y', 'o', 'm', 'o', 0x02, 0x41, 0xFF}, res)
+
+	buf2, err := Decode(res, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, buf, buf2)
+}
+
+func TestDecodeValues(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want any
+	}{
+		// half precision floats
+		{[]byte{0xF9, 0x3C, 0x00}, float32(1)},
+		{[]byte{0xF9, 0xC4, 0x00}, float32(-4)},
+		{[]byte{0xF9, 0x7C, 0x00}, float32(math.Inf(1))},
+		{[]byte{0xF9, 0x00, 0x01}, float32(5.960464477539063e-8)},
+		{[]byte{0xFA, 0x47, 0xC3, 0x50, 0x00}, float32(100000)},
+		{[]byte{0x3B, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, int64(math.MinInt64)},
+		{[]byte{0xF4}, false},
+		{[]byte{0xF7}, nil},
+		// indefinite length strings and array
+		{[]byte{0x7F, 0x62, 'a', 'b', 0x61, 'c', 0xFF}, "abc"},
+		{[]byte{0x5F, 0x41, 0x01, 0x40, 0xFF}, []byte{0x01}},
+		{[]byte{0x9F, 0x01, 0x9F, 0xFF, 0xFF}, []any{uint64(1), []any{}}},
+		// date/time
+		{append([]byte{0xC0, 0x74}, "2013-03-21T20:04:00Z"...), time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+		{[]byte{0xC1, 0x1A, 0x51, 0x4B, 0x67, 0xB0}, time.Unix(1363896240, 0)},
+		{[]byte{0xC1, 0xFB, 0x41, 0xD4, 0x52, 0xD9, 0xEC, 0x20, 0x00, 0x00}, time.Unix(1363896240, 5e8)},
+	}
+	for _, tt := range tests {
+		d := &decoder{data: tt.data}
+		v, err := d.value()
+		assert.NoError(t, err, "%x", tt.data)
+		if want, ok := tt.want.(time.Time); ok {
+			assert.True(t, want.Equal(v.(time.Time)), "%x", tt.data)
+			continue
+		}
+		assert.Equal(t, tt.want, v, "%x", tt.data)
+		assert.Equal(t, len(tt.data), d.off)
+	}
+}
+
+func TestEncodeTime(t *testing.T) {
+	buf := y3.NewNode(0x01, mustPrimitive(t, 0x01, time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC))).Encode()
+	res, err := Encode(buf, &Options{Typed: true})
+	assert.NoError(t, err)
+	buf2, err := Decode(res, &Options{SeqID: 0x01, Typed: true})
+	assert.NoError(t, err)
+	assert.Equal(t, buf, buf2)
+}
+
+func mustPrimitive(t *testing.T, sid byte, v any) y3.Value {
+	p, err := y3.NewPrimitive(sid, v)
+	assert.NoError(t, err)
+	return p
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		data []byte
+		err  string
+	}{
+		{[]byte{}, "y3cbor: unexpected end of data"},
+		{[]byte{0xA1, 0x01}, "y3cbor: unexpected end of data"},
+		{[]byte{0x5A, 0xFF, 0xFF, 0xFF, 0xFF}, "y3cbor: unexpected end of data"},
+		{[]byte{0x01}, "y3cbor: expected map, found uint64"},
+		{[]byte{0xA0, 0x00}, "y3cbor: invalid data after top-level value at offset 1"},
+		{[]byte{0xA1, 0x61, 'a', 0x01}, "y3cbor: offset 1: map key should be an unsigned integer"},
+		{[]byte{0xA1, 0x18, 0x40, 0x01}, "y3cbor: key 64 is not a SeqID"},
+		{[]byte{0xA2, 0x01, 0x01, 0x01, 0x02}, "y3cbor: duplicated key 1"},
+		{[]byte{0xA1, 0x01, 0xC2, 0x41, 0x01}, "y3cbor: offset 2: unsupported tag 2"},
+		{[]byte{0xA1, 0x01, 0xC0, 0x01}, "y3cbor: offset 2: invalid content of tag 0"},
+		{[]byte{0xA1, 0x01, 0xFF}, "y3cbor: offset 2: unexpected break"},
+		{[]byte{0xA1, 0x01, 0x1F}, "y3cbor: offset 2: unexpected break"},
+		{[]byte{0xA1, 0x01, 0x1C}, "y3cbor: offset 3: reserved additional information 28"},
+		{[]byte{0xA1, 0x01, 0xE0}, "y3cbor: offset 2: unsupported simple value 0"},
+		{[]byte{0xA1, 0x01, 0x61, 0xFF}, "y3cbor: offset 2: invalid UTF-8 text string"},
+		{[]byte{0xA1, 0x01, 0x7F, 0x41, 0x01, 0xFF}, "y3cbor: offset 3: invalid chunk of indefinite length string"},
+	}
+	for _, tt := range tests {
+		_, err := Decode(tt.data, nil)
+		assert.EqualError(t, err, tt.err, "%x", tt.data)
+	}
+
+	deep := make([]byte, 0, 1200)
+	deep = append(deep, 0xA1, 0x01)
+	for i := 0; i < 600; i++ {
+		deep = append(deep, 0x81)
+	}
+	_, err := Decode(deep, nil)
+	assert.Contains(t, err.Error(), "exceeded max depth 512")
+
+	// nested tags
+	deep = append([]byte{0xA1, 0x01}, bytes.Repeat([]byte{0xC1}, 1<<20)...)
+	_, err = Decode(deep, nil)
+	assert.Contains(t, err.Error(), "exceeded max depth 512")
+
+	_, err = Encode([]byte{0x01, 0x01, 0x01}, nil)
+	assert.EqualError(t, err, "y3cbor: packet 0x1 is not a node packet")
+}
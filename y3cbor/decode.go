@@ -0,0 +1,302 @@
+package y3cbor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yomorun/y3/internal/bridge"
+)
+
+// maxDepth limits the nesting of arrays, maps and tags
+const maxDepth = 512
+
+var errUnexpectedEnd = errors.New("y3cbor: unexpected end of data")
+
+type decoder struct {
+	data  []byte
+	off   int
+	depth int
+}
+
+// errorf returns the error at the current offset
+func (d *decoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("y3cbor: offset %d: %s", d.off, fmt.Sprintf(format, args...))
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.off >= len(d.data) {
+		return 0, errUnexpectedEnd
+	}
+	b := d.data[d.off]
+	d.off++
+	return b, nil
+}
+
+func (d *decoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.off) {
+		return nil, errUnexpectedEnd
+	}
+	b := d.data[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
+
+// head reads the initial byte and the argument, indefinite is true if the
+// additional information is 31
+func (d *decoder) head() (major, info byte, n uint64, indefinite bool, err error) {
+	b, err := d.byte()
+	if err != nil {
+		return
+	}
+	major, info = b>>5, b&0x1F
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		var buf []byte
+		buf, err = d.bytes(1 << (info - 24))
+		if err != nil {
+			return
+		}
+		for _, c := range buf {
+			n = n<<8 | uint64(c)
+		}
+	case info == 31:
+		indefinite = true
+	default:
+		err = d.errorf("reserved additional information %d", info)
+	}
+	return
+}
+
+// isBreak reads the break stop code of indefinite length items if it's next
+func (d *decoder) isBreak() (bool, error) {
+	if d.off >= len(d.data) {
+		return false, errUnexpectedEnd
+	}
+	if d.data[d.off] == 0xFF {
+		d.off++
+		return true, nil
+	}
+	return false, nil
+}
+
+func (d *decoder) value() (any, error) {
+	start := d.off
+	major, info, n, indefinite, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if indefinite && (major < majorBytes || major == majorTag) {
+		d.off = start
+		return nil, d.errorf("unexpected break")
+	}
+
+	switch major {
+	case majorUint:
+		return n, nil
+	case majorNegInt:
+		if n > math.MaxInt64 {
+			d.off = start
+			return nil, d.errorf("integer overflows int64")
+		}
+		return -1 - int64(n), nil
+	case majorBytes, majorText:
+		b, err := d.string(major, n, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == majorBytes {
+			return append([]byte(nil), b...), nil
+		}
+		if !utf8.Valid(b) {
+			d.off = start
+			return nil, d.errorf("invalid UTF-8 text string")
+		}
+		return string(b), nil
+	case majorArray, majorMap:
+		if d.depth++; d.depth > maxDepth {
+			return nil, d.errorf("exceeded max depth %d", maxDepth)
+		}
+		defer func() { d.depth-- }()
+		if major == majorArray {
+			return d.array(n, indefinite)
+		}
+		return d.mapValue(n, indefinite)
+	case majorTag:
+		return d.tag(start, n)
+	}
+
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		// null and undefined
+		return nil, nil
+	case 25:
+		return halfToFloat32(uint16(n)), nil
+	case 26:
+		return math.Float32frombits(uint32(n)), nil
+	case 27:
+		return math.Float64frombits(n), nil
+	}
+	d.off = start
+	if indefinite {
+		return nil, d.errorf("unexpected break")
+	}
+	return nil, d.errorf("unsupported simple value %d", n)
+}
+
+// string reads the content of a byte or text string, the chunks of
+// indefinite length string are concatenated
+func (d *decoder) string(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		return d.bytes(n)
+	}
+	var res []byte
+	for {
+		ok, err := d.isBreak()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return res, nil
+		}
+		start := d.off
+		m, _, n, indefinite, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || indefinite {
+			d.off = start
+			return nil, d.errorf("invalid chunk of indefinite length string")
+		}
+		b, err := d.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, b...)
+	}
+}
+
+func (d *decoder) array(n uint64, indefinite bool) ([]any, error) {
+	var a []any
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite {
+			ok, err := d.isBreak()
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				break
+			}
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	if a == nil {
+		a = []any{}
+	}
+	return a, nil
+}
+
+func (d *decoder) mapValue(n uint64, indefinite bool) (bridge.Map, error) {
+	m := bridge.Map{}
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite {
+			ok, err := d.isBreak()
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				break
+			}
+		}
+		start := d.off
+		major, _, key, indefinite, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if major != majorUint || indefinite {
+			d.off = start
+			return nil, d.errorf("map key should be an unsigned integer")
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, bridge.Entry{Key: key, Value: v})
+	}
+	return m, nil
+}
+
+// tag reads the content of tag n, only the date/time tags are supported
+func (d *decoder) tag(start int, n uint64) (any, error) {
+	if d.depth++; d.depth > maxDepth {
+		return nil, d.errorf("exceeded max depth %d", maxDepth)
+	}
+	defer func() { d.depth-- }()
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	switch n {
+	case tagDateTime:
+		if s, ok := v.(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return t, nil
+			}
+		}
+	case tagEpochTime:
+		switch v := v.(type) {
+		case uint64:
+			if v <= math.MaxInt64 {
+				return time.Unix(int64(v), 0), nil
+			}
+		case int64:
+			return time.Unix(v, 0), nil
+		case float32:
+			return epochTime(float64(v)), nil
+		case float64:
+			return epochTime(v), nil
+		}
+	default:
+		d.off = start
+		return nil, d.errorf("unsupported tag %d", n)
+	}
+	d.off = start
+	return nil, d.errorf("invalid content of tag %d", n)
+}
+
+func epochTime(sec float64) time.Time {
+	s, frac := math.Modf(sec)
+	return time.Unix(int64(s), int64(frac*1e9))
+}
+
+// halfToFloat32 converts IEEE 754 half precision float
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1F
+	frac := uint32(h) & 0x3FF
+	switch {
+	case exp == 0x1F:
+		// Inf and NaN
+		return math.Float32frombits(sign | 0xFF<<23 | frac<<13)
+	case exp == 0:
+		// zero and subnormal
+		f := float32(frac) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+}
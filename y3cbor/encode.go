@@ -0,0 +1,103 @@
+package y3cbor
+
+import (
+	"math"
+	"time"
+
+	"github.com/yomorun/y3/internal/bridge"
+)
+
+// major types
+const (
+	majorUint byte = iota
+	majorNegInt
+	majorBytes
+	majorText
+	majorArray
+	majorMap
+	majorTag
+	majorSimple
+)
+
+const (
+	tagDateTime  = 0
+	tagEpochTime = 1
+)
+
+type encoder struct {
+	buf []byte
+}
+
+// head writes the initial byte and the argument n
+func (e *encoder) head(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, major|25)
+		e.uint(n, 2)
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, major|26)
+		e.uint(n, 4)
+	default:
+		e.buf = append(e.buf, major|27)
+		e.uint(n, 8)
+	}
+}
+
+// uint writes n in big-endian with size bytes
+func (e *encoder) uint(n uint64, size int) {
+	for i := size - 1; i >= 0; i-- {
+		e.buf = append(e.buf, byte(n>>(8*i)))
+	}
+}
+
+func (e *encoder) value(v any) {
+	switch v := v.(type) {
+	case bridge.Map:
+		e.head(majorMap, uint64(len(v)))
+		for _, entry := range v {
+			e.head(majorUint, entry.Key)
+			e.value(entry.Value)
+		}
+	case []any:
+		e.head(majorArray, uint64(len(v)))
+		for _, elem := range v {
+			e.value(elem)
+		}
+	case nil:
+		e.buf = append(e.buf, 0xF6)
+	case bool:
+		if v {
+			e.buf = append(e.buf, 0xF5)
+		} else {
+			e.buf = append(e.buf, 0xF4)
+		}
+	case int64:
+		if v >= 0 {
+			e.head(majorUint, uint64(v))
+		} else {
+			e.head(majorNegInt, uint64(-1-v))
+		}
+	case uint64:
+		e.head(majorUint, v)
+	case float32:
+		e.buf = append(e.buf, majorSimple<<5|26)
+		e.uint(uint64(math.Float32bits(v)), 4)
+	case float64:
+		e.buf = append(e.buf, majorSimple<<5|27)
+		e.uint(math.Float64bits(v), 8)
+	case string:
+		e.head(majorText, uint64(len(v)))
+		e.buf = append(e.buf, v...)
+	case []byte:
+		e.head(majorBytes, uint64(len(v)))
+		e.buf = append(e.buf, v...)
+	case time.Time:
+		e.head(majorTag, tagDateTime)
+		e.value(v.Format(time.RFC3339Nano))
+	}
+}
@@ -0,0 +1,265 @@
+package y3json
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/yomorun/y3"
+	"github.com/yomorun/y3/schema"
+)
+
+var errTrailingData = errors.New("y3json: invalid data after top-level value")
+
+func (d *Decoder) decode() ([]byte, error) {
+	tok, err := d.dec.Token()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return nil, fmt.Errorf("y3json: expected JSON object, found %v", tok)
+	}
+
+	msg, err := rootMessage(d.opts, d.opts.SeqID)
+	if err != nil {
+		return nil, err
+	}
+	sid := d.opts.SeqID
+	if msg != nil && msg.HasSeqID {
+		sid = msg.SeqID
+	}
+	v, err := d.object(sid, msg)
+	if err != nil {
+		return nil, err
+	}
+	return v.Encode(), nil
+}
+
+// object decodes the JSON object after '{' to a node, msg is nil if there's
+// no schema
+func (d *Decoder) object(sid byte, msg *schema.Message) (y3.Value, error) {
+	var children []y3.Value
+	for d.dec.More() {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+
+		var f *schema.Field
+		if msg != nil {
+			for _, mf := range msg.Fields {
+				if mf.Name == key {
+					f = mf
+				}
+			}
+		}
+		childSID := byte(0)
+		if f != nil {
+			childSID = f.SeqID
+		} else {
+			v, err := strconv.ParseUint(key, 0, 8)
+			if err != nil || v > 0x3F {
+				if msg != nil {
+					return nil, fmt.Errorf("y3json: unknown field %q of %s", key, msg.FullName())
+				}
+				return nil, fmt.Errorf("y3json: key %q should be a SeqID like 0x01", key)
+			}
+			childSID = byte(v)
+			if msg != nil {
+				f = msg.Field(childSID)
+			}
+		}
+
+		child, err := d.value(childSID, f, f == nil || f.Label == schema.Repeated)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	// '}'
+	if _, err := d.dec.Token(); err != nil {
+		return nil, err
+	}
+	return y3.NewNode(sid, children...), nil
+}
+
+// value decodes the next JSON value, f is nil if there's no schema, array
+// means the value can be an array
+func (d *Decoder) value(sid byte, f *schema.Field, array bool) (y3.Value, error) {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case nil:
+		return y3.NewNull(sid), nil
+	case json.Delim('{'):
+		if f != nil && f.Message == nil {
+			return nil, fmt.Errorf("y3json: %s should not be an object", f.Name)
+		}
+		var msg *schema.Message
+		if f != nil {
+			msg = f.Message
+		}
+		return d.object(sid, msg)
+	case json.Delim('['):
+		if !array {
+			return nil, fmt.Errorf("y3json: %s should not be an array", f.Name)
+		}
+		s := y3.NewSlice(sid)
+		for d.dec.More() {
+			elem, err := d.value(y3.SliceElementSeqID, f, f == nil)
+			if err != nil {
+				return nil, err
+			}
+			s.Append(elem)
+		}
+		// ']'
+		if _, err := d.dec.Token(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	if f == nil {
+		v, err := d.schemalessValue(tok)
+		if err != nil {
+			return nil, err
+		}
+		return d.primitive(sid, v)
+	}
+	if f.Message != nil {
+		return nil, fmt.Errorf("y3json: %s should be an object, found %v", f.Name, tok)
+	}
+	if f.Label == schema.Repeated && array {
+		return nil, fmt.Errorf("y3json: %s should be an array, found %v", f.Name, tok)
+	}
+	v, err := typedValue(tok, f)
+	if err != nil {
+		return nil, err
+	}
+	return d.primitive(sid, v)
+}
+
+// primitive creates the primitive of the Go value
+func (d *Decoder) primitive(sid byte, v any) (y3.Value, error) {
+	if d.opts.Typed {
+		return y3.NewPrimitive(sid, v)
+	}
+	enc := y3.NewPrimitivePacketEncoder(sid)
+	if err := enc.SetValue(v); err != nil {
+		return nil, err
+	}
+	return y3.NewRawPrimitive(sid, enc.GetValBuf()), nil
+}
+
+// schemalessValue returns the Go value of the token, integers are int64,
+// other numbers are float64, strings are base64 decoded unless typed
+func (d *Decoder) schemalessValue(tok json.Token) (any, error) {
+	switch t := tok.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	case string:
+		if d.opts.Typed {
+			return t, nil
+		}
+		v, err := base64.StdEncoding.DecodeString(t)
+		if err != nil {
+			return nil, fmt.Errorf("y3json: untyped value %q should be base64 encoded", t)
+		}
+		return v, nil
+	}
+	return tok, nil
+}
+
+// typedValue returns the Go value of the token as the type of field f
+func typedValue(tok json.Token, f *schema.Field) (any, error) {
+	invalid := func() error {
+		return fmt.Errorf("y3json: invalid %s value %v of %s", f.Type, tok, f.Name)
+	}
+
+	if f.Enum != nil {
+		switch t := tok.(type) {
+		case string:
+			for _, ev := range f.Enum.Values {
+				if ev.Name == t {
+					return ev.Value, nil
+				}
+			}
+		case json.Number:
+			if v, err := strconv.ParseInt(t.String(), 10, 32); err == nil {
+				return int32(v), nil
+			}
+		}
+		return nil, invalid()
+	}
+
+	switch t := tok.(type) {
+	case json.Number:
+		s := t.String()
+		switch f.Type {
+		case "int32":
+			v, err := strconv.ParseInt(s, 10, 32)
+			if err == nil {
+				return int32(v), nil
+			}
+		case "int64":
+			v, err := strconv.ParseInt(s, 10, 64)
+			if err == nil {
+				return v, nil
+			}
+		case "uint32":
+			v, err := strconv.ParseUint(s, 10, 32)
+			if err == nil {
+				return uint32(v), nil
+			}
+		case "uint64":
+			v, err := strconv.ParseUint(s, 10, 64)
+			if err == nil {
+				return v, nil
+			}
+		case "float32":
+			v, err := strconv.ParseFloat(s, 32)
+			if err == nil {
+				return float32(v), nil
+			}
+		case "float64":
+			v, err := strconv.ParseFloat(s, 64)
+			if err == nil {
+				return v, nil
+			}
+		}
+	case string:
+		switch f.Type {
+		case "string":
+			return t, nil
+		case "bytes":
+			v, err := base64.StdEncoding.DecodeString(t)
+			if err == nil {
+				return v, nil
+			}
+		case "time":
+			v, err := time.Parse(time.RFC3339Nano, t)
+			if err == nil {
+				return v, nil
+			}
+		}
+	case bool:
+		if f.Type == "bool" {
+			return t, nil
+		}
+	}
+	return nil, invalid()
+}
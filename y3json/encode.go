@@ -0,0 +1,253 @@
+package y3json
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yomorun/y3"
+	"github.com/yomorun/y3/schema"
+)
+
+type encodeState struct {
+	w    *bufio.Writer
+	opts *Options
+}
+
+func newEncodeState(w *bufio.Writer, opts *Options) *encodeState {
+	if opts == nil {
+		opts = &Options{}
+	}
+	return &encodeState{w: w, opts: opts}
+}
+
+func (e *encodeState) encode(buf []byte) error {
+	parse := y3.Parse
+	if e.opts.Typed {
+		parse = y3.ParseTyped
+	}
+	v, err := parse(buf)
+	if err != nil {
+		return err
+	}
+	msg, err := rootMessage(e.opts, v.SeqID())
+	if err != nil {
+		return err
+	}
+	return e.value(v, msg, nil)
+}
+
+// rootMessage returns the message of the root packet with SeqID sid
+func rootMessage(opts *Options, sid byte) (*schema.Message, error) {
+	if opts.Schema == nil {
+		return nil, nil
+	}
+	if opts.Message != "" {
+		m := opts.Schema.Lookup(opts.Message)
+		if m == nil {
+			return nil, fmt.Errorf("y3json: message %s is not found", opts.Message)
+		}
+		return m, nil
+	}
+	for _, m := range opts.Schema.Messages {
+		if m.HasSeqID && m.SeqID == sid {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+// value writes v, msg is the message of node, f is the field of v, both are
+// nil if there's no schema for v
+func (e *encodeState) value(v y3.Value, msg *schema.Message, f *schema.Field) error {
+	if p, ok := v.(*y3.PrimitiveValue); ok && p.IsNull() {
+		_, err := e.w.WriteString("null")
+		return err
+	}
+	if f != nil {
+		if err := checkKind(v, f); err != nil {
+			return err
+		}
+	}
+
+	switch v := v.(type) {
+	case *y3.NodeValue:
+		return e.object(v, msg)
+	case *y3.SliceValue:
+		e.w.WriteByte('[')
+		for i, elem := range v.Elems() {
+			if i > 0 {
+				e.w.WriteByte(',')
+			}
+			var err error
+			if f != nil {
+				err = e.elem(elem, f)
+			} else {
+				err = e.value(elem, nil, nil)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return e.w.WriteByte(']')
+	case *y3.PrimitiveValue:
+		return e.primitive(v, f)
+	}
+	return nil
+}
+
+// elem writes the element of the repeated field f
+func (e *encodeState) elem(v y3.Value, f *schema.Field) error {
+	if p, ok := v.(*y3.PrimitiveValue); ok && p.IsNull() {
+		_, err := e.w.WriteString("null")
+		return err
+	}
+	switch v := v.(type) {
+	case *y3.NodeValue:
+		if f.Message == nil {
+			return fmt.Errorf("y3json: element of %s should be a primitive packet", f.Name)
+		}
+		return e.object(v, f.Message)
+	case *y3.PrimitiveValue:
+		if f.Message != nil {
+			return fmt.Errorf("y3json: element of %s should be a node packet", f.Name)
+		}
+		return e.primitive(v, f)
+	}
+	return fmt.Errorf("y3json: element of %s should not be a slice packet", f.Name)
+}
+
+func (e *encodeState) object(n *y3.NodeValue, msg *schema.Message) error {
+	e.w.WriteByte('{')
+	for i, child := range n.Children() {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		var f *schema.Field
+		if msg != nil {
+			f = msg.Field(child.SeqID())
+		}
+		var err error
+		if f != nil {
+			err = e.json(f.Name)
+		} else {
+			err = e.json(seqIDKey(child.SeqID()))
+		}
+		if err != nil {
+			return err
+		}
+		e.w.WriteByte(':')
+		if f != nil {
+			err = e.value(child, f.Message, f)
+		} else {
+			err = e.value(child, nil, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.w.WriteByte('}')
+}
+
+// checkKind checks the packet type of v matches the field f
+func checkKind(v y3.Value, f *schema.Field) error {
+	want := "primitive"
+	switch {
+	case f.Label == schema.Repeated:
+		want = "slice"
+	case f.Message != nil:
+		want = "node"
+	}
+	got := "primitive"
+	switch v.(type) {
+	case *y3.SliceValue:
+		got = "slice"
+	case *y3.NodeValue:
+		got = "node"
+	}
+	if got != want {
+		return fmt.Errorf("y3json: %s (%#x) should be a %s packet, got %s", f.Name, f.SeqID, want, got)
+	}
+	return nil
+}
+
+func (e *encodeState) primitive(p *y3.PrimitiveValue, f *schema.Field) error {
+	if f == nil {
+		if !p.IsTyped() {
+			return e.json(p.Bytes())
+		}
+		v, err := p.Value()
+		if err != nil {
+			return err
+		}
+		return e.json(v)
+	}
+
+	if f.Enum != nil {
+		var v int32
+		if err := p.Decode(&v); err != nil {
+			return err
+		}
+		for _, ev := range f.Enum.Values {
+			if ev.Value == v {
+				return e.json(ev.Name)
+			}
+		}
+		return e.json(v)
+	}
+
+	var v any
+	switch f.Type {
+	case "int32":
+		v = new(int32)
+	case "int64":
+		v = new(int64)
+	case "uint32":
+		v = new(uint32)
+	case "uint64":
+		v = new(uint64)
+	case "float32":
+		v = new(float32)
+	case "float64":
+		v = new(float64)
+	case "bool":
+		v = new(bool)
+	case "string":
+		v = new(string)
+	case "bytes":
+		v = new([]byte)
+	case "time":
+		v = new(time.Time)
+	}
+	if err := p.Decode(v); err != nil {
+		return fmt.Errorf("y3json: %s (%#x): %w", f.Name, f.SeqID, err)
+	}
+	return e.json(v)
+}
+
+// json writes the JSON of the Go value
+func (e *encodeState) json(v any) error {
+	// the empty bytes of a non-null primitive are "", not null
+	switch b := v.(type) {
+	case []byte:
+		if b == nil {
+			v = []byte{}
+		}
+	case *[]byte:
+		if *b == nil {
+			v = []byte{}
+		}
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("y3json: %w", err)
+	}
+	_, err = e.w.Write(buf)
+	return err
+}
+
+// seqIDKey returns the key of the child without schema
+func seqIDKey(sid byte) string {
+	return fmt.Sprintf("0x%02X", sid)
+}
@@ -0,0 +1,117 @@
+// Package y3json converts Y3 packets to JSON and back.
+//
+// A node packet is converted to a JSON object, a slice packet to an array and
+// a null to null. Without schema the keys of objects are the SeqIDs like
+// "0x01", with a schema they are the field names, and the children not in the
+// schema are still keyed by their SeqIDs.
+//
+// The JSON types of primitives are decided by the schema, or by the type codes
+// when the packets are typed (see y3.NewTypedPrimitivePacketEncoder):
+//
+//	int32, int64, uint32, uint64, float32, float64  number
+//	bool                                            true or false
+//	string                                          string
+//	bytes                                           base64 string
+//	time                                            RFC 3339 string
+//	enum                                            the symbolic name, or number if unknown
+//
+// A primitive without schema and type code is converted to a base64 string.
+package y3json
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/yomorun/y3/schema"
+)
+
+// Options describes how the packets are converted, nil is the same as the zero value
+type Options struct {
+	// Schema gives the field names and the types of primitives
+	Schema *schema.Schema
+	// Message is the full name of the root message in Schema, by default it's
+	// the message declaring the SeqID of the root packet
+	Message string
+	// SeqID is the SeqID of the root packet when converting JSON to Y3, it
+	// also selects the root message if Message is not set
+	SeqID byte
+	// Typed means the primitives are typed, they are decoded by their type
+	// codes, and encoded with type codes
+	Typed bool
+}
+
+// Encode converts a Y3 encoded node packet to JSON
+func Encode(buf []byte, opts *Options) ([]byte, error) {
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	if err := newEncodeState(w, opts).encode(buf); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Decode converts a JSON object to a Y3 encoded node packet
+func Decode(data []byte, opts *Options) ([]byte, error) {
+	d := NewDecoder(bytes.NewReader(data), opts)
+	buf, err := d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.dec.Token(); err != io.EOF {
+		return nil, errTrailingData
+	}
+	return buf, nil
+}
+
+// Encoder writes the JSON of packets to an io.Writer, one JSON object per line
+type Encoder struct {
+	w     *bufio.Writer
+	state *encodeState
+}
+
+// NewEncoder returns an Encoder writing to w
+func NewEncoder(w io.Writer, opts *Options) *Encoder {
+	bw := bufio.NewWriter(w)
+	return &Encoder{w: bw, state: newEncodeState(bw, opts)}
+}
+
+// Encode writes the JSON of the Y3 encoded node packet followed by a newline,
+// the packet is decoded as a whole before it's written
+func (e *Encoder) Encode(buf []byte) error {
+	if err := e.state.encode(buf); err != nil {
+		return err
+	}
+	if err := e.w.WriteByte('\n'); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// Decoder reads JSON objects from an io.Reader and converts them to Y3 packets
+type Decoder struct {
+	dec  *json.Decoder
+	opts *Options
+}
+
+// NewDecoder returns a Decoder reading from r, the objects can be separated by
+// whitespaces
+func NewDecoder(r io.Reader, opts *Options) *Decoder {
+	if opts == nil {
+		opts = &Options{}
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &Decoder{dec: dec, opts: opts}
+}
+
+// Decode reads the next JSON object and returns the Y3 encoded node packet, it
+// returns io.EOF if there is no more object. The JSON is read token by token,
+// so the children keep the order of the keys.
+func (d *Decoder) Decode() ([]byte, error) {
+	return d.decode()
+}
@@ -0,0 +1,248 @@
+package y3json
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/y3"
+	"github.com/yomorun/y3/schema"
+)
+
+const testSchema = `
+package demo;
+
+message Frame = 0x3F {
+    string id = 0x01;
+    optional int32 retry = 0x02;
+    Meta meta = 0x03;
+    repeated string tags = 0x04;
+    repeated Route routes = 0x05;
+    bytes payload = 0x06;
+    Status status = 0x07;
+    time at = 0x08;
+    float64 temp = 0x09;
+    uint64 count = 0x0A;
+    bool ok = 0x0B;
+}
+
+message Meta {
+    string name = 0x01;
+}
+
+message Route {
+    string host = 0x01;
+}
+
+enum Status {
+    OK = 0;
+    NOT_FOUND = 404;
+}
+`
+
+type testMeta struct {
+	Name string `y3:"0x01"`
+}
+
+type testRoute struct {
+	Host string `y3:"0x01"`
+}
+
+type testFrame struct {
+	ID      string      `y3:"0x01"`
+	Retry   *int32      `y3:"0x02"`
+	Meta    testMeta    `y3:"0x03"`
+	Tags    []string    `y3:"0x04"`
+	Routes  []testRoute `y3:"0x05"`
+	Payload []byte      `y3:"0x06"`
+	Status  int32       `y3:"0x07"`
+	At      time.Time   `y3:"0x08"`
+	Temp    float64     `y3:"0x09"`
+	Count   uint64      `y3:"0x0A"`
+	OK      bool        `y3:"0x0B"`
+	Extra   int32       `y3:"0x10"`
+}
+
+func mustSchema(t *testing.T) *schema.Schema {
+	s, err := schema.Parse([]byte(testSchema))
+	assert.NoError(t, err)
+	return s
+}
+
+const testJSON = `{"id":"yomo","retry":null,"meta":{"name":"edge"},"tags":["a","b"],"routes":[{"host":"h1"}],` +
+	`"payload":"AQID","status":"NOT_FOUND","at":"2021-04-01T07:40:00Z","temp":36.5,"count":18446744073709551615,"ok":true,"0x10":"Bw=="}`
+
+func TestSchemaRoundTrip(t *testing.T) {
+	frame := testFrame{
+		ID:      "yomo",
+		Meta:    testMeta{Name: "edge"},
+		Tags:    []string{"a", "b"},
+		Routes:  []testRoute{{Host: "h1"}},
+		Payload: []byte{0x01, 0x02, 0x03},
+		Status:  404,
+		At:      time.Unix(1617262800, 0).UTC(),
+		Temp:    36.5,
+		Count:   1<<64 - 1,
+		OK:      true,
+		Extra:   7,
+	}
+	buf, err := y3.Marshal(0x3F, &frame)
+	assert.NoError(t, err)
+
+	opts := &Options{Schema: mustSchema(t), SeqID: 0x3F}
+	res, err := Encode(buf, opts)
+	assert.NoError(t, err)
+	// 0x10 is not in the schema, it's keyed by SeqID and base64 encoded
+	assert.Equal(t, testJSON, string(res))
+
+	res, err = Decode(res, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, buf, res)
+
+	var decoded testFrame
+	assert.NoError(t, y3.Unmarshal(res, &decoded))
+	assert.True(t, frame.At.Equal(decoded.At))
+	decoded.At = frame.At
+	assert.Equal(t, frame, decoded)
+}
+
+func TestRedacted(t *testing.T) {
+	src := testSchema
+	for _, field := range []string{"string id = 0x01", "Meta meta = 0x03", "bytes payload = 0x06", "Status status = 0x07", "float64 temp = 0x09"} {
+		src = strings.Replace(src, field, field+" [redact]", 1)
+	}
+	s, err := schema.Parse([]byte(src))
+	assert.NoError(t, err)
+	want := `{"id":"[REDACTED]","retry":null,"meta":{"name":"[REDACTED]"},"tags":["a","b"],"routes":[{"host":"h1"}],` +
+		`"payload":"W1JFREFDVEVEXQ==","status":null,"at":"2021-04-01T07:40:00Z","temp":null,"count":18446744073709551615,"ok":true,"0x10":"Bw=="}`
+
+	for _, typed := range []bool{false, true} {
+		opts := &Options{Schema: mustSchema(t), SeqID: 0x3F, Typed: typed}
+		buf, err := Decode([]byte(testJSON), opts)
+		assert.NoError(t, err)
+		r := schema.NewRedactor(s)
+		r.Typed = typed
+		buf, err = r.Redact(buf)
+		assert.NoError(t, err)
+		res, err := Encode(buf, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(res), "typed %v", typed)
+	}
+}
+
+func TestEnumValue(t *testing.T) {
+	opts := &Options{Schema: mustSchema(t), SeqID: 0x3F}
+	buf, err := Decode([]byte(`{"status":404}`), opts)
+	assert.NoError(t, err)
+	res, err := Encode(buf, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"status":"NOT_FOUND"}`, string(res))
+
+	// the values without names are kept as numbers
+	buf, err = Decode([]byte(`{"status":500}`), opts)
+	assert.NoError(t, err)
+	res, err = Encode(buf, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"status":500}`, string(res))
+}
+
+func TestTyped(t *testing.T) {
+	opts := &Options{Typed: true, SeqID: 0x30}
+	data := `{"0x01":"yomo","0x02":-1,"0x03":1.5,"0x04":true,"0x05":[1,2],"0x06":{"0x01":null}}`
+	buf, err := Decode([]byte(data), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0xB0), buf[0])
+
+	res, err := Encode(buf, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, data, string(res))
+}
+
+func TestUntyped(t *testing.T) {
+	data := `{"0x01":"eW9tbw==","0x02":[{"0x01":"AQ=="}]}`
+	buf, err := Decode([]byte(data), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x80, 0x0D, 0x01, 0x04, 'y', 'o', 'm', 'o', 0xC2, 0x05, 0x80, 0x03, 0x01, 0x01, 0x01}, buf)
+
+	res, err := Encode(buf, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, data, string(res))
+
+	// empty is not null
+	data = `{"0x01":"","0x02":null}`
+	buf, err = Decode([]byte(data), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x80, 0x04, 0x01, 0x00, 0x42, 0x00}, buf)
+	res, err = Encode(buf, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, data, string(res))
+
+	opts := &Options{Schema: mustSchema(t), SeqID: 0x3F}
+	buf, err = Decode([]byte(`{"payload":"","id":""}`), opts)
+	assert.NoError(t, err)
+	res, err = Encode(buf, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"payload":"","id":""}`, string(res))
+}
+
+func TestStream(t *testing.T) {
+	var b bytes.Buffer
+	enc := NewEncoder(&b, &Options{Typed: true})
+	for _, data := range []string{`{"0x01":1}`, `{"0x01":"a"}`} {
+		buf, err := Decode([]byte(data), &Options{Typed: true})
+		assert.NoError(t, err)
+		assert.NoError(t, enc.Encode(buf))
+	}
+	assert.Equal(t, "{\"0x01\":1}\n{\"0x01\":\"a\"}\n", b.String())
+
+	dec := NewDecoder(strings.NewReader(b.String()), &Options{Typed: true})
+	var n int
+	for {
+		_, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		n++
+	}
+	assert.Equal(t, 2, n)
+}
+
+func TestErrors(t *testing.T) {
+	opts := &Options{Schema: mustSchema(t), SeqID: 0x3F}
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`[]`, "y3json: expected JSON object, found ["},
+		{`{"name":"a"}`, `y3json: unknown field "name" of Frame`},
+		{`{"0x40":"a"}`, `y3json: unknown field "0x40" of Frame`},
+		{`{"id":1}`, "y3json: invalid string value 1 of id"},
+		{`{"tags":"a"}`, "y3json: tags should be an array, found a"},
+		{`{"meta":"a"}`, "y3json: meta should be an object, found a"},
+		{`{"id":{}}`, "y3json: id should not be an object"},
+		{`{"status":"UNKNOWN"}`, "y3json: invalid Status value UNKNOWN of status"},
+		{`{"retry":2147483648}`, "y3json: invalid int32 value 2147483648 of retry"},
+		{`{"payload":"!"}`, "y3json: invalid bytes value ! of payload"},
+		{`{} {}`, "y3json: invalid data after top-level value"},
+	}
+	for _, tt := range tests {
+		_, err := Decode([]byte(tt.data), opts)
+		if assert.Error(t, err, tt.data) {
+			assert.Equal(t, tt.err, err.Error(), tt.data)
+		}
+	}
+
+	_, err := Decode([]byte(`{"id":"a"}`), nil)
+	assert.EqualError(t, err, `y3json: key "id" should be a SeqID like 0x01`)
+	_, err = Decode([]byte(`{"0x01":"!"}`), nil)
+	assert.EqualError(t, err, `y3json: untyped value "!" should be base64 encoded`)
+
+	_, err = Encode([]byte{0xBF, 0x03, 0x03, 0x01, 0x01}, opts)
+	assert.EqualError(t, err, "y3json: meta (0x3) should be a node packet, got primitive")
+	_, err = Encode([]byte{0xBF, 0x00}, &Options{Schema: opts.Schema, Message: "Unknown"})
+	assert.EqualError(t, err, "y3json: message Unknown is not found")
+}
@@ -0,0 +1,244 @@
+package y3msgpack
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yomorun/y3/internal/bridge"
+)
+
+// maxDepth limits the nesting of arrays and maps
+const maxDepth = 512
+
+var errUnexpectedEnd = errors.New("y3msgpack: unexpected end of data")
+
+type decoder struct {
+	data  []byte
+	off   int
+	depth int
+}
+
+// errorf returns the error at the current offset
+func (d *decoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("y3msgpack: offset %d: %s", d.off, fmt.Sprintf(format, args...))
+}
+
+func (d *decoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.off) {
+		return nil, errUnexpectedEnd
+	}
+	b := d.data[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
+
+// uint reads a big-endian unsigned integer of size bytes
+func (d *decoder) uint(size int) (uint64, error) {
+	buf, err := d.bytes(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	return bigEndian(buf), nil
+}
+
+func bigEndian(buf []byte) uint64 {
+	var n uint64
+	for _, c := range buf {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
+
+func (d *decoder) value() (any, error) {
+	start := d.off
+	if d.off >= len(d.data) {
+		return nil, errUnexpectedEnd
+	}
+	b := d.data[d.off]
+	d.off++
+
+	switch {
+	case b <= 0x7F:
+		return uint64(b), nil
+	case b >= 0xE0:
+		return int64(int8(b)), nil
+	case b <= 0x8F:
+		return d.mapValue(uint64(b & 0x0F))
+	case b <= 0x9F:
+		return d.array(uint64(b & 0x0F))
+	case b <= 0xBF:
+		return d.str(start, uint64(b&0x1F))
+	}
+
+	switch b {
+	case 0xC0:
+		return nil, nil
+	case 0xC2:
+		return false, nil
+	case 0xC3:
+		return true, nil
+	case 0xC4, 0xC5, 0xC6:
+		n, err := d.uint(1 << (b - 0xC4))
+		if err != nil {
+			return nil, err
+		}
+		buf, err := d.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), buf...), nil
+	case 0xC7, 0xC8, 0xC9:
+		n, err := d.uint(1 << (b - 0xC7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(start, n)
+	case 0xCA:
+		n, err := d.uint(4)
+		return math.Float32frombits(uint32(n)), err
+	case 0xCB:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xCC, 0xCD, 0xCE, 0xCF:
+		return d.uint(1 << (b - 0xCC))
+	case 0xD0, 0xD1, 0xD2, 0xD3:
+		size := 1 << (b - 0xD0)
+		n, err := d.uint(size)
+		// sign extend
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, err
+	case 0xD4, 0xD5, 0xD6, 0xD7, 0xD8:
+		return d.ext(start, 1<<(b-0xD4))
+	case 0xD9, 0xDA, 0xDB:
+		n, err := d.uint(1 << (b - 0xD9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(start, n)
+	case 0xDC, 0xDD:
+		n, err := d.uint(2 << (b - 0xDC))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(n)
+	case 0xDE, 0xDF:
+		n, err := d.uint(2 << (b - 0xDE))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapValue(n)
+	}
+	d.off = start
+	return nil, d.errorf("invalid format %#x", b)
+}
+
+func (d *decoder) str(start int, n uint64) (string, error) {
+	buf, err := d.bytes(n)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(buf) {
+		d.off = start
+		return "", d.errorf("invalid UTF-8 string")
+	}
+	return string(buf), nil
+}
+
+// nest increases the depth of arrays and maps, the returned func decreases it
+func (d *decoder) nest() (func(), error) {
+	if d.depth++; d.depth > maxDepth {
+		return nil, d.errorf("exceeded max depth %d", maxDepth)
+	}
+	return func() { d.depth-- }, nil
+}
+
+func (d *decoder) array(n uint64) ([]any, error) {
+	done, err := d.nest()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	a := []any{}
+	for i := uint64(0); i < n; i++ {
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+func (d *decoder) mapValue(n uint64) (bridge.Map, error) {
+	done, err := d.nest()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	m := bridge.Map{}
+	for i := uint64(0); i < n; i++ {
+		start := d.off
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		var key uint64
+		switch k := k.(type) {
+		case uint64:
+			key = k
+		case int64:
+			if k < 0 {
+				d.off = start
+				return nil, d.errorf("map key should be a non-negative integer")
+			}
+			key = uint64(k)
+		default:
+			d.off = start
+			return nil, d.errorf("map key should be a non-negative integer")
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, bridge.Entry{Key: key, Value: v})
+	}
+	return m, nil
+}
+
+// ext reads the extension of n bytes data, only timestamp is supported
+func (d *decoder) ext(start int, n uint64) (any, error) {
+	typ, err := d.uint(1)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := d.bytes(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ) != extTimestamp {
+		d.off = start
+		return nil, d.errorf("unsupported extension type %d", int8(typ))
+	}
+
+	var sec, nsec uint64
+	switch n {
+	case 4:
+		sec = bigEndian(buf)
+	case 8:
+		v := bigEndian(buf)
+		sec, nsec = v&(1<<34-1), v>>34
+	case 12:
+		sec, nsec = bigEndian(buf[4:]), bigEndian(buf[:4])
+	default:
+		d.off = start
+		return nil, d.errorf("invalid timestamp length %d", n)
+	}
+	if nsec >= 1e9 {
+		d.off = start
+		return nil, d.errorf("invalid timestamp nanoseconds %d", nsec)
+	}
+	return time.Unix(int64(sec), int64(nsec)), nil
+}
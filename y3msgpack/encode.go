@@ -0,0 +1,137 @@
+package y3msgpack
+
+import (
+	"math"
+	"time"
+
+	"github.com/yomorun/y3/internal/bridge"
+)
+
+// extTimestamp is the extension type of timestamp
+const extTimestamp = -1
+
+type encoder struct {
+	buf []byte
+}
+
+// uint writes n in big-endian with size bytes
+func (e *encoder) uint(n uint64, size int) {
+	for i := size - 1; i >= 0; i-- {
+		e.buf = append(e.buf, byte(n>>(8*i)))
+	}
+}
+
+// head writes the format of maps, arrays, strings and bins: fix is the fix
+// format with max length fixMax, or 0 if there's none, formats are the 8, 16
+// and 32 bits formats, 0 if there's none
+func (e *encoder) head(n int, fix byte, fixMax int, formats [3]byte) {
+	switch {
+	case fix != 0 && n <= fixMax:
+		e.buf = append(e.buf, fix|byte(n))
+	case formats[0] != 0 && n <= math.MaxUint8:
+		e.buf = append(e.buf, formats[0], byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, formats[1])
+		e.uint(uint64(n), 2)
+	default:
+		e.buf = append(e.buf, formats[2])
+		e.uint(uint64(n), 4)
+	}
+}
+
+func (e *encoder) value(v any) {
+	switch v := v.(type) {
+	case bridge.Map:
+		e.head(len(v), 0x80, 15, [3]byte{0, 0xDE, 0xDF})
+		for _, entry := range v {
+			e.uint64(entry.Key)
+			e.value(entry.Value)
+		}
+	case []any:
+		e.head(len(v), 0x90, 15, [3]byte{0, 0xDC, 0xDD})
+		for _, elem := range v {
+			e.value(elem)
+		}
+	case nil:
+		e.buf = append(e.buf, 0xC0)
+	case bool:
+		if v {
+			e.buf = append(e.buf, 0xC3)
+		} else {
+			e.buf = append(e.buf, 0xC2)
+		}
+	case int64:
+		e.int64(v)
+	case uint64:
+		e.uint64(v)
+	case float32:
+		e.buf = append(e.buf, 0xCA)
+		e.uint(uint64(math.Float32bits(v)), 4)
+	case float64:
+		e.buf = append(e.buf, 0xCB)
+		e.uint(math.Float64bits(v), 8)
+	case string:
+		e.head(len(v), 0xA0, 31, [3]byte{0xD9, 0xDA, 0xDB})
+		e.buf = append(e.buf, v...)
+	case []byte:
+		e.head(len(v), 0, 0, [3]byte{0xC4, 0xC5, 0xC6})
+		e.buf = append(e.buf, v...)
+	case time.Time:
+		e.timestamp(v)
+	}
+}
+
+func (e *encoder) uint64(n uint64) {
+	switch {
+	case n <= 0x7F:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xCC, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xCD)
+		e.uint(n, 2)
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, 0xCE)
+		e.uint(n, 4)
+	default:
+		e.buf = append(e.buf, 0xCF)
+		e.uint(n, 8)
+	}
+}
+
+func (e *encoder) int64(n int64) {
+	switch {
+	case n >= 0:
+		e.uint64(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, 0xD0, byte(n))
+	case n >= math.MinInt16:
+		e.buf = append(e.buf, 0xD1)
+		e.uint(uint64(n), 2)
+	case n >= math.MinInt32:
+		e.buf = append(e.buf, 0xD2)
+		e.uint(uint64(n), 4)
+	default:
+		e.buf = append(e.buf, 0xD3)
+		e.uint(uint64(n), 8)
+	}
+}
+
+// timestamp writes the shortest of timestamp 32, 64 and 96 formats
+func (e *encoder) timestamp(t time.Time) {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case sec >= 0 && sec <= math.MaxUint32 && nsec == 0:
+		e.buf = append(e.buf, 0xD6, byte(extTimestamp&0xFF))
+		e.uint(uint64(sec), 4)
+	case sec >= 0 && sec < 1<<34:
+		e.buf = append(e.buf, 0xD7, byte(extTimestamp&0xFF))
+		e.uint(nsec<<34|uint64(sec), 8)
+	default:
+		e.buf = append(e.buf, 0xC7, 12, byte(extTimestamp&0xFF))
+		e.uint(nsec, 4)
+		e.uint(uint64(sec), 8)
+	}
+}
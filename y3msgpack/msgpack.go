@@ -0,0 +1,65 @@
+// Package y3msgpack converts Y3 packets to MessagePack and back.
+//
+// A node packet is converted to a map with integer keys of the SeqIDs, a slice
+// packet to an array and a null to nil. Typed primitives (see
+// y3.NewTypedPrimitivePacketEncoder) are converted to the MessagePack values
+// of their types, time is the timestamp extension type, and untyped primitives
+// are bin of the encoded Val.
+//
+// When converting MessagePack to Y3, integers are encoded as int32 if they
+// fit, or as int64, or uint64. Without Typed, a bin is taken as the encoded
+// Val, and other values are encoded by the Set*Value methods of their types.
+package y3msgpack
+
+import (
+	"fmt"
+
+	"github.com/yomorun/y3/internal/bridge"
+)
+
+// Options describes how the packets are converted, nil is the same as the zero value
+type Options struct {
+	// SeqID is the SeqID of the root packet when converting MessagePack to Y3
+	SeqID byte
+	// Typed means the primitives are typed, they are decoded by their type
+	// codes, and encoded with type codes
+	Typed bool
+}
+
+// Encode converts a Y3 encoded node packet to MessagePack
+func Encode(buf []byte, opts *Options) ([]byte, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	m, err := bridge.FromPacket(buf, opts.Typed)
+	if err != nil {
+		return nil, fmt.Errorf("y3msgpack: %w", err)
+	}
+	var e encoder
+	e.value(m)
+	return e.buf, nil
+}
+
+// Decode converts a MessagePack map to a Y3 encoded node packet
+func Decode(data []byte, opts *Options) ([]byte, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	d := &decoder{data: data}
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if d.off < len(d.data) {
+		return nil, fmt.Errorf("y3msgpack: invalid data after top-level value at offset %d", d.off)
+	}
+	m, ok := v.(bridge.Map)
+	if !ok {
+		return nil, fmt.Errorf("y3msgpack: expected map, found %T", v)
+	}
+	buf, err := bridge.ToPacket(opts.SeqID, m, opts.Typed)
+	if err != nil {
+		return nil, fmt.Errorf("y3msgpack: %w", err)
+	}
+	return buf, nil
+}
@@ -0,0 +1,117 @@
+package y3msgpack
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/y3"
+)
+
+func TestTypedRoundTrip(t *testing.T) {
+	// {1: "yomo", 2: -1, 3: [1, 1000000], 4: {1: true}, 5: nil, 6: 1.5, 7: bin 0102, 8: -200, 9: float32 1.5}
+	data := []byte{
+		0x89,
+		0x01, 0xA4, 'y', 'o', 'm', 'o',
+		0x02, 0xFF,
+		0x03, 0x92, 0x01, 0xCE, 0x00, 0x0F, 0x42, 0x40,
+		0x04, 0x81, 0x01, 0xC3,
+		0x05, 0xC0,
+		0x06, 0xCB, 0x3F, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x07, 0xC4, 0x02, 0x01, 0x02,
+		0x08, 0xD1, 0xFF, 0x38,
+		0x09, 0xCA, 0x3F, 0xC0, 0x00, 0x00,
+	}
+	opts := &Options{SeqID: 0x30, Typed: true}
+	buf, err := Decode(data, opts)
+	assert.NoError(t, err)
+
+	v, err := y3.ParseTyped(buf)
+	assert.NoError(t, err)
+	n := v.(*y3.NodeValue)
+	assert.Equal(t, byte(0x30), n.SeqID())
+	assert.Equal(t, y3.KindInt32, n.Get(0x08).(*y3.PrimitiveValue).Kind())
+	assert.Equal(t, y3.KindFloat32, n.Get(0x09).(*y3.PrimitiveValue).Kind())
+
+	res, err := Encode(buf, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, data, res)
+}
+
+func TestUntyped(t *testing.T) {
+	// {1: "yomo", 2: -1}
+	data := []byte{0x82, 0x01, 0xA4, 'y', 'o', 'm', 'o', 0x02, 0xFF}
+	buf, err := Decode(data, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x80, 0x09, 0x01, 0x04, 'y', 'o', 'm', 'o', 0x02, 0x01, 0xFF}, buf)
+
+	res, err := Encode(buf, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x82, 0x01, 0xC4, 0x04, 'y', 'o', 'm', 'o', 0x02, 0xC4, 0x01, 0xFF}, res)
+}
+
+func TestEncodeValues(t *testing.T) {
+	tests := []struct {
+		v    any
+		want []byte
+	}{
+		{int64(-33), []byte{0xD0, 0xDF}},
+		{int64(math.MinInt32), []byte{0xD2, 0x80, 0x00, 0x00, 0x00}},
+		{int64(math.MinInt64), []byte{0xD3, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{uint64(200), []byte{0xCC, 0xC8}},
+		{uint64(math.MaxUint64), []byte{0xCF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{string(make([]byte, 32)), append([]byte{0xD9, 0x20}, make([]byte, 32)...)},
+		{[]any{}, []byte{0x90}},
+		{time.Unix(1, 0), []byte{0xD6, 0xFF, 0x00, 0x00, 0x00, 0x01}},
+		{time.Unix(1, 1), []byte{0xD7, 0xFF, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01}},
+		{time.Unix(-1, 0), []byte{0xC7, 0x0C, 0xFF, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+	}
+	for _, tt := range tests {
+		var e encoder
+		e.value(tt.v)
+		assert.Equal(t, tt.want, e.buf, "%v", tt.v)
+
+		d := &decoder{data: e.buf}
+		v, err := d.value()
+		assert.NoError(t, err)
+		if want, ok := tt.v.(time.Time); ok {
+			assert.True(t, want.Equal(v.(time.Time)), "%v", tt.v)
+		} else if i, ok := tt.v.(int64); ok && i >= 0 {
+			assert.Equal(t, uint64(i), v)
+		} else {
+			assert.Equal(t, tt.v, v)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		data []byte
+		err  string
+	}{
+		{[]byte{}, "y3msgpack: unexpected end of data"},
+		{[]byte{0x81, 0x01}, "y3msgpack: unexpected end of data"},
+		{[]byte{0xC6, 0xFF, 0xFF, 0xFF, 0xFF}, "y3msgpack: unexpected end of data"},
+		{[]byte{0x01}, "y3msgpack: expected map, found uint64"},
+		{[]byte{0x80, 0x00}, "y3msgpack: invalid data after top-level value at offset 1"},
+		{[]byte{0x81, 0xA1, 'a', 0x01}, "y3msgpack: offset 1: map key should be a non-negative integer"},
+		{[]byte{0x81, 0xFF, 0x01}, "y3msgpack: offset 1: map key should be a non-negative integer"},
+		{[]byte{0x81, 0x40, 0x01}, "y3msgpack: key 64 is not a SeqID"},
+		{[]byte{0x81, 0x01, 0xC1}, "y3msgpack: offset 2: invalid format 0xc1"},
+		{[]byte{0x81, 0x01, 0xD4, 0x01, 0x00}, "y3msgpack: offset 2: unsupported extension type 1"},
+		{[]byte{0x81, 0x01, 0xD5, 0xFF, 0x00, 0x00}, "y3msgpack: offset 2: invalid timestamp length 2"},
+		{[]byte{0x81, 0x01, 0xA1, 0xFF}, "y3msgpack: offset 2: invalid UTF-8 string"},
+	}
+	for _, tt := range tests {
+		_, err := Decode(tt.data, nil)
+		assert.EqualError(t, err, tt.err, "%x", tt.data)
+	}
+
+	deep := []byte{0x81, 0x01}
+	for i := 0; i < 600; i++ {
+		deep = append(deep, 0x91)
+	}
+	_, err := Decode(deep, nil)
+	assert.Contains(t, err.Error(), "exceeded max depth 512")
+}
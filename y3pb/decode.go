@@ -0,0 +1,125 @@
+package y3pb
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/yomorun/y3"
+	"github.com/yomorun/y3/internal/bridge"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// decoder builds the Y3 packets of protobuf messages
+type decoder struct {
+	typed bool
+}
+
+// message returns the node of m, the fields are in the order of numbers
+func (d *decoder) message(sid byte, m protoreflect.Message) (*y3.NodeValue, error) {
+	var fields []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		fields = append(fields, fd)
+		return true
+	})
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Number() < fields[j].Number() })
+
+	n := y3.NewNode(sid)
+	for _, fd := range fields {
+		v, err := d.field(fd, m.Get(fd))
+		if err != nil {
+			return nil, err
+		}
+		n.Set(v)
+	}
+	return n, nil
+}
+
+func (d *decoder) field(fd protoreflect.FieldDescriptor, v protoreflect.Value) (y3.Value, error) {
+	sid := byte(fd.Number())
+	switch {
+	case fd.IsList():
+		list := v.List()
+		s := y3.NewSlice(sid)
+		for i := 0; i < list.Len(); i++ {
+			elem, err := d.value(y3.SliceElementSeqID, fd, list.Get(i))
+			if err != nil {
+				return nil, err
+			}
+			s.Append(elem)
+		}
+		return s, nil
+	case fd.IsMap():
+		return d.mapValue(sid, fd, v.Map())
+	}
+	return d.value(sid, fd, v)
+}
+
+// mapValue returns the map packet, the entries are sorted by key
+func (d *decoder) mapValue(sid byte, fd protoreflect.FieldDescriptor, m protoreflect.Map) (y3.Value, error) {
+	var keys []protoreflect.MapKey
+	m.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+
+	s := y3.NewSlice(sid)
+	for _, k := range keys {
+		key, err := d.value(y3.MapKeySeqID, fd.MapKey(), k.Value())
+		if err != nil {
+			return nil, err
+		}
+		val, err := d.value(y3.MapValueSeqID, fd.MapValue(), m.Get(k))
+		if err != nil {
+			return nil, err
+		}
+		s.Append(y3.NewNode(y3.MapEntrySeqID, key, val))
+	}
+	return s, nil
+}
+
+func lessKey(a, b protoreflect.MapKey) bool {
+	switch a.Interface().(type) {
+	case string:
+		return a.String() < b.String()
+	case bool:
+		return !a.Bool() && b.Bool()
+	case int32, int64:
+		return a.Int() < b.Int()
+	}
+	return a.Uint() < b.Uint()
+}
+
+// value returns the packet of a singular value
+func (d *decoder) value(sid byte, fd protoreflect.FieldDescriptor, v protoreflect.Value) (y3.Value, error) {
+	if fd.Message() != nil {
+		return d.message(sid, v.Message())
+	}
+
+	var x any
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		x = int32(v.Int())
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		x = v.Int()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		x = uint32(v.Uint())
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		x = v.Uint()
+	case protoreflect.FloatKind:
+		x = float32(v.Float())
+	case protoreflect.DoubleKind:
+		x = v.Float()
+	case protoreflect.BoolKind:
+		x = v.Bool()
+	case protoreflect.StringKind:
+		x = v.String()
+	case protoreflect.BytesKind:
+		x = v.Bytes()
+	case protoreflect.EnumKind:
+		x = int32(v.Enum())
+	default:
+		return nil, fmt.Errorf("y3pb: unsupported kind %v of field %s", fd.Kind(), fd.FullName())
+	}
+	return bridge.Primitive(sid, x, d.typed)
+}
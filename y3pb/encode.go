@@ -0,0 +1,159 @@
+package y3pb
+
+import (
+	"fmt"
+
+	"github.com/yomorun/y3"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func encode(buf []byte, m protoreflect.Message, typed bool) error {
+	parse := y3.Parse
+	if typed {
+		parse = y3.ParseTyped
+	}
+	v, err := parse(buf)
+	if err != nil {
+		return err
+	}
+	n, ok := v.(*y3.NodeValue)
+	if !ok {
+		return fmt.Errorf("y3pb: packet %#x is not a node packet", v.SeqID())
+	}
+	return message(n, m)
+}
+
+// message sets the fields of m from the children of n
+func message(n *y3.NodeValue, m protoreflect.Message) error {
+	desc := m.Descriptor()
+	for _, child := range n.Children() {
+		fd := desc.Fields().ByNumber(protoreflect.FieldNumber(child.SeqID()))
+		if fd == nil {
+			return fmt.Errorf("y3pb: unknown field %#x of %s", child.SeqID(), desc.FullName())
+		}
+		if p, ok := child.(*y3.PrimitiveValue); ok && p.IsNull() {
+			continue
+		}
+		if err := field(child, fd, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func field(v y3.Value, fd protoreflect.FieldDescriptor, m protoreflect.Message) error {
+	switch {
+	case fd.IsList():
+		s, err := checkKind[*y3.SliceValue](v, fd, "slice")
+		if err != nil {
+			return err
+		}
+		list := m.Mutable(fd).List()
+		for _, elem := range s.Elems() {
+			x, err := value(elem, fd, list.NewElement)
+			if err != nil {
+				return err
+			}
+			list.Append(x)
+		}
+		return nil
+	case fd.IsMap():
+		s, err := checkKind[*y3.SliceValue](v, fd, "map")
+		if err != nil {
+			return err
+		}
+		return mapValue(s, fd, m.Mutable(fd).Map())
+	}
+	x, err := value(v, fd, func() protoreflect.Value { return m.NewField(fd) })
+	if err != nil {
+		return err
+	}
+	m.Set(fd, x)
+	return nil
+}
+
+func mapValue(s *y3.SliceValue, fd protoreflect.FieldDescriptor, m protoreflect.Map) error {
+	for _, elem := range s.Elems() {
+		entry, ok := elem.(*y3.NodeValue)
+		if !ok || entry.Get(y3.MapKeySeqID) == nil || entry.Get(y3.MapValueSeqID) == nil {
+			return fmt.Errorf("y3pb: invalid entry of map field %s", fd.FullName())
+		}
+		k, err := value(entry.Get(y3.MapKeySeqID), fd.MapKey(), nil)
+		if err != nil {
+			return err
+		}
+		x, err := value(entry.Get(y3.MapValueSeqID), fd.MapValue(), m.NewValue)
+		if err != nil {
+			return err
+		}
+		m.Set(k.MapKey(), x)
+	}
+	return nil
+}
+
+// value returns the singular value of fd, newMessage creates the value of
+// message fields
+func value(v y3.Value, fd protoreflect.FieldDescriptor, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	if fd.Message() != nil {
+		n, err := checkKind[*y3.NodeValue](v, fd, "node")
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		x := newMessage()
+		return x, message(n, x.Message())
+	}
+
+	p, err := checkKind[*y3.PrimitiveValue](v, fd, "primitive")
+	if err != nil {
+		return protoreflect.Value{}, err
+	}
+	var x protoreflect.Value
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		x, err = decode(p, protoreflect.ValueOfInt32)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		x, err = decode(p, protoreflect.ValueOfInt64)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		x, err = decode(p, protoreflect.ValueOfUint32)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		x, err = decode(p, protoreflect.ValueOfUint64)
+	case protoreflect.FloatKind:
+		x, err = decode(p, protoreflect.ValueOfFloat32)
+	case protoreflect.DoubleKind:
+		x, err = decode(p, protoreflect.ValueOfFloat64)
+	case protoreflect.BoolKind:
+		x, err = decode(p, protoreflect.ValueOfBool)
+	case protoreflect.StringKind:
+		x, err = decode(p, protoreflect.ValueOfString)
+	case protoreflect.BytesKind:
+		x, err = decode(p, protoreflect.ValueOfBytes)
+	case protoreflect.EnumKind:
+		x, err = decode(p, func(v int32) protoreflect.Value {
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v))
+		})
+	default:
+		return x, fmt.Errorf("y3pb: unsupported kind %v of field %s", fd.Kind(), fd.FullName())
+	}
+	if err != nil {
+		return x, fmt.Errorf("y3pb: field %s: %w", fd.FullName(), err)
+	}
+	return x, nil
+}
+
+// decode decodes the primitive as T and converts it by valueOf
+func decode[T any](p *y3.PrimitiveValue, valueOf func(T) protoreflect.Value) (protoreflect.Value, error) {
+	var v T
+	if err := p.Decode(&v); err != nil {
+		return protoreflect.Value{}, err
+	}
+	return valueOf(v), nil
+}
+
+// checkKind checks the packet of fd is a T
+func checkKind[T y3.Value](v y3.Value, fd protoreflect.FieldDescriptor, want string) (T, error) {
+	x, ok := v.(T)
+	if !ok {
+		return x, fmt.Errorf("y3pb: field %s (%#x) should be a %s packet", fd.FullName(), fd.Number(), want)
+	}
+	return x, nil
+}
@@ -0,0 +1,96 @@
+// Package y3pb translates protobuf wire bytes to Y3 packets and back with the
+// descriptor of the message, so the services can be migrated from protobuf
+// gradually.
+//
+// The field numbers are the SeqIDs, so they must not be greater than 0x3F. A
+// message is a node packet, a repeated field, packed or not, is a slice packet,
+// and a map field is a map packet (see y3.MapEntrySeqID). The scalars are
+// encoded by the Set*Value methods of their types: sint32 and sfixed32 are
+// int32, fixed32 is uint32, and so on, enums are int32.
+//
+// The fields not set are omitted, and so are the unknown fields of protobuf.
+// A null packet leaves the field unset.
+package y3pb
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Options describes how the packets are translated, nil is the same as the zero value
+type Options struct {
+	// SeqID is the SeqID of the root packet when translating protobuf to Y3
+	SeqID byte
+	// Typed means the primitives are typed, they are decoded by their type
+	// codes, and encoded with type codes
+	Typed bool
+}
+
+// Decode translates the protobuf wire bytes of the message desc to a Y3
+// encoded node packet
+func Decode(data []byte, desc protoreflect.MessageDescriptor, opts *Options) ([]byte, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if err := checkDescriptor(desc, map[protoreflect.FullName]bool{}); err != nil {
+		return nil, err
+	}
+	m := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("y3pb: %w", err)
+	}
+	n, err := (&decoder{typed: opts.Typed}).message(opts.SeqID, m)
+	if err != nil {
+		return nil, err
+	}
+	return n.Encode(), nil
+}
+
+// Encode translates the Y3 encoded node packet to the protobuf wire bytes of
+// the message desc, the output is deterministic
+func Encode(buf []byte, desc protoreflect.MessageDescriptor, opts *Options) ([]byte, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if err := checkDescriptor(desc, map[protoreflect.FullName]bool{}); err != nil {
+		return nil, err
+	}
+	m := dynamicpb.NewMessage(desc)
+	if err := encode(buf, m, opts.Typed); err != nil {
+		return nil, err
+	}
+	res, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("y3pb: %w", err)
+	}
+	return res, nil
+}
+
+// checkDescriptor checks the field numbers of the message and the nested
+// messages can be SeqIDs
+func checkDescriptor(desc protoreflect.MessageDescriptor, checked map[protoreflect.FullName]bool) error {
+	if checked[desc.FullName()] {
+		return nil
+	}
+	checked[desc.FullName()] = true
+
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Number() > 0x3F {
+			return fmt.Errorf("y3pb: number %d of field %s is greater than 63", fd.Number(), fd.FullName())
+		}
+		if fd.IsMap() {
+			fd = fd.MapValue()
+		}
+		if md := fd.Message(); md != nil {
+			if err := checkDescriptor(md, checked); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package y3pb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/y3"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// testDescriptor returns the descriptor of
+//
+//	message Frame {
+//	    string id = 1;
+//	    sint32 delta = 2;
+//	    repeated int32 nums = 3;
+//	    Meta meta = 4;
+//	    repeated Meta metas = 5;
+//	    map<string, int64> counts = 6;
+//	    Status status = 7;
+//	    bytes payload = 8;
+//	    double temp = 9;
+//	    fixed64 big = 10;
+//	    float ratio = 11;
+//	    bool ok = 12;
+//	    map<int32, Meta> meta_by_id = 13;
+//	}
+//
+//	message Meta { string name = 1; }
+//
+//	enum Status { OK = 0; NOT_FOUND = 1; }
+func testDescriptor(t *testing.T, extra ...*descriptorpb.FieldDescriptorProto) protoreflect.MessageDescriptor {
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+		label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		if repeated {
+			label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		}
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(num),
+			Label:    label.Enum(),
+			Type:     typ.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	entry := func(name string, key, val *descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{
+			Name:    proto.String(name),
+			Field:   []*descriptorpb.FieldDescriptorProto{key, val},
+			Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+		}
+	}
+
+	frame := &descriptorpb.DescriptorProto{
+		Name: proto.String("Frame"),
+		Field: append([]*descriptorpb.FieldDescriptorProto{
+			field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+			field("delta", 2, descriptorpb.FieldDescriptorProto_TYPE_SINT32, "", false),
+			field("nums", 3, descriptorpb.FieldDescriptorProto_TYPE_INT32, "", true),
+			field("meta", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.Meta", false),
+			field("metas", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.Meta", true),
+			field("counts", 6, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.Frame.CountsEntry", true),
+			field("status", 7, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".test.Status", false),
+			field("payload", 8, descriptorpb.FieldDescriptorProto_TYPE_BYTES, "", false),
+			field("temp", 9, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, "", false),
+			field("big", 10, descriptorpb.FieldDescriptorProto_TYPE_FIXED64, "", false),
+			field("ratio", 11, descriptorpb.FieldDescriptorProto_TYPE_FLOAT, "", false),
+			field("ok", 12, descriptorpb.FieldDescriptorProto_TYPE_BOOL, "", false),
+			field("meta_by_id", 13, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.Frame.MetaByIdEntry", true),
+		}, extra...),
+		NestedType: []*descriptorpb.DescriptorProto{
+			entry("CountsEntry",
+				field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+				field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, "", false)),
+			entry("MetaByIdEntry",
+				field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, "", false),
+				field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.Meta", false)),
+		},
+	}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("test.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			frame,
+			{
+				Name:  proto.String("Meta"),
+				Field: []*descriptorpb.FieldDescriptorProto{field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false)},
+			},
+		},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Status"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("OK"), Number: proto.Int32(0)},
+				{Name: proto.String("NOT_FOUND"), Number: proto.Int32(1)},
+			},
+		}},
+	}, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return fd.Messages().ByName("Frame")
+}
+
+type testMeta struct {
+	Name string `y3:"0x01"`
+}
+
+type testFrame struct {
+	ID       string             `y3:"0x01"`
+	Delta    int32              `y3:"0x02"`
+	Nums     []int32            `y3:"0x03"`
+	Meta     testMeta           `y3:"0x04"`
+	Metas    []testMeta         `y3:"0x05"`
+	Counts   map[string]int64   `y3:"0x06"`
+	Status   int32              `y3:"0x07"`
+	Payload  []byte             `y3:"0x08"`
+	Temp     float64            `y3:"0x09"`
+	Big      uint64             `y3:"0x0A"`
+	Ratio    float32            `y3:"0x0B"`
+	OK       bool               `y3:"0x0C"`
+	MetaByID map[int64]testMeta `y3:"0x0D"`
+}
+
+func testWire() []byte {
+	meta := func(name string) []byte {
+		return protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), name)
+	}
+	var b []byte
+	b = protowire.AppendString(protowire.AppendTag(b, 1, protowire.BytesType), "yomo")
+	b = protowire.AppendVarint(protowire.AppendTag(b, 2, protowire.VarintType), protowire.EncodeZigZag(-3))
+	// packed
+	b = protowire.AppendBytes(protowire.AppendTag(b, 3, protowire.BytesType), []byte{0x01, 0x96, 0x01})
+	// not packed
+	b = protowire.AppendVarint(protowire.AppendTag(b, 3, protowire.VarintType), 3)
+	b = protowire.AppendBytes(protowire.AppendTag(b, 4, protowire.BytesType), meta("edge"))
+	b = protowire.AppendBytes(protowire.AppendTag(b, 5, protowire.BytesType), meta("a"))
+	b = protowire.AppendBytes(protowire.AppendTag(b, 5, protowire.BytesType), meta("b"))
+	for _, k := range []string{"y", "x"} {
+		e := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), k)
+		e = protowire.AppendVarint(protowire.AppendTag(e, 2, protowire.VarintType), uint64(len(k)+int(k[0])))
+		b = protowire.AppendBytes(protowire.AppendTag(b, 6, protowire.BytesType), e)
+	}
+	b = protowire.AppendVarint(protowire.AppendTag(b, 7, protowire.VarintType), 1)
+	b = protowire.AppendBytes(protowire.AppendTag(b, 8, protowire.BytesType), []byte{0x01, 0x02})
+	b = protowire.AppendFixed64(protowire.AppendTag(b, 9, protowire.Fixed64Type), 0x4042400000000000)
+	b = protowire.AppendFixed64(protowire.AppendTag(b, 10, protowire.Fixed64Type), 1<<63)
+	b = protowire.AppendFixed32(protowire.AppendTag(b, 11, protowire.Fixed32Type), 0x3FC00000)
+	b = protowire.AppendVarint(protowire.AppendTag(b, 12, protowire.VarintType), 1)
+	e := protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), 7)
+	e = protowire.AppendBytes(protowire.AppendTag(e, 2, protowire.BytesType), meta("seven"))
+	b = protowire.AppendBytes(protowire.AppendTag(b, 13, protowire.BytesType), e)
+	return b
+}
+
+func TestDecode(t *testing.T) {
+	desc := testDescriptor(t)
+	buf, err := Decode(testWire(), desc, &Options{SeqID: 0x30})
+	assert.NoError(t, err)
+
+	want := testFrame{
+		ID:       "yomo",
+		Delta:    -3,
+		Nums:     []int32{1, 150, 3},
+		Meta:     testMeta{Name: "edge"},
+		Metas:    []testMeta{{Name: "a"}, {Name: "b"}},
+		Counts:   map[string]int64{"x": 121, "y": 122},
+		Status:   1,
+		Payload:  []byte{0x01, 0x02},
+		Temp:     36.5,
+		Big:      1 << 63,
+		Ratio:    1.5,
+		OK:       true,
+		MetaByID: map[int64]testMeta{7: {Name: "seven"}},
+	}
+	expected, err := y3.Marshal(0x30, &want)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, buf)
+
+	var frame testFrame
+	assert.NoError(t, y3.Unmarshal(buf, &frame))
+	assert.Equal(t, want, frame)
+}
+
+func TestEncode(t *testing.T) {
+	desc := testDescriptor(t)
+	for _, typed := range []bool{false, true} {
+		opts := &Options{Typed: typed}
+		buf, err := Decode(testWire(), desc, opts)
+		assert.NoError(t, err)
+		res, err := Encode(buf, desc, opts)
+		assert.NoError(t, err)
+
+		want, got := dynamicpb.NewMessage(desc), dynamicpb.NewMessage(desc)
+		assert.NoError(t, proto.Unmarshal(testWire(), want))
+		assert.NoError(t, proto.Unmarshal(res, got))
+		assert.True(t, proto.Equal(want, got), "typed: %v", typed)
+
+		// deterministic
+		again, err := Encode(buf, desc, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, res, again)
+	}
+}
+
+func TestEncodeNull(t *testing.T) {
+	desc := testDescriptor(t)
+	buf := y3.NewNode(0x30, y3.NewNull(0x01), y3.NewRawPrimitive(0x02, []byte{0x7F})).Encode()
+	res, err := Encode(buf, desc, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x10, 0xFE, 0x01}, res)
+}
+
+func TestErrors(t *testing.T) {
+	desc := testDescriptor(t)
+	tests := []struct {
+		buf []byte
+		err string
+	}{
+		{y3.NewNode(0x30, y3.NewRawPrimitive(0x20, nil)).Encode(), "y3pb: unknown field 0x20 of test.Frame"},
+		{y3.NewNode(0x30, y3.NewRawPrimitive(0x04, nil)).Encode(), "y3pb: field test.Frame.meta (0x4) should be a node packet"},
+		{y3.NewNode(0x30, y3.NewRawPrimitive(0x03, nil)).Encode(), "y3pb: field test.Frame.nums (0x3) should be a slice packet"},
+		{y3.NewNode(0x30, y3.NewSlice(0x06, y3.NewRawPrimitive(0x00, nil))).Encode(), "y3pb: invalid entry of map field test.Frame.counts"},
+		{y3.NewNode(0x30, y3.NewSlice(0x05, y3.NewRawPrimitive(0x00, nil))).Encode(), "y3pb: field test.Frame.metas (0x5) should be a node packet"},
+		{[]byte{0x01, 0x01, 0x01}, "y3pb: packet 0x1 is not a node packet"},
+	}
+	for _, tt := range tests {
+		_, err := Encode(tt.buf, desc, nil)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), tt.err)
+		}
+	}
+
+	_, err := Decode([]byte{0x0A, 0x05}, desc, nil)
+	assert.Contains(t, err.Error(), "y3pb: ")
+
+	big := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("big_number"),
+		JsonName: proto.String("bigNumber"),
+		Number:   proto.Int32(64),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+	}
+	_, err = Decode(nil, testDescriptor(t, big), nil)
+	assert.EqualError(t, err, "y3pb: number 64 of field test.Frame.big_number is greater than 63")
+}